@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"log/slog"
 	"context"
@@ -9,9 +10,18 @@ import (
 
 	"github.com/go-chi/chi/v5"
     "github.com/go-chi/chi/v5/middleware"
+	"github.com/seeques/subman/internal/auth"
 	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/graphqlapi"
+	"github.com/seeques/subman/internal/metrics"
+	"github.com/seeques/subman/internal/ratelimit"
+	"github.com/seeques/subman/internal/response"
 	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/stream"
 	"github.com/seeques/subman/internal/handler"
+	"github.com/seeques/subman/internal/health"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/tracing"
 
 	_ "github.com/seeques/subman/docs"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -20,15 +30,27 @@ import (
 type Server struct {
 	router chi.Router
 	postgresStorage *storage.PostgresStorage
-	port string
+	broker *stream.Broker
+	authenticator *auth.Authenticator
+	limiter *ratelimit.Limiter
+	metrics *metrics.Metrics
+	health *health.Checker
+	graphql *graphqlapi.Handler
+	port int
     cfg config.Config
 	httpServer *http.Server
 }
 
-func NewServer(storage *storage.PostgresStorage, cfg config.Config) *Server {
+func NewServer(storage *storage.PostgresStorage, broker *stream.Broker, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, metrics *metrics.Metrics, health *health.Checker, graphql *graphqlapi.Handler, cfg config.Config) *Server {
 	s := &Server{
         router: chi.NewRouter(),
         postgresStorage: storage,
+        broker: broker,
+        authenticator: authenticator,
+        limiter: limiter,
+        metrics: metrics,
+        health: health,
+        graphql: graphql,
         port: cfg.Port,
         cfg: cfg,
     }
@@ -38,31 +60,145 @@ func NewServer(storage *storage.PostgresStorage, cfg config.Config) *Server {
 
 func (s *Server) SetupRoutes() {
 	// middleware
-    s.router.Use(middleware.Logger)
-    s.router.Use(middleware.Recoverer) 
+    s.router.Use(s.metrics.Middleware)
     s.router.Use(middleware.RequestID) // generates unique id for request and attaches it to the context
+    s.router.Use(logging.Middleware) // access log and a logger carrying the request id, see logging.FromContext
+    s.router.Use(response.Middleware(response.Format(s.cfg.ErrorFormat)))
+    s.router.Use(middleware.Recoverer)
+    s.router.Use(tracing.Middleware)
 
-	h := handler.NewHandler(s.postgresStorage, s.cfg)
+	h := handler.NewHandler(s.postgresStorage, s.broker, s.cfg)
+
+	s.router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		response.RespondError(w, r, http.StatusNotFound, response.CodeRouteNotFound, "no route for "+r.URL.Path)
+	})
+	s.router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		response.RespondError(w, r, http.StatusMethodNotAllowed, response.CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+	})
 
 	s.router.Get("/swagger/*", httpSwagger.WrapHandler)
+	s.router.With(s.requireMetricsToken).Handle("/metrics", s.metrics.Handler())
+	s.router.Get("/healthz", s.health.Liveness)
+	s.router.Get("/readyz", s.health.Readiness)
+
+	// GraphQL resolves costs, so it shares the rate limit of the reports
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.limiter.Middleware(ratelimit.GroupAuth))
+		r.Use(s.authenticator.Middleware)
+		r.Use(s.authenticator.ResolveTenant)
+		r.Use(s.limiter.Middleware(ratelimit.GroupReports))
+
+		r.Method(http.MethodGet, "/graphql", s.graphql)
+		r.Method(http.MethodPost, "/graphql", s.graphql)
+	})
 
 	s.router.Route("/api/v1", func(r chi.Router){
-		r.Post("/subscriptions", h.Create)
-		r.Get("/subscriptions", h.List)
-		r.Get("/subscriptions/total-cost", h.TotalCost)
-		r.Get("/subscriptions/{id}", h.GetById)
-		r.Put("/subscriptions/{id}", h.Update)
-		r.Delete("/subscriptions/{id}", h.Delete)
+		// limits requests per IP before they are authenticated
+		r.Use(s.limiter.Middleware(ratelimit.GroupAuth))
+		r.Use(s.authenticator.Middleware)
+		r.Use(s.authenticator.ResolveTenant)
+
+		// permissions are checked by the handlers, see auth.Principal.Can.
+		// Every group of routes has its own rate limit, see RATE_LIMITS.
+		r.Group(func(r chi.Router) {
+			r.Use(s.limiter.Middleware(ratelimit.GroupDefault))
+
+			r.Post("/subscriptions", h.Create)
+			r.Get("/subscriptions", h.List)
+			r.Get("/subscriptions/{id}", h.GetById)
+			r.Put("/subscriptions/{id}", h.Update)
+			r.Delete("/subscriptions/{id}", h.Delete)
+			r.Post("/subscriptions/{id}/price-changes", h.CreatePriceChange)
+			r.Get("/subscriptions/{id}/price-changes", h.ListPriceChanges)
+
+			r.Post("/users", h.CreateUser)
+			r.Get("/users", h.ListUsers)
+			r.Get("/users/{id}", h.GetUser)
+			r.Put("/users/{id}", h.UpdateUser)
+			r.Delete("/users/{id}", h.DeleteUser)
+			r.Get("/users/{id}/subscriptions", h.UserSubscriptions)
+
+			r.Post("/services", h.CreateService)
+			r.Get("/services", h.ListServices)
+			r.Get("/services/{id}", h.GetService)
+			r.Put("/services/{id}", h.UpdateService)
+			r.Delete("/services/{id}", h.DeleteService)
+
+			r.Post("/budgets", h.CreateBudget)
+			r.Get("/budgets", h.ListBudgets)
+			r.Get("/budgets/{id}", h.GetBudget)
+			r.Put("/budgets/{id}", h.UpdateBudget)
+			r.Delete("/budgets/{id}", h.DeleteBudget)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.limiter.Middleware(ratelimit.GroupReports))
+
+			r.Get("/subscriptions/total-cost", h.TotalCost)
+			r.Get("/subscriptions/forecast", h.Forecast)
+			r.Get("/users/{id}/total-cost", h.UserTotalCost)
+			r.Get("/budgets/{id}/status", h.BudgetStatus)
+		})
+
+		r.With(s.limiter.Middleware(ratelimit.GroupStream)).Get("/subscriptions/events", h.Events)
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.limiter.Middleware(ratelimit.GroupAdmin))
+
+			r.Post("/webhooks", h.CreateWebhook)
+			r.Get("/webhooks", h.ListWebhooks)
+			r.Get("/webhooks/dead-letters", h.ListDeadLetters)
+			r.Post("/webhooks/dead-letters/{id}/retry", h.RetryDeadLetter)
+			r.Get("/webhooks/{id}", h.GetWebhook)
+			r.Delete("/webhooks/{id}", h.DeleteWebhook)
+
+			r.Post("/admin/api-keys", h.CreateAPIKey)
+			r.Get("/admin/api-keys", h.ListAPIKeys)
+			r.Delete("/admin/api-keys/{id}", h.RevokeAPIKey)
+			r.Post("/admin/api-keys/{id}/rotate", h.RotateAPIKey)
+
+			r.Get("/services/merge-proposals", h.ServiceMergeProposals)
+			r.Post("/services/merges", h.MergeServices)
+			r.Get("/services/merges", h.ListServiceMerges)
+
+			r.Post("/admin/roles", h.CreateRoleAssignment)
+			r.Get("/admin/roles", h.ListRoleAssignments)
+			r.Delete("/admin/roles/{id}", h.DeleteRoleAssignment)
+
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireOperator)
+				r.Post("/admin/tenants", h.CreateTenant)
+				r.Get("/admin/tenants", h.ListTenants)
+				r.Get("/admin/tenants/{id}", h.GetTenant)
+				r.Delete("/admin/tenants/{id}", h.DeleteTenant)
+			})
+		})
+	})
+}
+
+// requireMetricsToken protects /metrics with METRICS_TOKEN when it is set.
+// Scrapers aren't API clients, so they don't get API keys.
+func (s *Server) requireMetricsToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.MetricsToken != "" {
+			expected := "Bearer " + s.cfg.MetricsToken
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				response.RespondError(w, r, http.StatusUnauthorized, response.CodeUnauthenticated, "invalid metrics token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
 func (s *Server) Run() error {
 	s.httpServer = &http.Server{
-        Addr:         fmt.Sprintf(":%s", s.cfg.Port),
+        Addr:         fmt.Sprintf(":%d", s.cfg.Port),
         Handler:      s.router,
-        ReadTimeout:  10 * time.Second,
-        WriteTimeout: 10 * time.Second,
-        IdleTimeout:  30 * time.Second,
+        ReadTimeout:  s.cfg.ReadTimeout,
+        WriteTimeout: s.cfg.WriteTimeout,
+        IdleTimeout:  s.cfg.IdleTimeout,
     }
 
 	slog.Info("starting HTTP server", "port", s.cfg.Port)
@@ -70,7 +206,18 @@ func (s *Server) Run() error {
     return s.httpServer.ListenAndServe()
 }
 
+// Shutdown fails readiness first and keeps serving for the drain delay, so
+// load balancers stop sending requests before the listener is closed.
 func (s *Server) Shutdown(ctx context.Context) error {
+    s.health.Drain()
+    slog.Info("draining HTTP server", "delay", s.cfg.ShutdownDrainDelay)
+
+    select {
+    case <-time.After(s.cfg.ShutdownDrainDelay):
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+
     slog.Info("shutting down HTTP server")
     return s.httpServer.Shutdown(ctx)
 }
\ No newline at end of file
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// RestrictedUserID returns the user the caller of ctx is restricted to, or
+// nil when it may access every user. A restricted caller whose subject is
+// not a user id gets uuid.Nil, which matches no subscriptions.
+func RestrictedUserID(ctx context.Context) *uuid.UUID {
+	principal := PrincipalFromContext(ctx)
+	if principal == nil || !principal.RestrictedToSubject() {
+		return nil
+	}
+
+	userID, err := uuid.Parse(principal.Subject)
+	if err != nil {
+		return &uuid.Nil
+	}
+	return &userID
+}
+
+// CanAccessUser reports whether the caller of ctx may access the data of
+// userID.
+func CanAccessUser(ctx context.Context, userID uuid.UUID) bool {
+	restricted := RestrictedUserID(ctx)
+	return restricted == nil || (*restricted != uuid.Nil && *restricted == userID)
+}
+
+// CanReportOnUser is CanAccessUser for reports. Callers allowed to read the
+// reports of every user, such as finance, may report on anyone.
+func CanReportOnUser(ctx context.Context, userID uuid.UUID) bool {
+	if principal := PrincipalFromContext(ctx); principal != nil && principal.Can(PermReadAllReports) {
+		return true
+	}
+	return CanAccessUser(ctx, userID)
+}
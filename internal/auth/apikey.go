@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// API keys look like sm_<prefix>_<secret>. The prefix is stored in plain text
+// to find the key, the whole key only as a SHA-256 hash. Keys carry 256 bits
+// of randomness, so a fast hash is enough.
+const apiKeyTag = "sm"
+
+// GenerateAPIKey returns a new key together with its prefix and hash.
+func GenerateAPIKey() (key, prefix string, hash []byte, err error) {
+	prefixBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", nil, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", nil, err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = apiKeyTag + "_" + prefix + "_" + hex.EncodeToString(secretBytes)
+	return key, prefix, HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// apiKeyPrefix extracts the prefix of a key, reporting false when token is
+// not shaped like an API key.
+func apiKeyPrefix(token string) (string, bool) {
+	parts := strings.Split(token, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTag || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Allowed clock skew between the token issuer and this server
+const jwtLeeway = time.Minute
+
+// ErrInvalidToken is returned for JWTs that fail verification.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the registered, scope and tenant claims of a verified token.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	Scopes    []string
+	TenantID  uuid.UUID
+}
+
+// audience accepts both forms of the aud claim, a string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+type rawClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	// space separated, as in OAuth 2.0 access tokens
+	Scope string `json:"scope"`
+	// array form used by some identity providers
+	Scp      []string `json:"scp"`
+	TenantID string   `json:"tenant_id"`
+}
+
+// JWTVerifier validates signed bearer tokens against a JWKS.
+type JWTVerifier struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+}
+
+// NewJWTVerifier returns a verifier for tokens signed by keys. Issuer and
+// audience are only checked when set.
+func NewJWTVerifier(keys *KeySet, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// Verify checks the signature and the time, issuer and audience claims of
+// a compact JWS and returns its claims.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if errors.Is(err, errUnknownKey) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := decodeB64(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var raw rawClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+
+	now := time.Now()
+	if raw.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	expiresAt := time.Unix(*raw.ExpiresAt, 0)
+	if now.After(expiresAt.Add(jwtLeeway)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if raw.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(*raw.NotBefore, 0)) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+
+	if v.issuer != "" && raw.Issuer != v.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.audience != "" && !slices.Contains(raw.Audience, v.audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	if raw.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidToken)
+	}
+
+	scopes := raw.Scp
+	if raw.Scope != "" {
+		scopes = strings.Fields(raw.Scope)
+	}
+
+	// a token without a tenant must not fall into the default tenant, whose
+	// admins are operators of the whole installation
+	if raw.TenantID == "" {
+		return nil, fmt.Errorf("%w: missing tenant_id", ErrInvalidToken)
+	}
+	tenantID, err := uuid.Parse(raw.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid tenant_id", ErrInvalidToken)
+	}
+
+	return &Claims{
+		Subject:   raw.Subject,
+		Issuer:    raw.Issuer,
+		Audience:  raw.Audience,
+		ExpiresAt: expiresAt,
+		Scopes:    scopes,
+		TenantID:  tenantID,
+	}, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := decodeB64(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+var ecdsaCurveBits = map[string]int{
+	"ES256": 256,
+	"ES384": 384,
+	"ES512": 521,
+}
+
+// verifySignature checks an asymmetric JWS signature. Symmetric algorithms
+// and "none" are rejected, as is a key that doesn't match the algorithm.
+func verifySignature(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm")
+		}
+		if !ed25519.Verify(pub, signingInput, signature) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	h := hash.New()
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm")
+		}
+		if alg[0] == 'R' {
+			return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		}
+		return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+
+	default:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm")
+		}
+		if ecdsaCurveBits[alg] != pub.Curve.Params().BitSize {
+			return errors.New("key does not match algorithm")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+}
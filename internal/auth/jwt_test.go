@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testIssuer   = "https://id.example.com/"
+	testAudience = "subman"
+	testTenant   = "6a1f8f0e-3c2b-4f7e-9d4a-2b6c8e0f1a3d"
+)
+
+var b64 = base64.RawURLEncoding
+
+// jwksServer serves a JWKS whose keys can be swapped, counting requests.
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     []map[string]string
+	requests atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...map[string]string) *jwksServer {
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   b64.EncodeToString(key.N.Bytes()),
+		"e":   b64.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   b64.EncodeToString(x),
+		"y":   b64.EncodeToString(y),
+	}
+}
+
+func mustGenerateRSA(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64.EncodeToString(b)
+}
+
+// signRS256 returns a compact JWS of claims signed with key under kid.
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	input := encodeSegment(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64.EncodeToString(signature)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	input := encodeSegment(t, map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return input + "." + b64.EncodeToString(signature)
+}
+
+// validClaims are accepted by a verifier for testIssuer and testAudience.
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"sub":       "user-42",
+		"iss":       testIssuer,
+		"aud":       testAudience,
+		"exp":       now.Add(time.Hour).Unix(),
+		"iat":       now.Unix(),
+		"scope":     "read reports",
+		"tenant_id": testTenant,
+	}
+}
+
+func with(claims map[string]any, key string, value any) map[string]any {
+	if value == nil {
+		delete(claims, key)
+	} else {
+		claims[key] = value
+	}
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey := mustGenerateRSA(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newJWKSServer(t, rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+
+	keys, err := NewKeySet(context.Background(), server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	verifier := NewJWTVerifier(keys, testIssuer, testAudience)
+
+	now := time.Now()
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	valid := signRS256(t, rsaKey, "rsa-1", validClaims())
+	parts := strings.Split(valid, ".")
+
+	// HS256 keyed with the public key, which a verifier trusting the
+	// header's alg would accept
+	hsInput := encodeSegment(t, map[string]string{"alg": "HS256", "kid": "rsa-1"}) + "." + parts[1]
+	mac := hmac.New(sha256.New, publicPEM)
+	mac.Write([]byte(hsInput))
+	hsToken := hsInput + "." + b64.EncodeToString(mac.Sum(nil))
+
+	tamperedSignature := []byte(parts[2])
+	if tamperedSignature[10] == 'A' {
+		tamperedSignature[10] = 'B'
+	} else {
+		tamperedSignature[10] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "valid", token: valid},
+		{name: "valid ES256", token: signES256(t, ecKey, "ec-1", validClaims())},
+		{name: "audience array", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "aud", []string{"other", testAudience}))},
+		{name: "expired within leeway", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "exp", now.Add(-30*time.Second).Unix()))},
+		{name: "expired", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "exp", now.Add(-2*time.Minute).Unix())), wantErr: "expired"},
+		{name: "missing exp", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "exp", nil)), wantErr: "missing exp"},
+		{name: "nbf in the future", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "nbf", now.Add(5*time.Minute).Unix())), wantErr: "not valid yet"},
+		{name: "nbf within leeway", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "nbf", now.Add(30*time.Second).Unix()))},
+		{name: "wrong issuer", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "iss", "https://evil.example.com/")), wantErr: "unexpected issuer"},
+		{name: "wrong audience", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "aud", "other")), wantErr: "unexpected audience"},
+		{name: "missing audience", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "aud", nil)), wantErr: "unexpected audience"},
+		{name: "missing sub", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "sub", nil)), wantErr: "missing sub"},
+		{name: "missing tenant", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "tenant_id", nil)), wantErr: "missing tenant_id"},
+		{name: "invalid tenant", token: signRS256(t, rsaKey, "rsa-1", with(validClaims(), "tenant_id", "acme")), wantErr: "invalid tenant_id"},
+		{
+			name:    "alg none",
+			token:   encodeSegment(t, map[string]string{"alg": "none", "kid": "rsa-1"}) + "." + parts[1] + ".",
+			wantErr: `unsupported algorithm "none"`,
+		},
+		{name: "HS256 with the public key as secret", token: hsToken, wantErr: `unsupported algorithm "HS256"`},
+		{
+			name:    "RS256 header on an EC key",
+			token:   encodeSegment(t, map[string]string{"alg": "RS256", "kid": "ec-1"}) + "." + parts[1] + "." + parts[2],
+			wantErr: "key does not match algorithm",
+		},
+		{name: "tampered signature", token: parts[0] + "." + parts[1] + "." + string(tamperedSignature), wantErr: "verification error"},
+		{
+			name:    "tampered claims",
+			token:   parts[0] + "." + encodeSegment(t, with(validClaims(), "scope", "admin")) + "." + parts[2],
+			wantErr: "verification error",
+		},
+		{name: "signed by another key", token: signRS256(t, mustGenerateRSA(t), "rsa-1", validClaims()), wantErr: "verification error"},
+		{name: "unknown kid", token: signRS256(t, rsaKey, "rsa-2", validClaims()), wantErr: "unknown signing key"},
+		{name: "malformed", token: parts[0] + "." + parts[1], wantErr: "malformed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Subject != "user-42" {
+					t.Errorf("subject = %q, want user-42", claims.Subject)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Verify succeeded, want %q", tt.wantErr)
+			}
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("error %v is not ErrInvalidToken", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutIssuerAndAudience(t *testing.T) {
+	key := mustGenerateRSA(t)
+	server := newJWKSServer(t, rsaJWK("rsa-1", key))
+	keys, err := NewKeySet(context.Background(), server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	// neither is checked when the verifier isn't configured with them
+	token := signRS256(t, key, "", with(with(validClaims(), "iss", "anyone"), "aud", nil))
+	if _, err := NewJWTVerifier(keys, "", "").Verify(context.Background(), token); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+// stale makes the key set look loaded long enough ago to reload for an
+// unknown kid.
+func stale(keys *KeySet) {
+	keys.mu.Lock()
+	keys.loadedAt = time.Now().Add(-2 * minJWKSReload)
+	keys.mu.Unlock()
+}
+
+func TestKeySetUnknownKidReloads(t *testing.T) {
+	oldKey, newKey := mustGenerateRSA(t), mustGenerateRSA(t)
+	server := newJWKSServer(t, rsaJWK("old", oldKey))
+	keys, err := NewKeySet(context.Background(), server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	verifier := NewJWTVerifier(keys, testIssuer, testAudience)
+
+	// the issuer starts signing with a key published after the set was loaded
+	server.setKeys(rsaJWK("old", oldKey), rsaJWK("new", newKey))
+	token := signRS256(t, newKey, "new", validClaims())
+
+	// right after a load, unknown kids don't hit the source again
+	if _, err := verifier.Verify(context.Background(), token); err == nil {
+		t.Fatal("Verify succeeded before the key set was reloaded")
+	}
+	if got := server.requests.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", got)
+	}
+
+	stale(keys)
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatalf("Verify after reload: %v", err)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+
+	// known kids don't trigger a reload
+	if _, err := verifier.Verify(context.Background(), signRS256(t, oldKey, "old", validClaims())); err != nil {
+		t.Fatalf("Verify with the old key: %v", err)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, newKey := mustGenerateRSA(t), mustGenerateRSA(t)
+	server := newJWKSServer(t, rsaJWK("old", oldKey))
+	keys, err := NewKeySet(context.Background(), server.URL, time.Minute)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	verifier := NewJWTVerifier(keys, testIssuer, testAudience)
+
+	oldToken := signRS256(t, oldKey, "old", validClaims())
+	if _, err := verifier.Verify(context.Background(), oldToken); err != nil {
+		t.Fatalf("Verify with the old key: %v", err)
+	}
+
+	// the old key is retired; once the refresh interval passes it is gone
+	server.setKeys(rsaJWK("new", newKey))
+	stale(keys)
+
+	if _, err := verifier.Verify(context.Background(), oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify with the retired key = %v, want ErrInvalidToken", err)
+	}
+	if _, err := verifier.Verify(context.Background(), signRS256(t, newKey, "new", validClaims())); err != nil {
+		t.Errorf("Verify with the new key: %v", err)
+	}
+}
+
+func TestKeySetKeepsKeysWhenSourceFails(t *testing.T) {
+	key := mustGenerateRSA(t)
+	server := newJWKSServer(t, rsaJWK("k", key))
+	keys, err := NewKeySet(context.Background(), server.URL, time.Minute)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	server.Close()
+	stale(keys)
+
+	token := signRS256(t, key, "k", validClaims())
+	if _, err := NewJWTVerifier(keys, testIssuer, testAudience).Verify(context.Background(), token); err != nil {
+		t.Errorf("Verify while the JWKS is unavailable: %v", err)
+	}
+}
+
+func TestAuthenticateJWTPrincipal(t *testing.T) {
+	key := mustGenerateRSA(t)
+	server := newJWKSServer(t, rsaJWK("k", key))
+	keys, err := NewKeySet(context.Background(), server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	a := &Authenticator{jwt: NewJWTVerifier(keys, testIssuer, testAudience)}
+
+	tests := []struct {
+		name       string
+		claims     map[string]any
+		wantScopes []string
+	}{
+		{name: "scope string", claims: validClaims(), wantScopes: []string{"read", "reports"}},
+		{name: "scp array", claims: with(with(validClaims(), "scope", nil), "scp", []string{"read", "write"}), wantScopes: []string{"read", "write"}},
+		{name: "scope takes precedence", claims: with(validClaims(), "scp", []string{"admin"}), wantScopes: []string{"read", "reports"}},
+		{name: "no scopes", claims: with(validClaims(), "scope", nil), wantScopes: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Authenticate(context.Background(), signRS256(t, key, "k", tt.claims))
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+
+			want := &Principal{
+				ID:       "jwt:user-42",
+				Name:     "user-42",
+				Subject:  "user-42",
+				Scopes:   tt.wantScopes,
+				TenantID: uuid.MustParse(testTenant),
+			}
+			if !reflect.DeepEqual(p, want) {
+				t.Errorf("principal = %+v, want %+v", p, want)
+			}
+			// end users only see their own data unless they are admins
+			if !p.RestrictedToSubject() {
+				t.Error("RestrictedToSubject() = false for a non-admin token")
+			}
+		})
+	}
+}
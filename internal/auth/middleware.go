@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/tenant"
+)
+
+// Authenticator resolves the Authorization header of a request to a
+// principal. Bearer tokens are either API keys or, when a verifier is set,
+// JWTs issued by the platform identity provider.
+type Authenticator struct {
+	storage *storage.PostgresStorage
+	// bootstrapKey is accepted as an admin key without being stored, so the
+	// first real keys can be issued
+	bootstrapKey string
+	jwt          *JWTVerifier
+}
+
+// NewAuthenticator returns an authenticator for API keys, and for JWTs when
+// jwt is not nil.
+func NewAuthenticator(storage *storage.PostgresStorage, bootstrapKey string, jwt *JWTVerifier) *Authenticator {
+	return &Authenticator{
+		storage:      storage,
+		bootstrapKey: bootstrapKey,
+		jwt:          jwt,
+	}
+}
+
+// bearerToken returns the credentials of an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="subman"`)
+	response.RespondError(w, r, http.StatusUnauthorized, response.CodeUnauthenticated, message)
+}
+
+// Middleware rejects requests without a valid API key or token and stores
+// the authenticated principal in the request context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			unauthorized(w, r, "missing bearer token")
+			return
+		}
+
+		principal, err := a.Authenticate(r.Context(), token)
+		if errors.Is(err, ErrInvalidKey) {
+			unauthorized(w, r, "invalid API key")
+			return
+		}
+		if errors.Is(err, ErrInvalidToken) {
+			logging.FromContext(r.Context()).Info("rejected bearer token", "error", err)
+			unauthorized(w, r, "invalid token")
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to authenticate request", "error", err)
+			response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+			return
+		}
+
+		logging.With(r.Context(), "principal", principal.ID)
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// Authenticate resolves a bearer token to a principal, returning
+// ErrInvalidKey or ErrInvalidToken when it is not accepted.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if _, isKey := apiKeyPrefix(token); isKey || a.jwt == nil || a.isBootstrapKey(token) {
+		return a.authenticateAPIKey(ctx, token)
+	}
+	return a.authenticateJWT(ctx, token)
+}
+
+// ErrInvalidKey is returned for unknown, revoked or malformed API keys.
+var ErrInvalidKey = errors.New("invalid api key")
+
+// isBootstrapKey reports whether token is the configured bootstrap key,
+// compared in constant time.
+func (a *Authenticator) isBootstrapKey(token string) bool {
+	return a.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.bootstrapKey)) == 1
+}
+
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, token string) (*Principal, error) {
+	if a.isBootstrapKey(token) {
+		return &Principal{ID: "bootstrap", Name: "bootstrap", Scopes: []string{ScopeAdmin}, TenantID: tenant.DefaultID}, nil
+	}
+
+	prefix, ok := apiKeyPrefix(token)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	// the tenant is only known once the key is found
+	lookupCtx := tenant.WithAllTenants(ctx)
+	key, err := a.storage.GetActiveAPIKeyByPrefix(lookupCtx, prefix)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(HashAPIKey(token), key.KeyHash) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	if err := a.storage.TouchAPIKey(lookupCtx, key.ID); err != nil {
+		logging.FromContext(ctx).Warn("failed to record API key usage", "error", err, "api_key_id", key.ID)
+	}
+
+	return &Principal{
+		ID:       "apikey:" + strconv.Itoa(key.ID),
+		Name:     key.Name,
+		Scopes:   key.Scopes,
+		TenantID: key.TenantID,
+	}, nil
+}
+
+func (a *Authenticator) authenticateJWT(ctx context.Context, token string) (*Principal, error) {
+	claims, err := a.jwt.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		ID:       "jwt:" + claims.Subject,
+		Name:     claims.Subject,
+		Subject:  claims.Subject,
+		Scopes:   claims.Scopes,
+		TenantID: claims.TenantID,
+	}, nil
+}
+
+// ResolveTenant scopes the request to the tenant of its principal and loads
+// the roles assigned to the principal there. Operators may pick another
+// tenant with the X-Tenant-ID header; for anyone else a header naming a
+// different tenant is rejected with 403. It must run after
+// Authenticator.Middleware.
+func (a *Authenticator) ResolveTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := PrincipalFromContext(r.Context())
+		if principal == nil {
+			unauthorized(w, r, "missing bearer token")
+			return
+		}
+
+		tenantID, err := a.TenantOf(r.Context(), principal, r.Header.Get(tenant.Header))
+		switch {
+		case errors.Is(err, ErrInvalidTenant):
+			response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidTenant, "invalid "+tenant.Header+" header")
+			return
+		case errors.Is(err, ErrForeignTenant):
+			response.RespondError(w, r, http.StatusForbidden, response.CodeForeignTenant, "access to other tenants is not allowed")
+			return
+		case errors.Is(err, ErrTenantNotFound):
+			response.RespondError(w, r, http.StatusNotFound, response.CodeTenantNotFound, "tenant not found")
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("failed to resolve tenant", "error", err, "principal", principal.ID)
+			response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+			return
+		}
+
+		logging.With(r.Context(), "tenant_id", tenantID)
+		next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), tenantID)))
+	})
+}
+
+var (
+	// ErrInvalidTenant is returned for a requested tenant that is not a UUID.
+	ErrInvalidTenant = errors.New("invalid tenant id")
+	// ErrForeignTenant is returned when a caller other than an operator
+	// asks for another tenant than its own.
+	ErrForeignTenant  = errors.New("access to other tenants is not allowed")
+	ErrTenantNotFound = errors.New("tenant not found")
+)
+
+// TenantOf loads the roles of principal in its tenant and returns the
+// tenant it acts on: its own, or the requested one, a tenant ID sent as
+// X-Tenant-ID, when given and principal is an operator.
+func (a *Authenticator) TenantOf(ctx context.Context, principal *Principal, requested string) (uuid.UUID, error) {
+	roles, err := a.storage.GetPrincipalRoles(tenant.WithTenant(ctx, principal.TenantID), principal.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("get principal roles: %w", err)
+	}
+	principal.Roles = roles
+
+	if requested == "" {
+		return principal.TenantID, nil
+	}
+
+	tenantID, err := uuid.Parse(requested)
+	if err != nil {
+		return uuid.Nil, ErrInvalidTenant
+	}
+	if tenantID == principal.TenantID {
+		return tenantID, nil
+	}
+	if !principal.IsOperator() {
+		return uuid.Nil, ErrForeignTenant
+	}
+
+	if _, err := a.storage.GetTenant(ctx, tenantID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrTenantNotFound
+		}
+		return uuid.Nil, fmt.Errorf("get tenant: %w", err)
+	}
+	return tenantID, nil
+}
+
+// RequireOperator rejects requests from callers that are not operators
+// with 403. It must run after Authenticator.Middleware.
+func RequireOperator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := PrincipalFromContext(r.Context())
+		if principal == nil {
+			unauthorized(w, r, "missing bearer token")
+			return
+		}
+
+		if !principal.IsOperator() {
+			response.RespondError(w, r, http.StatusForbidden, response.CodeOperatorRequired, "only operators can manage tenants")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
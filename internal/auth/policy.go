@@ -0,0 +1,75 @@
+package auth
+
+import "slices"
+
+// Permission is an action the access policy decides on.
+type Permission string
+
+const (
+	PermReadSubscriptions  Permission = "subscriptions:read"
+	PermWriteSubscriptions Permission = "subscriptions:write"
+	PermReadBudgets        Permission = "budgets:read"
+	PermWriteBudgets       Permission = "budgets:write"
+	PermReadUsers          Permission = "users:read"
+	PermWriteUsers         Permission = "users:write"
+	// PermReadReports covers total cost, forecasts and budget status of the
+	// users the caller can access
+	PermReadReports Permission = "reports:read"
+	// PermReadAllReports lifts the own-user restriction of end users for reports
+	PermReadAllReports Permission = "reports:read_all"
+	PermManageWebhooks Permission = "webhooks:manage"
+	PermManageAPIKeys  Permission = "api_keys:manage"
+	PermManageRoles    Permission = "roles:manage"
+	// PermManageServices covers changes to the service catalog, which rename
+	// the subscriptions of every user
+	PermManageServices Permission = "services:manage"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	// RoleFinance can see the totals of every user in the tenant
+	RoleFinance = "finance"
+	RoleAdmin   = "admin"
+)
+
+// Roles lists every role that can be assigned.
+var Roles = []string{RoleViewer, RoleEditor, RoleFinance, RoleAdmin}
+
+func IsRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer:  {PermReadSubscriptions, PermReadBudgets, PermReadUsers},
+	RoleEditor:  {PermReadSubscriptions, PermWriteSubscriptions, PermReadBudgets, PermWriteBudgets, PermReadUsers, PermWriteUsers},
+	RoleFinance: {PermReadSubscriptions, PermReadBudgets, PermReadUsers, PermReadReports, PermReadAllReports},
+}
+
+// scopePermissions keeps API key and token scopes granting what they did
+// before roles existed.
+var scopePermissions = map[string][]Permission{
+	ScopeRead:    {PermReadSubscriptions, PermReadBudgets, PermReadUsers},
+	ScopeWrite:   {PermWriteSubscriptions, PermWriteBudgets, PermWriteUsers},
+	ScopeReports: {PermReadReports},
+}
+
+// Can reports whether the caller's scopes or assigned roles grant perm.
+// The admin scope and role grant everything.
+func (p *Principal) Can(perm Permission) bool {
+	if p.IsAdmin() {
+		return true
+	}
+
+	for _, scope := range p.Scopes {
+		if slices.Contains(scopePermissions[scope], perm) {
+			return true
+		}
+	}
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/tenant"
+)
+
+const (
+	ScopeRead    = "read"
+	ScopeWrite   = "write"
+	ScopeReports = "reports"
+	// ScopeAdmin grants every other scope as well
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every scope a key can be granted.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeReports, ScopeAdmin}
+
+func IsScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// ID identifies the caller across requests, e.g. "apikey:12"
+	ID   string
+	Name string
+	// Subject is the end user a bearer token was issued to. It is empty for
+	// API keys, which act on behalf of a service rather than a user.
+	Subject string
+	Scopes  []string
+	// Roles are assigned to the caller in its tenant, see Can
+	Roles []string
+	// TenantID is the tenant the caller belongs to
+	TenantID uuid.UUID
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// IsAdmin reports whether the caller has the admin scope or role.
+func (p *Principal) IsAdmin() bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Roles, RoleAdmin)
+}
+
+// RestrictedToSubject reports whether the caller may only access the
+// subscriptions of its own user, i.e. it is an end user who is not an admin.
+func (p *Principal) RestrictedToSubject() bool {
+	return p.Subject != "" && !p.IsAdmin()
+}
+
+// IsOperator reports whether the caller administers the whole installation,
+// i.e. it is an admin of the default tenant. Operators manage tenants and
+// may act on behalf of any tenant.
+func (p *Principal) IsOperator() bool {
+	return p.TenantID == tenant.DefaultID && p.IsAdmin()
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller authenticated by Authenticator.Middleware,
+// or nil outside of an authenticated request.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,90 @@
+package billing
+
+import (
+	"time"
+
+	"github.com/seeques/subman/internal/models"
+)
+
+// MonthlyPrice returns what sub costs in the given month: zero outside of its
+// active range or during the trial, otherwise the latest scheduled price
+// effective on or before the month, falling back to sub.Price.
+// changes must be ordered by effective date.
+func MonthlyPrice(sub *models.Subscription, changes []models.PriceChange, month time.Time) int {
+	if month.Before(sub.StartDate) || (sub.EndDate != nil && month.After(*sub.EndDate)) {
+		return 0
+	}
+
+	if sub.TrialEndDate != nil && !month.After(*sub.TrialEndDate) {
+		return 0
+	}
+
+	price := sub.Price
+	for _, change := range changes {
+		if change.EffectiveDate.After(month) {
+			break
+		}
+		price = change.Price
+	}
+	return price
+}
+
+// TotalCost sums the monthly prices of subs over every month between
+// startPeriod and endPeriod inclusive.
+func TotalCost(subs []models.Subscription, changes map[int][]models.PriceChange, startPeriod, endPeriod time.Time) int {
+	total := 0
+
+	for _, sub := range subs {
+		// Find if startPeriod overlaps with the startDate
+		overlapStart := sub.StartDate
+		if startPeriod.After(overlapStart) {
+			overlapStart = startPeriod
+		}
+
+		// Find if endDate overlaps with the endPeriod
+		overlapEnd := endPeriod
+		if sub.EndDate != nil && sub.EndDate.Before(overlapEnd) {
+			overlapEnd = *sub.EndDate
+		}
+
+		for month := overlapStart; !month.After(overlapEnd); month = month.AddDate(0, 1, 0) {
+			total += MonthlyPrice(&sub, changes[sub.ID], month)
+		}
+	}
+
+	return total
+}
+
+// NextRenewal returns the first month after the given one that sub is
+// charged for, and its price then. It reports false when sub ends before
+// it is charged again.
+func NextRenewal(sub *models.Subscription, changes []models.PriceChange, after time.Time) (time.Time, int, bool) {
+	month := after.AddDate(0, 1, 0)
+	if sub.StartDate.After(month) {
+		month = sub.StartDate
+	}
+	if sub.TrialEndDate != nil && !month.After(*sub.TrialEndDate) {
+		month = sub.TrialEndDate.AddDate(0, 1, 0)
+	}
+
+	if sub.EndDate != nil && month.After(*sub.EndDate) {
+		return time.Time{}, 0, false
+	}
+	return month, MonthlyPrice(sub, changes, month), true
+}
+
+// SubscriptionIDs collects the ids of subs, e.g. to look up their price changes.
+func SubscriptionIDs(subs []models.Subscription) []int {
+	ids := make([]int, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ID
+	}
+	return ids
+}
+
+// CurrentMonth returns the first day of the current month in UTC, matching
+// how MM-YYYY dates are stored.
+func CurrentMonth() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,243 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seeques/subman/internal/models"
+)
+
+func month(m time.Month, year int) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func monthPtr(m time.Month, year int) *time.Time {
+	t := month(m, year)
+	return &t
+}
+
+func TestMonthlyPrice(t *testing.T) {
+	sub := &models.Subscription{
+		ID:           1,
+		Price:        400,
+		StartDate:    month(time.January, 2025),
+		EndDate:      monthPtr(time.December, 2025),
+		TrialEndDate: monthPtr(time.February, 2025),
+	}
+	changes := []models.PriceChange{
+		{SubscriptionID: 1, Price: 500, EffectiveDate: month(time.June, 2025)},
+		{SubscriptionID: 1, Price: 600, EffectiveDate: month(time.October, 2025)},
+	}
+
+	tests := []struct {
+		name  string
+		month time.Time
+		want  int
+	}{
+		{"before start", month(time.December, 2024), 0},
+		{"first trial month", month(time.January, 2025), 0},
+		{"last trial month", month(time.February, 2025), 0},
+		{"first paid month", month(time.March, 2025), 400},
+		{"month before price change", month(time.May, 2025), 400},
+		{"price change effective", month(time.June, 2025), 500},
+		{"between price changes", month(time.September, 2025), 500},
+		{"second price change", month(time.October, 2025), 600},
+		{"end month", month(time.December, 2025), 600},
+		{"after end", month(time.January, 2026), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MonthlyPrice(sub, changes, tt.month); got != tt.want {
+				t.Errorf("MonthlyPrice(%s) = %d, want %d", tt.month.Format("01-2006"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		sub      models.Subscription
+		changes  []models.PriceChange
+		from, to time.Time
+		want     int
+	}{
+		{
+			name: "open ended",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.March, 2025)},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 4 * 400,
+		},
+		{
+			name: "open ended starting before the period",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2024)},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 6 * 400,
+		},
+		{
+			name: "ends inside the period",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025), EndDate: monthPtr(time.March, 2025)},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 3 * 400,
+		},
+		{
+			name: "ends before the period",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2024), EndDate: monthPtr(time.December, 2024)},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 0,
+		},
+		{
+			name: "starts after the period",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.July, 2025)},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 0,
+		},
+		{
+			name: "trial months are free",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025), TrialEndDate: monthPtr(time.February, 2025)},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 4 * 400,
+		},
+		{
+			name: "trial ending before the period",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.October, 2024), TrialEndDate: monthPtr(time.November, 2024)},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 6 * 400,
+		},
+		{
+			name: "price change effective mid-range",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025)},
+			changes: []models.PriceChange{
+				{SubscriptionID: 1, Price: 500, EffectiveDate: month(time.April, 2025)},
+			},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 3*400 + 3*500,
+		},
+		{
+			name: "price change effective before the period",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2024)},
+			changes: []models.PriceChange{
+				{SubscriptionID: 1, Price: 500, EffectiveDate: month(time.June, 2024)},
+			},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 6 * 500,
+		},
+		{
+			name: "trial and price change",
+			sub:  models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025), TrialEndDate: monthPtr(time.March, 2025)},
+			changes: []models.PriceChange{
+				{SubscriptionID: 1, Price: 500, EffectiveDate: month(time.February, 2025)},
+			},
+			from: month(time.January, 2025),
+			to:   month(time.June, 2025),
+			want: 3 * 500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := map[int][]models.PriceChange{tt.sub.ID: tt.changes}
+			if got := TotalCost([]models.Subscription{tt.sub}, changes, tt.from, tt.to); got != tt.want {
+				t.Errorf("TotalCost() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalCostSumsSubscriptions(t *testing.T) {
+	subs := []models.Subscription{
+		{ID: 1, Price: 400, StartDate: month(time.January, 2025)},
+		{ID: 2, Price: 300, StartDate: month(time.January, 2025), EndDate: monthPtr(time.February, 2025)},
+	}
+	changes := map[int][]models.PriceChange{
+		1: {{SubscriptionID: 1, Price: 100, EffectiveDate: month(time.March, 2025)}},
+	}
+
+	got := TotalCost(subs, changes, month(time.January, 2025), month(time.March, 2025))
+	if want := 400 + 400 + 100 + 300 + 300; got != want {
+		t.Errorf("TotalCost() = %d, want %d", got, want)
+	}
+}
+
+func TestNextRenewal(t *testing.T) {
+	changes := []models.PriceChange{
+		{SubscriptionID: 1, Price: 500, EffectiveDate: month(time.June, 2025)},
+	}
+
+	tests := []struct {
+		name      string
+		sub       models.Subscription
+		after     time.Time
+		wantMonth time.Time
+		wantPrice int
+		wantOK    bool
+	}{
+		{
+			name:      "next month",
+			sub:       models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025)},
+			after:     month(time.March, 2025),
+			wantMonth: month(time.April, 2025),
+			wantPrice: 400,
+			wantOK:    true,
+		},
+		{
+			name:      "at price change",
+			sub:       models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025)},
+			after:     month(time.May, 2025),
+			wantMonth: month(time.June, 2025),
+			wantPrice: 500,
+			wantOK:    true,
+		},
+		{
+			name:      "not started yet",
+			sub:       models.Subscription{ID: 1, Price: 400, StartDate: month(time.September, 2025)},
+			after:     month(time.March, 2025),
+			wantMonth: month(time.September, 2025),
+			wantPrice: 500,
+			wantOK:    true,
+		},
+		{
+			name:      "after trial",
+			sub:       models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025), TrialEndDate: monthPtr(time.April, 2025)},
+			after:     month(time.January, 2025),
+			wantMonth: month(time.May, 2025),
+			wantPrice: 400,
+			wantOK:    true,
+		},
+		{
+			name:   "ended",
+			sub:    models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025), EndDate: monthPtr(time.March, 2025)},
+			after:  month(time.March, 2025),
+			wantOK: false,
+		},
+		{
+			name:   "ends during trial",
+			sub:    models.Subscription{ID: 1, Price: 400, StartDate: month(time.January, 2025), EndDate: monthPtr(time.March, 2025), TrialEndDate: monthPtr(time.March, 2025)},
+			after:  month(time.January, 2025),
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMonth, gotPrice, gotOK := NextRenewal(&tt.sub, changes, tt.after)
+			if gotOK != tt.wantOK {
+				t.Fatalf("NextRenewal() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+			if !gotMonth.Equal(tt.wantMonth) || gotPrice != tt.wantPrice {
+				t.Errorf("NextRenewal() = %s, %d, want %s, %d",
+					gotMonth.Format("01-2006"), gotPrice, tt.wantMonth.Format("01-2006"), tt.wantPrice)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/tenant"
+)
+
+// Status is a snapshot of a budget's spend in its current period.
+type Status struct {
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Spent       int
+	Reached     []int
+}
+
+// Evaluator compares user spend against budgets and records a breach the
+// first time each threshold is reached in a period.
+type Evaluator struct {
+	storage *storage.PostgresStorage
+}
+
+func NewEvaluator(storage *storage.PostgresStorage) *Evaluator {
+	return &Evaluator{storage: storage}
+}
+
+// CurrentPeriod returns the first and last month of the budget period that
+// contains month.
+func CurrentPeriod(budget *models.Budget, month time.Time) (time.Time, time.Time) {
+	if budget.Period == models.BudgetPeriodYearly {
+		start := time.Date(month.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 11, 0)
+	}
+	return month, month
+}
+
+// Status computes the spend of the budget's user in the current period.
+func (e *Evaluator) Status(ctx context.Context, budget *models.Budget) (*Status, error) {
+	start, end := CurrentPeriod(budget, billing.CurrentMonth())
+
+	subs, err := e.storage.GetSubscriptionsForPeriod(ctx, storage.TotalCostParams{
+		StartPeriod: start,
+		EndPeriod:   end,
+		UserID:      &budget.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := e.storage.GetPriceChanges(ctx, billing.SubscriptionIDs(subs))
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Spent:       billing.TotalCost(subs, changes, start, end),
+	}
+
+	for _, threshold := range budget.Thresholds {
+		if status.Spent*100 >= budget.Limit*threshold {
+			status.Reached = append(status.Reached, threshold)
+		}
+	}
+	return status, nil
+}
+
+// Evaluate records a breach for every threshold the budget has reached in
+// the current period that was not recorded before.
+func (e *Evaluator) Evaluate(ctx context.Context, budget *models.Budget) error {
+	// the scheduled run sees every tenant, but spend is per tenant
+	ctx = tenant.WithTenant(ctx, budget.TenantID)
+
+	status, err := e.Status(ctx, budget)
+	if err != nil {
+		return fmt.Errorf("evaluate budget %d: %w", budget.ID, err)
+	}
+
+	for _, threshold := range status.Reached {
+		breach := &models.BudgetBreach{
+			BudgetID:    budget.ID,
+			Threshold:   threshold,
+			PeriodStart: status.PeriodStart,
+			Spent:       status.Spent,
+			Limit:       budget.Limit,
+		}
+
+		created, err := e.storage.RecordBudgetBreach(ctx, breach)
+		if err != nil {
+			return fmt.Errorf("evaluate budget %d: %w", budget.ID, err)
+		}
+
+		if created {
+			slog.Warn("budget threshold reached",
+				"budget_id", budget.ID,
+				"user_id", budget.UserID,
+				"threshold", threshold,
+				"spent", status.Spent,
+				"limit", budget.Limit,
+			)
+		}
+	}
+	return nil
+}
+
+// EvaluateUser evaluates every budget of a user, e.g. after one of their
+// subscriptions changed. A nil userID evaluates all budgets. A budget that
+// fails is logged and skipped, so it doesn't hold up the others; the
+// failures are returned joined.
+func (e *Evaluator) EvaluateUser(ctx context.Context, userID *uuid.UUID) error {
+	budgets, err := e.storage.GetBudgetsForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for i := range budgets {
+		if ctx.Err() != nil {
+			return errors.Join(errs, ctx.Err())
+		}
+		if err := e.Evaluate(ctx, &budgets[i]); err != nil {
+			slog.Error("failed to evaluate budget", "error", err, "budget_id", budgets[i].ID, "tenant_id", budgets[i].TenantID)
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// Run evaluates all budgets every interval until ctx is cancelled.
+func (e *Evaluator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.EvaluateUser(ctx, nil); err != nil && ctx.Err() == nil {
+			slog.Error("scheduled budget evaluation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,406 @@
+// Package cli implements the client subcommands of the subman binary, which
+// talk to a running server through its REST API.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/seeques/subman/internal/client"
+	"github.com/seeques/subman/internal/handler"
+)
+
+// Commands lists the subcommands handled by Run.
+var Commands = []string{"create", "get", "list", "update", "delete", "total-cost", "export", "import"}
+
+// Usage describes the client subcommands.
+const Usage = `client commands:
+  create       create a subscription
+  get ID       show a subscription
+  list         list subscriptions
+  update ID    change some fields of a subscription
+  delete ID    delete a subscription
+  total-cost   sum up what subscriptions cost over a period
+  export       write every subscription as JSON or CSV
+  import FILE  create subscriptions from a JSON or CSV file, - for stdin
+
+Client commands read the API URL and key from a profile in
+~/.config/subman/cli.yaml (or SUBMAN_CONFIG), SUBMAN_URL and SUBMAN_API_KEY,
+or the --url and --api-key flags.
+`
+
+// command is a client subcommand being run.
+type command struct {
+	name    string
+	flags   *flag.FlagSet
+	args    []string
+	stdout  io.Writer
+	stderr  io.Writer
+	profile string
+	url     string
+	apiKey  string
+	tenant  string
+	output  string
+}
+
+// Run runs the client subcommand name with args, positional arguments
+// first.
+func Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	cmd := &command{
+		name:   name,
+		flags:  flag.NewFlagSet("subman "+name, flag.ContinueOnError),
+		stdout: stdout,
+		stderr: stderr,
+	}
+	cmd.flags.SetOutput(stderr)
+	cmd.flags.StringVar(&cmd.profile, "profile", os.Getenv("SUBMAN_PROFILE"), "profile of the CLI config file to use (env SUBMAN_PROFILE)")
+	cmd.flags.StringVar(&cmd.url, "url", os.Getenv("SUBMAN_URL"), "base URL of the API (env SUBMAN_URL)")
+	cmd.flags.StringVar(&cmd.apiKey, "api-key", os.Getenv("SUBMAN_API_KEY"), "API key (env SUBMAN_API_KEY)")
+	cmd.flags.StringVar(&cmd.tenant, "tenant", os.Getenv("SUBMAN_TENANT_ID"), "tenant to act on behalf of, operators only (env SUBMAN_TENANT_ID)")
+	cmd.flags.StringVar(&cmd.output, "o", os.Getenv("SUBMAN_OUTPUT"), "output format: table, json or csv (env SUBMAN_OUTPUT)")
+
+	switch name {
+	case "create":
+		return cmd.create(ctx, args)
+	case "get":
+		return cmd.get(ctx, args)
+	case "list":
+		return cmd.list(ctx, args)
+	case "update":
+		return cmd.update(ctx, args)
+	case "delete":
+		return cmd.delete(ctx, args)
+	case "total-cost":
+		return cmd.totalCost(ctx, args)
+	case "export":
+		return cmd.export(ctx, args)
+	case "import":
+		return cmd.importFile(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// parse parses the flags and returns a client for the resolved profile.
+// want positional arguments are required, they may come before or after
+// the flags.
+func (c *command) parse(args []string, want int) (*client.Client, error) {
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = append(positional, args[0]), args[1:]
+	}
+	if err := c.flags.Parse(args); err != nil {
+		return nil, err
+	}
+	c.args = append(positional, c.flags.Args()...)
+	if len(c.args) != want {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", c.name, want, len(c.args))
+	}
+
+	profile, err := loadProfile(c.profile)
+	if err != nil {
+		return nil, err
+	}
+	if c.url == "" {
+		c.url = profile.URL
+	}
+	if c.url == "" {
+		c.url = defaultURL
+	}
+	if c.apiKey == "" {
+		c.apiKey = profile.APIKey
+	}
+	if c.tenant == "" {
+		c.tenant = profile.TenantID
+	}
+	if c.output == "" {
+		c.output = profile.Output
+	}
+	if c.output == "" {
+		c.output = OutputTable
+	}
+
+	return client.New(c.url, c.apiKey, c.tenant), nil
+}
+
+// subscriptionFlags registers the fields of a subscription as flags.
+func (c *command) subscriptionFlags(req *handler.SubscriptionRequest) {
+	c.flags.StringVar(&req.ServiceName, "service", "", "service name")
+	c.flags.IntVar(&req.Price, "price", 0, "monthly price in whole rubles")
+	c.flags.StringVar(&req.UserID, "user", "", "user ID (UUID)")
+	c.flags.StringVar(&req.StartDate, "start", "", "first month, MM-YYYY")
+	c.flags.StringVar(&req.EndDate, "end", "", "last month, MM-YYYY")
+	c.flags.StringVar(&req.TrialEndDate, "trial-end", "", "last month of the free trial, MM-YYYY")
+	c.flags.StringVar(&req.Category, "category", "", "category, such as entertainment")
+	c.flags.Func("tags", "comma separated tags, empty to remove them", func(s string) error {
+		req.Tags = splitTags(s)
+		return nil
+	})
+}
+
+// splitTags splits a comma separated list of tags, dropping empty ones.
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (c *command) create(ctx context.Context, args []string) error {
+	var req handler.SubscriptionRequest
+	c.subscriptionFlags(&req)
+
+	api, err := c.parse(args, 0)
+	if err != nil {
+		return err
+	}
+
+	sub, err := api.CreateSubscription(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(c.stdout, c.output, []handler.SubscriptionResponse{*sub})
+}
+
+func (c *command) get(ctx context.Context, args []string) error {
+	api, err := c.parse(args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(c.args[0])
+	if err != nil {
+		return err
+	}
+
+	sub, err := api.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(c.stdout, c.output, []handler.SubscriptionResponse{*sub})
+}
+
+func (c *command) list(ctx context.Context, args []string) error {
+	page := c.flags.Int("page", 1, "page number")
+	limit := c.flags.Int("limit", 0, "subscriptions per page, the server's default when 0")
+	all := c.flags.Bool("all", false, "list every page")
+
+	api, err := c.parse(args, 0)
+	if err != nil {
+		return err
+	}
+
+	if *all {
+		subs, err := api.ListAllSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+		return printSubscriptions(c.stdout, c.output, subs)
+	}
+
+	list, err := api.ListSubscriptions(ctx, *page, *limit)
+	if err != nil {
+		return err
+	}
+	if err := printSubscriptions(c.stdout, c.output, list.Data); err != nil {
+		return err
+	}
+	if c.output == OutputTable {
+		fmt.Fprintf(c.stderr, "page %d of %d, %d subscriptions\n", list.Meta.Page, list.Meta.TotalPages, list.Meta.Total)
+	}
+	return nil
+}
+
+// update changes only the fields given as flags, as the API replaces the
+// whole subscription.
+func (c *command) update(ctx context.Context, args []string) error {
+	var changes handler.SubscriptionRequest
+	c.subscriptionFlags(&changes)
+
+	api, err := c.parse(args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(c.args[0])
+	if err != nil {
+		return err
+	}
+
+	current, err := api.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	req := handler.SubscriptionRequest{
+		ServiceName:  current.ServiceName,
+		Price:        current.Price,
+		UserID:       current.UserID,
+		StartDate:    current.StartDate,
+		EndDate:      deref(current.EndDate),
+		TrialEndDate: deref(current.TrialEndDate),
+		Category:     current.Category,
+		Tags:         current.Tags,
+	}
+	c.flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "service":
+			req.ServiceName = changes.ServiceName
+		case "price":
+			req.Price = changes.Price
+		case "user":
+			req.UserID = changes.UserID
+		case "start":
+			req.StartDate = changes.StartDate
+		case "end":
+			req.EndDate = changes.EndDate
+		case "trial-end":
+			req.TrialEndDate = changes.TrialEndDate
+		case "category":
+			req.Category = changes.Category
+		case "tags":
+			req.Tags = changes.Tags
+		}
+	})
+
+	sub, err := api.UpdateSubscription(ctx, id, req)
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(c.stdout, c.output, []handler.SubscriptionResponse{*sub})
+}
+
+func (c *command) delete(ctx context.Context, args []string) error {
+	api, err := c.parse(args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(c.args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := api.DeleteSubscription(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "subscription %d deleted\n", id)
+	return nil
+}
+
+func (c *command) totalCost(ctx context.Context, args []string) error {
+	var params client.TotalCostParams
+	c.flags.StringVar(&params.StartPeriod, "from", "", "first month, MM-YYYY (required)")
+	c.flags.StringVar(&params.EndPeriod, "to", "", "last month, MM-YYYY (required)")
+	c.flags.StringVar(&params.UserID, "user", "", "only subscriptions of this user")
+	c.flags.StringVar(&params.ServiceName, "service", "", "only subscriptions of this service")
+	c.flags.StringVar(&params.Category, "category", "", "only subscriptions in this category")
+	c.flags.StringVar(&params.Tag, "tag", "", "only subscriptions with this tag")
+
+	api, err := c.parse(args, 0)
+	if err != nil {
+		return err
+	}
+	if params.StartPeriod == "" || params.EndPeriod == "" {
+		return errors.New("--from and --to are required")
+	}
+
+	cost, err := api.TotalCost(ctx, params)
+	if err != nil {
+		return err
+	}
+	return printTotalCost(c.stdout, c.output, cost)
+}
+
+// export writes every subscription, as JSON unless another format is
+// asked for, so it can be imported again.
+func (c *command) export(ctx context.Context, args []string) error {
+	file := c.flags.String("file", "", "file to write to instead of stdout")
+
+	explicitOutput := os.Getenv("SUBMAN_OUTPUT") != ""
+	api, err := c.parse(args, 0)
+	if err != nil {
+		return err
+	}
+	c.flags.Visit(func(f *flag.Flag) {
+		explicitOutput = explicitOutput || f.Name == "o"
+	})
+	if !explicitOutput {
+		c.output = OutputJSON
+	}
+
+	subs, err := api.ListAllSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := c.stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := printSubscriptions(w, c.output, subs); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "exported %d subscriptions\n", len(subs))
+	return nil
+}
+
+// importFile creates a subscription for every record of a file, carrying
+// on past failures and reporting them at the end.
+func (c *command) importFile(ctx context.Context, args []string) error {
+	format := c.flags.String("format", "", "json or csv, guessed from the file extension by default")
+
+	api, err := c.parse(args, 1)
+	if err != nil {
+		return err
+	}
+
+	reqs, err := readSubscriptions(c.args[0], *format)
+	if err != nil {
+		return err
+	}
+
+	var created []handler.SubscriptionResponse
+	failed := 0
+	for i, req := range reqs {
+		sub, err := api.CreateSubscription(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(c.stderr, "record %d (%s): %v\n", i+1, req.ServiceName, err)
+			failed++
+			continue
+		}
+		created = append(created, *sub)
+	}
+
+	if err := printSubscriptions(c.stdout, c.output, created); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "imported %d of %d subscriptions\n", len(created), len(reqs))
+	if failed > 0 {
+		return fmt.Errorf("%d subscriptions failed to import", failed)
+	}
+	return nil
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid subscription id %q", s)
+	}
+	return id, nil
+}
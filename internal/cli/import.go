@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seeques/subman/internal/handler"
+)
+
+// readSubscriptions reads the subscriptions to import from path, - for
+// stdin. JSON files hold an array of subscriptions, CSV files a header row
+// naming the columns. Both are what export writes, extra fields such as id
+// are ignored.
+func readSubscriptions(path, format string) ([]handler.SubscriptionRequest, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	switch format {
+	case OutputJSON:
+		var reqs []handler.SubscriptionRequest
+		if err := json.NewDecoder(r).Decode(&reqs); err != nil {
+			return nil, fmt.Errorf("parse JSON: %w", err)
+		}
+		return reqs, nil
+	case OutputCSV:
+		return readCSV(r)
+	default:
+		return nil, errors.New("unknown input format, pass --format json or --format csv")
+	}
+}
+
+func readCSV(r io.Reader) ([]handler.SubscriptionRequest, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	column := func(record []string, name string) string {
+		if i := slices.Index(header, name); i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	for _, required := range []string{"service_name", "price", "user_id", "start_date"} {
+		if !slices.Contains(header, required) {
+			return nil, fmt.Errorf("CSV header lacks the %s column", required)
+		}
+	}
+
+	reqs := make([]handler.SubscriptionRequest, 0, len(records)-1)
+	for i, record := range records[1:] {
+		price, err := strconv.Atoi(column(record, "price"))
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d: invalid price %q", i+2, column(record, "price"))
+		}
+		reqs = append(reqs, handler.SubscriptionRequest{
+			ServiceName:  column(record, "service_name"),
+			Price:        price,
+			UserID:       column(record, "user_id"),
+			StartDate:    column(record, "start_date"),
+			EndDate:      column(record, "end_date"),
+			TrialEndDate: column(record, "trial_end_date"),
+			Category:     column(record, "category"),
+			Tags:         splitTags(column(record, "tags")),
+		})
+	}
+	return reqs, nil
+}
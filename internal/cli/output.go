@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seeques/subman/internal/handler"
+)
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputCSV   = "csv"
+)
+
+var subscriptionColumns = []string{"id", "service_name", "price", "user_id", "start_date", "end_date", "trial_end_date", "category", "tags", "created_at", "updated_at"}
+
+func subscriptionRow(sub handler.SubscriptionResponse) []string {
+	return []string{
+		strconv.Itoa(sub.ID),
+		sub.ServiceName,
+		strconv.Itoa(sub.Price),
+		sub.UserID,
+		sub.StartDate,
+		deref(sub.EndDate),
+		deref(sub.TrialEndDate),
+		sub.Category,
+		strings.Join(sub.Tags, ","),
+		sub.CreatedAt.Format(time.RFC3339),
+		sub.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func printSubscriptions(w io.Writer, format string, subs []handler.SubscriptionResponse) error {
+	if format == OutputJSON {
+		if subs == nil {
+			subs = []handler.SubscriptionResponse{}
+		}
+		return printJSON(w, subs)
+	}
+
+	rows := make([][]string, len(subs))
+	for i, sub := range subs {
+		rows[i] = subscriptionRow(sub)
+	}
+	return printRows(w, format, subscriptionColumns, rows)
+}
+
+func printTotalCost(w io.Writer, format string, cost *handler.TotalCostResponse) error {
+	if format == OutputJSON {
+		return printJSON(w, cost)
+	}
+
+	columns := []string{"total_cost", "currency", "period_start", "period_end", "subscriptions_count"}
+	row := []string{
+		strconv.Itoa(cost.TotalCost),
+		cost.Currency,
+		cost.PeriodStart,
+		cost.PeriodEnd,
+		strconv.Itoa(cost.SubscriptionsCount),
+	}
+	return printRows(w, format, columns, [][]string{row})
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printRows writes rows as an aligned table with upper case headers or as
+// CSV.
+func printRows(w io.Writer, format string, columns []string, rows [][]string) error {
+	switch format {
+	case OutputCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		cw.WriteAll(rows)
+		return cw.Error()
+	case OutputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, expected table, json or csv", format)
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
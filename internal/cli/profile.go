@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const defaultURL = "http://localhost:8080"
+
+// Profile is where and as whom the CLI talks to the API.
+type Profile struct {
+	URL      string `yaml:"url"`
+	APIKey   string `yaml:"api_key"`
+	TenantID string `yaml:"tenant_id"`
+	Output   string `yaml:"output"`
+}
+
+// profileFile is the CLI config file, holding named profiles:
+//
+//	profile: prod
+//	profiles:
+//	  prod:
+//	    url: https://subman.example.com
+//	    api_key: sk_...
+type profileFile struct {
+	// Profile is used when none is given with --profile or SUBMAN_PROFILE
+	Profile  string             `yaml:"profile"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// profilePath returns the CLI config file: SUBMAN_CONFIG, or cli.yaml in
+// the user's config directory, e.g. ~/.config/subman/cli.yaml.
+func profilePath() (string, bool) {
+	if path := os.Getenv("SUBMAN_CONFIG"); path != "" {
+		return path, true
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(dir, "subman", "cli.yaml"), false
+}
+
+// loadProfile resolves the profile named name, or the file's default one,
+// from the CLI config file. A missing file is only an error when it was
+// named explicitly.
+func loadProfile(name string) (Profile, error) {
+	path, explicit := profilePath()
+
+	var file profileFile
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !explicit:
+	case err != nil:
+		return Profile{}, fmt.Errorf("read CLI config: %w", err)
+	default:
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return Profile{}, fmt.Errorf("parse CLI config %s: %w", path, err)
+		}
+	}
+
+	if name == "" {
+		name = file.Profile
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	profile, ok := file.Profiles[name]
+	if !ok && name != "default" {
+		return Profile{}, fmt.Errorf("no profile %q in %s", name, path)
+	}
+	return profile, nil
+}
@@ -0,0 +1,249 @@
+// Package client is a Go client for the subscription REST API, speaking the
+// request and response types of the handler package.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seeques/subman/internal/handler"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/tenant"
+)
+
+// maxRetries bounds how often a rate limited request is retried.
+const maxRetries = 3
+
+// APIError is an error response of the API.
+type APIError struct {
+	StatusCode int
+	// Code is the stable error code, empty for servers answering in the
+	// legacy format
+	Code    response.Code
+	Message string
+	// Fields lists the invalid fields of a rejected request
+	Fields []response.FieldError
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Client calls the subscription API of one tenant.
+type Client struct {
+	baseURL  string
+	apiKey   string
+	tenantID string
+	http     *http.Client
+}
+
+// New returns a client for the API at baseURL, e.g. http://localhost:8080,
+// authenticating with apiKey. A non-empty tenantID is sent as X-Tenant-ID
+// to act on behalf of another tenant.
+func New(baseURL, apiKey, tenantID string) *Client {
+	return &Client{
+		baseURL:  strings.TrimSuffix(baseURL, "/") + "/api/v1",
+		apiKey:   apiKey,
+		tenantID: tenantID,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) CreateSubscription(ctx context.Context, req handler.SubscriptionRequest) (*handler.SubscriptionResponse, error) {
+	var sub handler.SubscriptionResponse
+	if err := c.do(ctx, http.MethodPost, "/subscriptions", nil, req, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (c *Client) GetSubscription(ctx context.Context, id int) (*handler.SubscriptionResponse, error) {
+	var sub handler.SubscriptionResponse
+	if err := c.do(ctx, http.MethodGet, "/subscriptions/"+strconv.Itoa(id), nil, nil, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (c *Client) ListSubscriptions(ctx context.Context, page, limit int) (*handler.ListResponse, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var list handler.ListResponse
+	if err := c.do(ctx, http.MethodGet, "/subscriptions", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ListAllSubscriptions pages through every subscription the caller can see.
+func (c *Client) ListAllSubscriptions(ctx context.Context) ([]handler.SubscriptionResponse, error) {
+	var subs []handler.SubscriptionResponse
+	for page := 1; ; page++ {
+		list, err := c.ListSubscriptions(ctx, page, 100)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, list.Data...)
+		if page >= list.Meta.TotalPages {
+			return subs, nil
+		}
+	}
+}
+
+func (c *Client) UpdateSubscription(ctx context.Context, id int, req handler.SubscriptionRequest) (*handler.SubscriptionResponse, error) {
+	var sub handler.SubscriptionResponse
+	if err := c.do(ctx, http.MethodPut, "/subscriptions/"+strconv.Itoa(id), nil, req, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (c *Client) DeleteSubscription(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/subscriptions/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+type TotalCostParams struct {
+	// StartPeriod and EndPeriod are months formatted as MM-YYYY
+	StartPeriod string
+	EndPeriod   string
+	UserID      string
+	ServiceName string
+	Category    string
+	Tag         string
+}
+
+func (c *Client) TotalCost(ctx context.Context, params TotalCostParams) (*handler.TotalCostResponse, error) {
+	query := url.Values{}
+	query.Set("start_period", params.StartPeriod)
+	query.Set("end_period", params.EndPeriod)
+	if params.UserID != "" {
+		query.Set("user_id", params.UserID)
+	}
+	if params.ServiceName != "" {
+		query.Set("service_name", params.ServiceName)
+	}
+	if params.Category != "" {
+		query.Set("category", params.Category)
+	}
+	if params.Tag != "" {
+		query.Set("tag", params.Tag)
+	}
+
+	var cost handler.TotalCostResponse
+	if err := c.do(ctx, http.MethodGet, "/subscriptions/total-cost", query, nil, &cost); err != nil {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+// do sends a request with body encoded as JSON and decodes the response
+// into out. Rate limited requests are retried after the time the server
+// asks for.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json, "+response.ContentType)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+		if c.tenantID != "" {
+			req.Header.Set(tenant.Header, c.tenantID)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			resp.Body.Close()
+			if err := sleep(ctx, retryAfter(resp)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		defer resp.Body.Close()
+		return decode(resp, out)
+	}
+}
+
+func decode(resp *http.Response, out any) error {
+	if resp.StatusCode >= http.StatusBadRequest {
+		var problem struct {
+			response.Problem
+			// the message of the legacy format
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(data, &problem)
+
+		message := problem.Detail
+		if message == "" {
+			message = problem.Error
+		}
+		if message == "" {
+			message = strings.TrimSpace(string(data))
+		}
+		return &APIError{StatusCode: resp.StatusCode, Code: problem.Code, Message: message, Fields: problem.Errors}
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 1 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
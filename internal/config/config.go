@@ -1,21 +1,331 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Config holds every setting of the service. Each setting can be given as
+// an env var (PORT), a command line flag (--port) or a key of the config
+// file (port). Flags take precedence over env vars, which take precedence
+// over the config file, which takes precedence over the default.
 type Config struct {
-	DatabaseURL string
-	Port string
+	DatabaseURL string `config:"database_url" usage:"PostgreSQL connection string"`
+	// Applies pending migrations before serving, see the migrate subcommand
+	MigrateOnStart bool `config:"migrate_on_start" default:"false" usage:"Apply pending migrations on startup"`
+	Port        int    `config:"port" default:"8080" usage:"HTTP server port"`
+	// The gRPC API is served on its own port, and not at all when 0
+	GRPCPort int `config:"grpc_port" default:"9090" usage:"gRPC server port, 0 disables the gRPC API"`
+
+	// Server timeouts, see http.Server
+	ReadTimeout  time.Duration `config:"read_timeout" default:"10s" usage:"Maximum time to read a request"`
+	WriteTimeout time.Duration `config:"write_timeout" default:"10s" usage:"Maximum time to write a response, event streams are exempt"`
+	IdleTimeout  time.Duration `config:"idle_timeout" default:"30s" usage:"How long idle keep-alive connections are kept open"`
+	// ShutdownTimeout bounds the whole shutdown, including the drain delay
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" default:"60s" usage:"Maximum time to shut down gracefully"`
+	// How long readiness fails before the server stops accepting requests
+	// on shutdown, so load balancers notice and stop sending traffic
+	ShutdownDrainDelay time.Duration `config:"shutdown_drain_delay" default:"10s" usage:"How long readiness fails before the server stops accepting requests on shutdown"`
+
+	// Connection pool settings, see pgxpool.Config
+	DBMaxConns        int           `config:"db_max_conns" default:"10" usage:"Maximum number of database connections"`
+	DBMinConns        int           `config:"db_min_conns" default:"0" usage:"Number of database connections kept open when idle"`
+	DBMaxConnLifetime time.Duration `config:"db_max_conn_lifetime" default:"1h" usage:"How long a database connection is used before it is replaced"`
+	DBMaxConnIdleTime time.Duration `config:"db_max_conn_idle_time" default:"30m" usage:"How long an idle database connection is kept open"`
+	DBConnectTimeout  time.Duration `config:"db_connect_timeout" default:"5s" usage:"Maximum time to establish a database connection"`
+
+	// Shape of error responses: RFC 7807 problem details, or the older
+	// {"error": "..."} for clients that haven't moved yet
+	ErrorFormat string `config:"error_format" default:"problem" oneof:"problem legacy" usage:"Format of error responses"`
+
+	// Page sizes of list endpoints taking a limit parameter
+	DefaultPageSize int `config:"default_page_size" default:"10" usage:"Page size of list endpoints when no limit is given"`
+	MaxPageSize     int `config:"max_page_size" default:"100" usage:"Largest limit list endpoints accept"`
+
+	// Limits on GraphQL queries, checked before they run. Complexity counts
+	// every field once per item of the lists above it.
+	GraphQLMaxDepth      int `config:"graphql_max_depth" default:"8" usage:"Deepest nesting of fields a GraphQL query may have"`
+	GraphQLMaxComplexity int `config:"graphql_max_complexity" default:"1000" usage:"Highest estimated number of fields a GraphQL query may resolve"`
+
+	// How often all budgets are re-evaluated in the background
+	BudgetEvaluationInterval time.Duration `config:"budget_evaluation_interval" default:"1h" usage:"How often all budgets are evaluated"`
+	// How often queued webhook deliveries are picked up
+	WebhookPollInterval time.Duration `config:"webhook_poll_interval" default:"5s" usage:"How often queued webhook deliveries are sent"`
+	// Where outbox events are published: any of webhook, log and file
+	OutboxSinks []string `config:"outbox_sinks" default:"webhook" oneof:"webhook log file" usage:"Comma separated sinks for outbox events"`
+	// File the file sink appends events to
+	OutboxFile string `config:"outbox_file" default:"outbox.jsonl" usage:"File the file sink appends to"`
+	// How often the outbox is checked for new events
+	OutboxPollInterval time.Duration `config:"outbox_poll_interval" default:"1s" usage:"How often the outbox is relayed"`
+
+	// Accepted as an admin API key without being stored, to issue the first keys
+	BootstrapAPIKey string `config:"bootstrap_api_key" usage:"Admin key accepted without being stored, for issuing the first keys"`
+	// File path or URL of the JWKS used to verify bearer JWTs. JWT
+	// authentication is disabled when empty.
+	JWKS string `config:"jwt_jwks" usage:"File path or URL of the JWKS for verifying bearer JWTs"`
+	// How long a loaded JWKS is used before it is fetched again
+	JWKSRefreshInterval time.Duration `config:"jwt_jwks_refresh_interval" default:"1h" usage:"How often the JWKS is reloaded"`
+	// Expected iss and aud claims of bearer JWTs, not checked when empty
+	JWTIssuer   string `config:"jwt_issuer" usage:"Required iss claim of JWTs"`
+	JWTAudience string `config:"jwt_audience" usage:"Required aud claim of JWTs"`
+
+	// Where rate limit buckets are kept: memory, postgres to share them
+	// between instances, or off
+	RateLimitStore string `config:"rate_limit_store" default:"memory" oneof:"memory postgres off" usage:"Where rate limit buckets are kept"`
+	// Limits per route group, e.g. "default=600/m,reports=60/m"
+	RateLimits []string `config:"rate_limits" default:"default=600/m,reports=60/m,stream=30/m,auth=1200/m" usage:"Comma separated limits per route group"`
+
+	// Required as a bearer token to scrape /metrics, which is open when empty
+	MetricsToken string `config:"metrics_token" usage:"Bearer token required to scrape /metrics"`
+	// How often the subscription gauges on /metrics are recomputed
+	MetricsRefreshInterval time.Duration `config:"metrics_refresh_interval" default:"1m" usage:"How often the subscription gauges are recomputed"`
+	// Where trace spans are exported: otlp, stdout or off
+	TracingExporter string `config:"tracing_exporter" default:"off" oneof:"otlp stdout off" usage:"Where trace spans are exported"`
+
+	// Log output format and the minimum level logged
+	LogFormat string `config:"log_format" default:"json" oneof:"json text" usage:"Log format"`
+	LogLevel  string `config:"log_level" default:"info" oneof:"debug info warn error" usage:"Minimum level logged"`
 }
 
-func LoadConfig() Config {
+// Load builds the config from the defaults, the config file named by
+// --config or CONFIG_FILE, the environment including a .env file, and the
+// command line flags in args, named name in the usage. It returns every invalid setting at once,
+// or flag.ErrHelp when the usage was requested.
+func Load(name string, args []string) (Config, error) {
 	godotenv.Load()
 
-	return Config{
-		DatabaseURL: os.Getenv("DATABASE_URL"),
-		Port: os.Getenv("PORT"),
+	var cfg Config
+	fields := settings(&cfg)
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	flagValues := make(map[string]*flagValue, len(fields))
+	for _, f := range fields {
+		value := &flagValue{value: f.def, isBool: f.value.Kind() == reflect.Bool}
+		flagValues[f.key] = value
+		fs.Var(value, f.flagName(), fmt.Sprintf("%s (env %s)", f.usage, f.envName()))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// later layers override earlier ones
+	raw := make(map[string]string, len(fields))
+	for _, f := range fields {
+		raw[f.key] = f.def
+	}
+
+	if *configFile != "" {
+		fileValues, err := readFile(*configFile)
+		if err != nil {
+			return Config{}, err
+		}
+		for key, value := range fileValues {
+			if _, ok := raw[key]; !ok {
+				return Config{}, fmt.Errorf("config file %s: unknown setting %q", *configFile, key)
+			}
+			raw[key] = value
+		}
+	}
+
+	// empty vars count as unset, as compose files pass through unset ones
+	for _, f := range fields {
+		if value := os.Getenv(f.envName()); value != "" {
+			raw[f.key] = value
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		key := strings.ReplaceAll(fl.Name, "-", "_")
+		if value, ok := flagValues[key]; ok {
+			raw[key] = value.value
+		}
+	})
+
+	var errs []error
+	for _, f := range fields {
+		if err := f.set(raw[f.key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// validate checks the settings that depend on each other or have to be in
+// a range. Types and allowed values are checked while loading.
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DatabaseURL != "", "database_url: is required")
+	check(c.Port > 0 && c.Port <= 65535, "port: must be between 1 and 65535")
+	check(c.GRPCPort >= 0 && c.GRPCPort <= 65535, "grpc_port: must be between 0 and 65535")
+	check(c.GRPCPort != c.Port, "grpc_port: must differ from port")
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"db_max_conn_lifetime", c.DBMaxConnLifetime},
+		{"db_max_conn_idle_time", c.DBMaxConnIdleTime},
+		{"db_connect_timeout", c.DBConnectTimeout},
+		{"budget_evaluation_interval", c.BudgetEvaluationInterval},
+		{"webhook_poll_interval", c.WebhookPollInterval},
+		{"outbox_poll_interval", c.OutboxPollInterval},
+		{"jwt_jwks_refresh_interval", c.JWKSRefreshInterval},
+		{"metrics_refresh_interval", c.MetricsRefreshInterval},
+	} {
+		check(d.value > 0, "%s: must be positive", d.key)
+	}
+	check(c.ShutdownDrainDelay >= 0, "shutdown_drain_delay: must not be negative")
+	check(c.ShutdownDrainDelay < c.ShutdownTimeout, "shutdown_drain_delay: must be shorter than shutdown_timeout")
+
+	check(c.DBMaxConns > 0, "db_max_conns: must be positive")
+	check(c.DBMinConns >= 0 && c.DBMinConns <= c.DBMaxConns, "db_min_conns: must be between 0 and db_max_conns")
+
+	check(c.MaxPageSize > 0, "max_page_size: must be positive")
+	check(c.DefaultPageSize > 0 && c.DefaultPageSize <= c.MaxPageSize, "default_page_size: must be between 1 and max_page_size")
+
+	check(c.GraphQLMaxDepth > 0, "graphql_max_depth: must be positive")
+	check(c.GraphQLMaxComplexity > 0, "graphql_max_complexity: must be positive")
+
+	check(len(c.OutboxSinks) > 0, "outbox_sinks: at least one sink is required")
+	check(!slices.Contains(c.OutboxSinks, "file") || c.OutboxFile != "", "outbox_file: is required by the file sink")
+
+	return errors.Join(errs...)
+}
+
+// flagValue keeps flags as given, to be parsed together with the other
+// sources. Bool settings can be passed as a bare --flag.
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
 	}
-}
\ No newline at end of file
+	return v.value
+}
+
+func (v *flagValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// setting is a field of Config described by its struct tags.
+type setting struct {
+	key   string
+	def   string
+	usage string
+	oneof []string
+	value reflect.Value
+}
+
+func settings(cfg *Config) []setting {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	fields := make([]setting, 0, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		fields = append(fields, setting{
+			key:   field.Tag.Get("config"),
+			def:   field.Tag.Get("default"),
+			usage: field.Tag.Get("usage"),
+			oneof: strings.Fields(field.Tag.Get("oneof")),
+			value: v.Field(i),
+		})
+	}
+	return fields
+}
+
+func (s setting) envName() string {
+	return strings.ToUpper(s.key)
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// set parses raw into the field's type.
+func (s setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch s.value.Interface().(type) {
+	case string:
+		if err := s.checkOneOf(raw); err != nil {
+			return err
+		}
+		s.value.SetString(raw)
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		s.value.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		s.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", raw)
+		}
+		s.value.SetInt(int64(d))
+	case []string:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			if err := s.checkOneOf(item); err != nil {
+				return err
+			}
+			list = append(list, item)
+		}
+		s.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+	return nil
+}
+
+func (s setting) checkOneOf(value string) error {
+	if len(s.oneof) > 0 && !slices.Contains(s.oneof, value) {
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(s.oneof, ", "))
+	}
+	return nil
+}
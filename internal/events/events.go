@@ -0,0 +1,89 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/models"
+)
+
+const (
+	SubscriptionCreated    = "subscription.created"
+	SubscriptionUpdated    = "subscription.updated"
+	SubscriptionDeleted    = "subscription.deleted"
+	SubscriptionEndingSoon = "subscription.ending_soon"
+)
+
+// Types lists every event type that is published.
+var Types = []string{
+	SubscriptionCreated,
+	SubscriptionUpdated,
+	SubscriptionDeleted,
+	SubscriptionEndingSoon,
+}
+
+func IsType(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event describes a change to a subscription. Its JSON form is what the
+// outbox stores and what sinks such as webhooks publish.
+type Event struct {
+	ID        uuid.UUID        `json:"id"`
+	TenantID  uuid.UUID        `json:"tenant_id"`
+	Type      string           `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      SubscriptionData `json:"data"`
+}
+
+// SubscriptionData mirrors the subscription representation of the REST API.
+type SubscriptionData struct {
+	ID           int       `json:"id"`
+	ServiceName  string    `json:"service_name"`
+	ServiceID    *int      `json:"service_id,omitempty"`
+	Category     string    `json:"category,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+	Price        int       `json:"price"`
+	UserID       string    `json:"user_id"`
+	StartDate    string    `json:"start_date"`
+	EndDate      *string   `json:"end_date,omitempty"`
+	TrialEndDate *string   `json:"trial_end_date,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func New(eventType string, sub *models.Subscription) Event {
+	return Event{
+		ID:        uuid.New(),
+		TenantID:  sub.TenantID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data: SubscriptionData{
+			ID:           sub.ID,
+			ServiceName:  sub.ServiceName,
+			ServiceID:    sub.ServiceID,
+			Category:     sub.Category,
+			Tags:         sub.Tags,
+			Price:        sub.Price,
+			UserID:       sub.UserID.String(),
+			StartDate:    sub.StartDate.Format("01-2006"),
+			EndDate:      formatMonthYear(sub.EndDate),
+			TrialEndDate: formatMonthYear(sub.TrialEndDate),
+			CreatedAt:    sub.CreatedAt,
+			UpdatedAt:    sub.UpdatedAt,
+		},
+	}
+}
+
+func formatMonthYear(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("01-2006")
+	return &s
+}
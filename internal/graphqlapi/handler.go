@@ -0,0 +1,92 @@
+// Package graphqlapi serves a read-only GraphQL API over the subscriptions,
+// with the users behind them and their costs, for clients that want to
+// fetch related data in one round trip.
+package graphqlapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// Handler executes GraphQL queries sent as POST bodies or GET query
+// parameters. It expects the caller to be authenticated and scoped to a
+// tenant by the auth middlewares.
+type Handler struct {
+	schema  graphql.Schema
+	storage *storage.PostgresStorage
+	cfg     config.Config
+}
+
+func NewHandler(storage *storage.PostgresStorage, cfg config.Config) (*Handler, error) {
+	schema, err := newSchema(&resolver{storage: storage, cfg: cfg})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		schema:  schema,
+		storage: storage,
+		cfg:     cfg,
+	}, nil
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				response.RespondFieldError(w, r, "variables", response.CodeInvalid, "invalid variables, expected a JSON object")
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logging.FromContext(r.Context()).Warn("invalid JSON in request body", "error", err)
+			response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidJSON, "invalid JSON")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		response.RespondError(w, r, http.StatusMethodNotAllowed, response.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if req.Query == "" {
+		response.RespondFieldError(w, r, "query", response.CodeRequired, "query is required")
+		return
+	}
+
+	if err := checkLimits(h.schema, req.Query, req.OperationName, req.Variables, h.cfg.GraphQLMaxDepth, h.cfg.GraphQLMaxComplexity); err != nil {
+		logging.FromContext(r.Context()).Warn("rejected GraphQL query", "error", err)
+		response.RespondJSON(w, http.StatusOK, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())},
+		})
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        withLoaders(r.Context(), newLoaders(h.storage)),
+	})
+
+	response.RespondJSON(w, http.StatusOK, result)
+}
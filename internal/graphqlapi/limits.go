@@ -0,0 +1,172 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// assumedListSize is the number of items a list field without a limit or
+// ids argument is expected to return when estimating complexity.
+const assumedListSize = 10
+
+// checkLimits rejects an operation nested deeper than maxDepth fields or
+// estimated to cost more than maxComplexity before it is executed. Every
+// field costs 1, and the fields below a list are counted once per item.
+// Introspection is free. Queries that don't parse or name an unknown
+// operation pass, for graphql.Do to report.
+func checkLimits(schema graphql.Schema, query, operationName string, variables map[string]any, maxDepth, maxComplexity int) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	a := analyzer{
+		schema:    schema,
+		variables: variables,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		spreading: make(map[string]bool),
+	}
+
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return nil
+	}
+
+	depth, complexity := a.selectionSet(operation.SelectionSet, schema.QueryType(), 1)
+	if depth > maxDepth {
+		return fmt.Errorf("query is nested %d levels deep, more than the limit of %d", depth, maxDepth)
+	}
+	if complexity > maxComplexity {
+		return fmt.Errorf("query complexity is %d, more than the limit of %d", complexity, maxComplexity)
+	}
+	return nil
+}
+
+type analyzer struct {
+	schema    graphql.Schema
+	variables map[string]any
+	fragments map[string]*ast.FragmentDefinition
+	// spreading holds the fragments being expanded, guarding against
+	// cycles, which validation rejects later on
+	spreading map[string]bool
+}
+
+// selectionSet returns the depth and complexity of set, selected on parent
+// at depth.
+func (a *analyzer) selectionSet(set *ast.SelectionSet, parent graphql.Type, depth int) (int, int) {
+	if set == nil {
+		return depth - 1, 0
+	}
+
+	maxDepth, complexity := depth, 0
+	add := func(d, c int) {
+		maxDepth = max(maxDepth, d)
+		complexity += c
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			add(a.field(selection, parent, depth))
+
+		case *ast.InlineFragment:
+			typ := parent
+			if selection.TypeCondition != nil {
+				typ = a.schema.Type(selection.TypeCondition.Name.Value)
+			}
+			add(a.selectionSet(selection.SelectionSet, typ, depth))
+
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := a.fragments[name]
+			if !ok || a.spreading[name] {
+				continue
+			}
+			a.spreading[name] = true
+			add(a.selectionSet(fragment.SelectionSet, a.schema.Type(fragment.TypeCondition.Name.Value), depth))
+			delete(a.spreading, name)
+		}
+	}
+	return maxDepth, complexity
+}
+
+func (a *analyzer) field(field *ast.Field, parent graphql.Type, depth int) (int, int) {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return depth, 0
+	}
+
+	object, ok := parent.(*graphql.Object)
+	if !ok {
+		return depth, 1
+	}
+	def, ok := object.Fields()[field.Name.Value]
+	if !ok {
+		return depth, 1
+	}
+
+	childDepth, childComplexity := a.selectionSet(field.SelectionSet, graphql.GetNamed(def.Type).(graphql.Type), depth+1)
+
+	typ := def.Type
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		typ = nonNull.OfType
+	}
+	if _, ok := typ.(*graphql.List); ok {
+		childComplexity *= a.listSize(field)
+	}
+	return childDepth, 1 + childComplexity
+}
+
+// listSize is the number of items a list field is expected to return: its
+// limit argument, the number of ids it is asked for, or assumedListSize.
+func (a *analyzer) listSize(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		value := a.value(arg.Value)
+		switch arg.Name.Value {
+		case "limit":
+			switch v := value.(type) {
+			case int:
+				return max(v, 1)
+			case float64:
+				return max(int(v), 1)
+			}
+		case "ids":
+			if list, ok := value.([]any); ok {
+				return max(len(list), 1)
+			}
+		}
+	}
+	return assumedListSize
+}
+
+// value resolves a literal or variable argument enough to size lists.
+func (a *analyzer) value(value ast.Value) any {
+	switch value := value.(type) {
+	case *ast.Variable:
+		return a.variables[value.Name.Value]
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(value.Value)
+		return n
+	case *ast.ListValue:
+		list := make([]any, len(value.Values))
+		for i, v := range value.Values {
+			list[i] = a.value(v)
+		}
+		return list
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package graphqlapi
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// userSubscriptions are the subscriptions of a user with what's needed to
+// price them.
+type userSubscriptions struct {
+	subscriptions []models.Subscription
+	priceChanges  map[int][]models.PriceChange
+}
+
+// loaders batch the lookups of one request, so resolving a field for every
+// item of a list takes one query instead of one per item. They cache what
+// they loaded for the rest of the request.
+type loaders struct {
+	// users loads nil for users that don't exist
+	users        *dataloader.Loader[uuid.UUID, *models.User]
+	byUser       *dataloader.Loader[uuid.UUID, userSubscriptions]
+	priceChanges *dataloader.Loader[int, []models.PriceChange]
+}
+
+func newLoaders(storage *storage.PostgresStorage) *loaders {
+	l := &loaders{}
+
+	l.users = dataloader.NewBatchedLoader(func(ctx context.Context, userIDs []uuid.UUID) []*dataloader.Result[*models.User] {
+		users, err := storage.GetUsers(ctx, userIDs)
+		return results(userIDs, func(id uuid.UUID) *models.User { return users[id] }, err)
+	})
+
+	l.priceChanges = dataloader.NewBatchedLoader(func(ctx context.Context, subscriptionIDs []int) []*dataloader.Result[[]models.PriceChange] {
+		changes, err := storage.GetPriceChanges(ctx, subscriptionIDs)
+		return results(subscriptionIDs, func(id int) []models.PriceChange { return changes[id] }, err)
+	})
+
+	l.byUser = dataloader.NewBatchedLoader(func(ctx context.Context, userIDs []uuid.UUID) []*dataloader.Result[userSubscriptions] {
+		subs, err := storage.GetSubscriptionsByUsers(ctx, userIDs)
+		if err != nil {
+			return results[uuid.UUID, userSubscriptions](userIDs, nil, err)
+		}
+
+		var all []models.Subscription
+		for _, userSubs := range subs {
+			all = append(all, userSubs...)
+		}
+		changes, err := storage.GetPriceChanges(ctx, billing.SubscriptionIDs(all))
+		if err != nil {
+			return results[uuid.UUID, userSubscriptions](userIDs, nil, err)
+		}
+
+		// the price changes of these subscriptions are known now
+		for _, sub := range all {
+			l.priceChanges.Prime(ctx, sub.ID, changes[sub.ID])
+		}
+
+		return results(userIDs, func(id uuid.UUID) userSubscriptions {
+			return userSubscriptions{subscriptions: subs[id], priceChanges: changes}
+		}, nil)
+	})
+
+	return l
+}
+
+// results lays out the values of a batch in the order of its keys, as
+// dataloader expects, failing every key with err when it is not nil.
+func results[K comparable, V any](keys []K, value func(K) V, err error) []*dataloader.Result[V] {
+	out := make([]*dataloader.Result[V], len(keys))
+	for i, key := range keys {
+		if err != nil {
+			out[i] = &dataloader.Result[V]{Error: err}
+			continue
+		}
+		out[i] = &dataloader.Result[V]{Data: value(key)}
+	}
+	return out
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,377 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/handler"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/storage"
+)
+
+var (
+	errForbidden = errors.New("access to other users is not allowed")
+	// errInternal hides the cause of a failure, which is logged instead
+	errInternal = errors.New("internal error")
+)
+
+// resolver resolves the fields of the schema with the same permissions as
+// the REST API. Fields below a user load through the request's loaders.
+type resolver struct {
+	storage *storage.PostgresStorage
+	cfg     config.Config
+}
+
+// authorize consults the access policy for the caller of ctx.
+func authorize(ctx context.Context, perm auth.Permission) error {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil || !principal.Can(perm) {
+		return fmt.Errorf("missing permission: %s", perm)
+	}
+	return nil
+}
+
+func parseUserID(arg any) (uuid.UUID, error) {
+	s, _ := arg.(string)
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid user id %q, must be UUID", s)
+	}
+	return id, nil
+}
+
+func (r *resolver) subscription(p graphql.ResolveParams) (any, error) {
+	if err := authorize(p.Context, auth.PermReadSubscriptions); err != nil {
+		return nil, err
+	}
+
+	id := p.Args["id"].(int)
+	sub, err := r.storage.GetSubscription(p.Context, id)
+	// subscriptions of other users are reported as missing so their ids don't leak
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !auth.CanAccessUser(p.Context, sub.UserID)) {
+		return nil, nil
+	}
+	if err != nil {
+		logging.FromContext(p.Context).Error("failed to get subscription", "error", err, "id", id)
+		return nil, errInternal
+	}
+	return sub, nil
+}
+
+func (r *resolver) subscriptions(p graphql.ResolveParams) (any, error) {
+	if err := authorize(p.Context, auth.PermReadSubscriptions); err != nil {
+		return nil, err
+	}
+
+	page, _ := p.Args["page"].(int)
+	page = max(page, 1)
+	limit, ok := p.Args["limit"].(int)
+	if !ok || limit < 1 {
+		limit = r.cfg.DefaultPageSize
+	}
+	limit = min(limit, r.cfg.MaxPageSize)
+
+	var userID *uuid.UUID
+	if arg, ok := p.Args["userId"]; ok {
+		id, err := parseUserID(arg)
+		if err != nil {
+			return nil, err
+		}
+		userID = &id
+	}
+	if restricted := auth.RestrictedUserID(p.Context); restricted != nil {
+		if userID != nil && *userID != *restricted {
+			return nil, errForbidden
+		}
+		userID = restricted
+	}
+
+	category, _ := p.Args["category"].(string)
+	tag, _ := p.Args["tag"].(string)
+
+	result, err := r.storage.ListAllSubscriptions(p.Context, storage.ListParams{
+		Page:     page,
+		Limit:    limit,
+		UserID:   userID,
+		Category: handler.NormalizeLabel(category),
+		Tag:      handler.NormalizeLabel(tag),
+	})
+	if err != nil {
+		logging.FromContext(p.Context).Error("failed to list subscriptions", "error", err)
+		return nil, errInternal
+	}
+	return pointers(result.Subscriptions), nil
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (any, error) {
+	id, err := parseUserID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if !auth.CanReportOnUser(p.Context, id) {
+		return nil, errForbidden
+	}
+	return loadRecord(p, id), nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (any, error) {
+	args, _ := p.Args["ids"].([]any)
+
+	users := make([]any, len(args))
+	for i, arg := range args {
+		id, err := parseUserID(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !auth.CanReportOnUser(p.Context, id) {
+			return nil, errForbidden
+		}
+		users[i] = loadRecord(p, id)
+	}
+	return users, nil
+}
+
+// subscriptionUser is the owner of a subscription, which always exists as
+// users with subscriptions can't be deleted.
+func (r *resolver) subscriptionUser(p graphql.ResolveParams) (any, error) {
+	return loadRecord(p, p.Source.(*models.Subscription).UserID), nil
+}
+
+// loadRecord loads the record of a user, returning a thunk so the users of
+// a list are loaded in one batch. It resolves to null for unknown users.
+func loadRecord(p graphql.ResolveParams, id uuid.UUID) func() (any, error) {
+	thunk := loadersFrom(p.Context).users.Load(p.Context, id)
+	return func() (any, error) {
+		u, err := thunk()
+		if err != nil {
+			logging.FromContext(p.Context).Error("failed to load user", "error", err, "user_id", id)
+			return nil, errInternal
+		}
+		if u == nil {
+			return nil, nil
+		}
+		return u, nil
+	}
+}
+
+func (r *resolver) totalCost(p graphql.ResolveParams) (any, error) {
+	if err := authorize(p.Context, auth.PermReadReports); err != nil {
+		return nil, err
+	}
+
+	start, end := p.Args["startPeriod"].(string), p.Args["endPeriod"].(string)
+	startPeriod, endPeriod, errs := handler.ParsePeriod(start, end)
+	if errs != nil {
+		return nil, errs
+	}
+
+	params := storage.TotalCostParams{
+		StartPeriod: startPeriod,
+		EndPeriod:   endPeriod,
+	}
+	params.ServiceName, _ = p.Args["serviceName"].(string)
+	category, _ := p.Args["category"].(string)
+	params.Category = handler.NormalizeLabel(category)
+	tag, _ := p.Args["tag"].(string)
+	params.Tag = handler.NormalizeLabel(tag)
+
+	if arg, ok := p.Args["userId"]; ok {
+		userID, err := parseUserID(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !auth.CanReportOnUser(p.Context, userID) {
+			return nil, errForbidden
+		}
+		params.UserID = &userID
+	} else if principal := auth.PrincipalFromContext(p.Context); !principal.Can(auth.PermReadAllReports) {
+		params.UserID = auth.RestrictedUserID(p.Context)
+	}
+
+	subs, err := r.storage.GetSubscriptionsForPeriod(p.Context, params)
+	if err != nil {
+		logging.FromContext(p.Context).Error("failed to get subscriptions", "error", err)
+		return nil, errInternal
+	}
+
+	changes, err := r.storage.GetPriceChanges(p.Context, billing.SubscriptionIDs(subs))
+	if err != nil {
+		logging.FromContext(p.Context).Error("failed to get price changes", "error", err)
+		return nil, errInternal
+	}
+
+	return &totalCost{
+		totalCost:          billing.TotalCost(subs, changes, startPeriod, endPeriod),
+		periodStart:        start,
+		periodEnd:          end,
+		subscriptionsCount: len(subs),
+	}, nil
+}
+
+// loadUser loads the subscriptions of the user a field is resolved on,
+// returning a thunk so the users of a list are loaded in one batch. The
+// caller needs perm, and for reports to be allowed to report on the user.
+func (r *resolver) loadUser(p graphql.ResolveParams, perm auth.Permission, resolve func(userSubscriptions) (any, error)) (any, error) {
+	if err := authorize(p.Context, perm); err != nil {
+		return nil, err
+	}
+
+	u := p.Source.(*models.User)
+	allowed := auth.CanAccessUser(p.Context, u.ID)
+	if perm == auth.PermReadReports {
+		allowed = auth.CanReportOnUser(p.Context, u.ID)
+	}
+	if !allowed {
+		return nil, errForbidden
+	}
+
+	thunk := loadersFrom(p.Context).byUser.Load(p.Context, u.ID)
+	return func() (any, error) {
+		subs, err := thunk()
+		if err != nil {
+			logging.FromContext(p.Context).Error("failed to load subscriptions", "error", err, "user_id", u.ID)
+			return nil, errInternal
+		}
+		return resolve(subs)
+	}, nil
+}
+
+func (r *resolver) userSubscriptions(p graphql.ResolveParams) (any, error) {
+	return r.loadUser(p, auth.PermReadSubscriptions, func(u userSubscriptions) (any, error) {
+		return pointers(u.subscriptions), nil
+	})
+}
+
+func (r *resolver) userActiveSubscriptionsCount(p graphql.ResolveParams) (any, error) {
+	return r.loadUser(p, auth.PermReadSubscriptions, func(u userSubscriptions) (any, error) {
+		month := billing.CurrentMonth()
+		count := 0
+		for _, sub := range u.subscriptions {
+			if !sub.StartDate.After(month) && (sub.EndDate == nil || !sub.EndDate.Before(month)) {
+				count++
+			}
+		}
+		return count, nil
+	})
+}
+
+func (r *resolver) userMonthlyCost(p graphql.ResolveParams) (any, error) {
+	return r.loadUser(p, auth.PermReadReports, func(u userSubscriptions) (any, error) {
+		month := billing.CurrentMonth()
+		return billing.TotalCost(u.subscriptions, u.priceChanges, month, month), nil
+	})
+}
+
+func (r *resolver) userTotalCost(p graphql.ResolveParams) (any, error) {
+	startPeriod, endPeriod, errs := handler.ParsePeriod(p.Args["startPeriod"].(string), p.Args["endPeriod"].(string))
+	if errs != nil {
+		return nil, errs
+	}
+
+	return r.loadUser(p, auth.PermReadReports, func(u userSubscriptions) (any, error) {
+		return billing.TotalCost(u.subscriptions, u.priceChanges, startPeriod, endPeriod), nil
+	})
+}
+
+func (r *resolver) userCostBreakdown(p graphql.ResolveParams) (any, error) {
+	startPeriod, endPeriod, errs := handler.ParsePeriod(p.Args["startPeriod"].(string), p.Args["endPeriod"].(string))
+	if errs != nil {
+		return nil, errs
+	}
+
+	return r.loadUser(p, auth.PermReadReports, func(u userSubscriptions) (any, error) {
+		totals := make(map[string]int)
+		for _, sub := range u.subscriptions {
+			if cost := billing.TotalCost([]models.Subscription{sub}, u.priceChanges, startPeriod, endPeriod); cost > 0 {
+				totals[sub.ServiceName] += cost
+			}
+		}
+
+		costs := make([]*serviceCost, 0, len(totals))
+		for name, total := range totals {
+			costs = append(costs, &serviceCost{serviceName: name, totalCost: total})
+		}
+		sort.Slice(costs, func(i, j int) bool {
+			if costs[i].totalCost != costs[j].totalCost {
+				return costs[i].totalCost > costs[j].totalCost
+			}
+			return costs[i].serviceName < costs[j].serviceName
+		})
+		return costs, nil
+	})
+}
+
+func (r *resolver) userUpcomingRenewals(p graphql.ResolveParams) (any, error) {
+	months, _ := p.Args["months"].(int)
+	if months < 1 || months > 12 {
+		return nil, errors.New("months must be between 1 and 12")
+	}
+
+	return r.loadUser(p, auth.PermReadSubscriptions, func(u userSubscriptions) (any, error) {
+		current := billing.CurrentMonth()
+		until := current.AddDate(0, months, 0)
+
+		renewals := []*renewal{}
+		for i := range u.subscriptions {
+			sub := &u.subscriptions[i]
+			month, price, ok := billing.NextRenewal(sub, u.priceChanges[sub.ID], current)
+			if ok && !month.After(until) {
+				renewals = append(renewals, &renewal{month: month, price: price, subscription: sub})
+			}
+		}
+		sort.SliceStable(renewals, func(i, j int) bool {
+			return renewals[i].month.Before(renewals[j].month)
+		})
+		return renewals, nil
+	})
+}
+
+func (r *resolver) subscriptionPriceChanges(p graphql.ResolveParams) (any, error) {
+	sub := p.Source.(*models.Subscription)
+	thunk := loadersFrom(p.Context).priceChanges.Load(p.Context, sub.ID)
+	return func() (any, error) {
+		changes, err := thunk()
+		if err != nil {
+			logging.FromContext(p.Context).Error("failed to load price changes", "error", err, "subscription_id", sub.ID)
+			return nil, errInternal
+		}
+		return pointers(changes), nil
+	}, nil
+}
+
+func (r *resolver) subscriptionNextRenewal(p graphql.ResolveParams) (any, error) {
+	sub := p.Source.(*models.Subscription)
+	thunk := loadersFrom(p.Context).priceChanges.Load(p.Context, sub.ID)
+	return func() (any, error) {
+		changes, err := thunk()
+		if err != nil {
+			logging.FromContext(p.Context).Error("failed to load price changes", "error", err, "subscription_id", sub.ID)
+			return nil, errInternal
+		}
+
+		month, price, ok := billing.NextRenewal(sub, changes, billing.CurrentMonth())
+		if !ok {
+			return nil, nil
+		}
+		return &renewal{month: month, price: price, subscription: sub}, nil
+	}, nil
+}
+
+// pointers returns pointers to the items of s, the sources fields of list
+// items are resolved on.
+func pointers[T any](s []T) []*T {
+	out := make([]*T, len(s))
+	for i := range s {
+		out[i] = &s[i]
+	}
+	return out
+}
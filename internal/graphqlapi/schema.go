@@ -0,0 +1,281 @@
+package graphqlapi
+
+import (
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/models"
+)
+
+// renewal is the next month a subscription is charged for.
+type renewal struct {
+	month        time.Time
+	price        int
+	subscription *models.Subscription
+}
+
+type serviceCost struct {
+	serviceName string
+	totalCost   int
+}
+
+type totalCost struct {
+	totalCost          int
+	periodStart        string
+	periodEnd          string
+	subscriptionsCount int
+}
+
+// newSchema builds the GraphQL schema with the resolvers of r:
+//
+//	type Query {
+//	  subscription(id: Int!): Subscription
+//	  subscriptions(page: Int = 1, limit: Int, userId: ID, category: String, tag: String): [Subscription!]!
+//	  user(id: ID!): User
+//	  users(ids: [ID!]!): [User]!
+//	  totalCost(startPeriod: String!, endPeriod: String!, userId: ID, serviceName: String, category: String, tag: String): TotalCost!
+//	}
+func newSchema(r *resolver) (graphql.Schema, error) {
+	priceChangeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PriceChange",
+		Description: "A scheduled price, effective from the first day of its month onwards.",
+		Fields: graphql.Fields{
+			"id":            field(graphql.NewNonNull(graphql.Int), func(c *models.PriceChange) any { return c.ID }),
+			"price":         field(graphql.NewNonNull(graphql.Int), func(c *models.PriceChange) any { return c.Price }),
+			"effectiveDate": field(graphql.NewNonNull(graphql.String), func(c *models.PriceChange) any { return c.EffectiveDate.Format(monthLayout) }),
+			"createdAt":     field(graphql.NewNonNull(graphql.DateTime), func(c *models.PriceChange) any { return c.CreatedAt }),
+		},
+	})
+
+	serviceCostType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ServiceCost",
+		Fields: graphql.Fields{
+			"serviceName": field(graphql.NewNonNull(graphql.String), func(c *serviceCost) any { return c.serviceName }),
+			"totalCost":   field(graphql.NewNonNull(graphql.Int), func(c *serviceCost) any { return c.totalCost }),
+		},
+	})
+
+	totalCostType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TotalCost",
+		Fields: graphql.Fields{
+			"totalCost":          field(graphql.NewNonNull(graphql.Int), func(c *totalCost) any { return c.totalCost }),
+			"currency":           field(graphql.NewNonNull(graphql.String), func(*totalCost) any { return "RUB" }),
+			"periodStart":        field(graphql.NewNonNull(graphql.String), func(c *totalCost) any { return c.periodStart }),
+			"periodEnd":          field(graphql.NewNonNull(graphql.String), func(c *totalCost) any { return c.periodEnd }),
+			"subscriptionsCount": field(graphql.NewNonNull(graphql.Int), func(c *totalCost) any { return c.subscriptionsCount }),
+		},
+	})
+
+	// Subscription, User and Renewal refer to each other, so their fields
+	// are added once all of them exist
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "Subscription",
+		Fields: graphql.Fields{},
+	})
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "A user, their subscriptions and what they cost.",
+		Fields:      graphql.Fields{},
+	})
+	renewalType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Renewal",
+		Description: "The next month a subscription is charged for.",
+		Fields: graphql.Fields{
+			"month":        field(graphql.NewNonNull(graphql.String), func(n *renewal) any { return n.month.Format(monthLayout) }),
+			"price":        field(graphql.NewNonNull(graphql.Int), func(n *renewal) any { return n.price }),
+			"subscription": field(graphql.NewNonNull(subscriptionType), func(n *renewal) any { return n.subscription }),
+		},
+	})
+
+	periodArgs := graphql.FieldConfigArgument{
+		"startPeriod": {Type: graphql.NewNonNull(graphql.String), Description: "First month, MM-YYYY"},
+		"endPeriod":   {Type: graphql.NewNonNull(graphql.String), Description: "Last month, MM-YYYY"},
+	}
+
+	subscriptionFields := graphql.Fields{
+		"id":           field(graphql.NewNonNull(graphql.Int), func(s *models.Subscription) any { return s.ID }),
+		"serviceName":  field(graphql.NewNonNull(graphql.String), func(s *models.Subscription) any { return s.ServiceName }),
+		"serviceId":    field(graphql.Int, func(s *models.Subscription) any { return serviceID(s) }),
+		"price":        field(graphql.NewNonNull(graphql.Int), func(s *models.Subscription) any { return s.Price }),
+		"userId":       field(graphql.NewNonNull(graphql.ID), func(s *models.Subscription) any { return s.UserID.String() }),
+		"startDate":    field(graphql.NewNonNull(graphql.String), func(s *models.Subscription) any { return s.StartDate.Format(monthLayout) }),
+		"endDate":      field(graphql.String, func(s *models.Subscription) any { return formatMonth(s.EndDate) }),
+		"trialEndDate": field(graphql.String, func(s *models.Subscription) any { return formatMonth(s.TrialEndDate) }),
+		"category":     field(graphql.NewNonNull(graphql.String), func(s *models.Subscription) any { return s.Category }),
+		"tags":         field(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), func(s *models.Subscription) any { return tags(s) }),
+		"createdAt":    field(graphql.NewNonNull(graphql.DateTime), func(s *models.Subscription) any { return s.CreatedAt }),
+		"updatedAt":    field(graphql.NewNonNull(graphql.DateTime), func(s *models.Subscription) any { return s.UpdatedAt }),
+		"user": {
+			Type:    graphql.NewNonNull(userType),
+			Resolve: r.subscriptionUser,
+		},
+		"priceChanges": {
+			Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(priceChangeType))),
+			Resolve: r.subscriptionPriceChanges,
+		},
+		"nextRenewal": {
+			Type:        renewalType,
+			Description: "The next month after the current one the subscription is charged for, null once it ends.",
+			Resolve:     r.subscriptionNextRenewal,
+		},
+	}
+	for name, f := range subscriptionFields {
+		subscriptionType.AddFieldConfig(name, f)
+	}
+
+	userFields := graphql.Fields{
+		"id":              field(graphql.NewNonNull(graphql.ID), func(u *models.User) any { return u.ID.String() }),
+		"displayName":     profileField(graphql.NewNonNull(graphql.String), func(u *models.User) any { return u.DisplayName }),
+		"email":           profileField(graphql.String, func(u *models.User) any { return email(u) }),
+		"defaultCurrency": profileField(graphql.NewNonNull(graphql.String), func(u *models.User) any { return u.DefaultCurrency }),
+		"timezone":        profileField(graphql.NewNonNull(graphql.String), func(u *models.User) any { return u.Timezone }),
+		"subscriptions": {
+			Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(subscriptionType))),
+			Resolve: r.userSubscriptions,
+		},
+		"activeSubscriptionsCount": {
+			Type:        graphql.NewNonNull(graphql.Int),
+			Description: "Subscriptions running in the current month.",
+			Resolve:     r.userActiveSubscriptionsCount,
+		},
+		"monthlyCost": {
+			Type:        graphql.NewNonNull(graphql.Int),
+			Description: "What the subscriptions cost in the current month.",
+			Resolve:     r.userMonthlyCost,
+		},
+		"totalCost": {
+			Type:    graphql.NewNonNull(graphql.Int),
+			Args:    periodArgs,
+			Resolve: r.userTotalCost,
+		},
+		"costBreakdown": {
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(serviceCostType))),
+			Description: "Cost per service over the period, most expensive first.",
+			Args:        periodArgs,
+			Resolve:     r.userCostBreakdown,
+		},
+		"upcomingRenewals": {
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(renewalType))),
+			Description: "Subscriptions charged again within the next months, soonest first.",
+			Args: graphql.FieldConfigArgument{
+				"months": {Type: graphql.Int, DefaultValue: 1, Description: "How many months ahead to look, 1 to 12"},
+			},
+			Resolve: r.userUpcomingRenewals,
+		},
+	}
+	for name, f := range userFields {
+		userType.AddFieldConfig(name, f)
+	}
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"subscription": {
+				Type:    subscriptionType,
+				Args:    graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve: r.subscription,
+			},
+			"subscriptions": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(subscriptionType))),
+				Description: "Subscriptions, newest first.",
+				Args: graphql.FieldConfigArgument{
+					"page":     {Type: graphql.Int, DefaultValue: 1},
+					"limit":    {Type: graphql.Int, Description: "Subscriptions per page, the server's default page size when omitted"},
+					"userId":   {Type: graphql.ID},
+					"category": {Type: graphql.String},
+					"tag":      {Type: graphql.String},
+				},
+				Resolve: r.subscriptions,
+			},
+			"user": {
+				Type:        userType,
+				Description: "The user of id, null when there is none.",
+				Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve:     r.user,
+			},
+			"users": {
+				Type:        graphql.NewNonNull(graphql.NewList(userType)),
+				Description: "The users of ids in the same order, null for those there are none of.",
+				Args:        graphql.FieldConfigArgument{"ids": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))}},
+				Resolve:     r.users,
+			},
+			"totalCost": {
+				Type: graphql.NewNonNull(totalCostType),
+				Args: graphql.FieldConfigArgument{
+					"startPeriod": periodArgs["startPeriod"],
+					"endPeriod":   periodArgs["endPeriod"],
+					"userId":      {Type: graphql.ID},
+					"serviceName": {Type: graphql.String},
+					"category":    {Type: graphql.String},
+					"tag":         {Type: graphql.String},
+				},
+				Resolve: r.totalCost,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// field is a field read from its source, a *T, without arguments.
+func field[T any](typ graphql.Output, get func(*T) any) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return get(p.Source.(*T)), nil
+		},
+	}
+}
+
+// profileField is a field of a user's record, which needs the same
+// permission as reading the user over the REST API.
+func profileField(typ graphql.Output, get func(*models.User) any) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			if err := authorize(p.Context, auth.PermReadUsers); err != nil {
+				return nil, err
+			}
+			u := p.Source.(*models.User)
+			if !auth.CanAccessUser(p.Context, u.ID) {
+				return nil, errForbidden
+			}
+			return get(u), nil
+		},
+	}
+}
+
+const monthLayout = "01-2006"
+
+func formatMonth(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format(monthLayout)
+}
+
+// email keeps users without an email at null rather than a typed nil.
+func email(u *models.User) any {
+	if u.Email == nil {
+		return nil
+	}
+	return *u.Email
+}
+
+// tags lists subscriptions without tags as an empty list rather than null.
+func tags(s *models.Subscription) []string {
+	if s.Tags == nil {
+		return []string{}
+	}
+	return s.Tags
+}
+
+// serviceID keeps subscriptions outside the catalog at null rather than a
+// typed nil, which graphql-go would fail to serialize.
+func serviceID(s *models.Subscription) any {
+	if s.ServiceID == nil {
+		return nil
+	}
+	return *s.ServiceID
+}
@@ -0,0 +1,214 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/ratelimit"
+	"github.com/seeques/subman/internal/tenant"
+	submanv1 "github.com/seeques/subman/proto/subman/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// requestIDKey is the metadata key of a caller supplied request ID, the
+// same header middleware.RequestID reads over HTTP.
+const requestIDKey = "x-request-id"
+
+// logRequests injects a logger carrying the request ID into the call
+// context and writes an access log once the call is done, like
+// logging.Middleware does for HTTP.
+func logRequests(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	requestID := firstMetadata(ctx, requestIDKey)
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+
+	logger := slog.Default().With(
+		"request_id", requestID,
+		"rpc", info.FullMethod,
+	)
+	ctx = logging.NewContext(ctx, logger)
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+
+	logging.FromContext(ctx).Log(ctx, level, "request completed",
+		"code", code.String(),
+		"duration_ms", float64(time.Since(start).Microseconds())/1000,
+		"remote_addr", remoteAddr,
+	)
+	return resp, err
+}
+
+// recoverPanics turns a panicking call into an internal error instead of
+// taking the server down.
+func recoverPanics(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logging.FromContext(ctx).Error("panic in gRPC call", "panic", p, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// authenticate resolves the bearer token and tenant of calls to the
+// subscription service like auth.Authenticator.Middleware and ResolveTenant
+// do for HTTP. Health checks and reflection are open.
+func authenticate(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, "/subman.") {
+			return handler(ctx, req)
+		}
+
+		scheme, token, ok := strings.Cut(firstMetadata(ctx, "authorization"), " ")
+		token = strings.TrimSpace(token)
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+
+		principal, err := authenticator.Authenticate(ctx, token)
+		if errors.Is(err, auth.ErrInvalidKey) {
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			logging.FromContext(ctx).Info("rejected bearer token", "error", err)
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to authenticate request", "error", err)
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		logging.With(ctx, "principal", principal.ID)
+
+		tenantID, err := authenticator.TenantOf(ctx, principal, firstMetadata(ctx, strings.ToLower(tenant.Header)))
+		switch {
+		case errors.Is(err, auth.ErrInvalidTenant):
+			return nil, status.Error(codes.InvalidArgument, "invalid x-tenant-id metadata")
+		case errors.Is(err, auth.ErrForeignTenant):
+			return nil, status.Error(codes.PermissionDenied, "access to other tenants is not allowed")
+		case errors.Is(err, auth.ErrTenantNotFound):
+			return nil, status.Error(codes.NotFound, "tenant not found")
+		case err != nil:
+			logging.FromContext(ctx).Error("failed to resolve tenant", "error", err, "principal", principal.ID)
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		logging.With(ctx, "tenant_id", tenantID)
+
+		ctx = tenant.WithTenant(auth.WithPrincipal(ctx, principal), tenantID)
+		return handler(ctx, req)
+	}
+}
+
+// methodGroups maps calls to the rate limit group of the matching REST
+// routes, any other call is in ratelimit.GroupDefault.
+var methodGroups = map[string]string{
+	submanv1.SubscriptionService_TotalCost_FullMethodName: ratelimit.GroupReports,
+}
+
+// limitUnauthenticated limits calls to the subscription service per client
+// IP before authenticate, so calls with missing or invalid credentials are
+// limited too.
+func limitUnauthenticated(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, "/subman.") {
+			return handler(ctx, req)
+		}
+		if err := takeToken(ctx, limiter, ratelimit.GroupAuth); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// limitRequests limits calls to the subscription service per caller like
+// ratelimit.Limiter.Middleware does for HTTP, sharing the buckets of the
+// REST API. It runs after authenticate to tell callers apart.
+func limitRequests(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, "/subman.") {
+			return handler(ctx, req)
+		}
+
+		group, ok := methodGroups[info.FullMethod]
+		if !ok {
+			group = ratelimit.GroupDefault
+		}
+		if err := takeToken(ctx, limiter, group); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// takeToken fails with ResourceExhausted, carrying the delay as RetryInfo,
+// when the caller of ctx is over the limit of group.
+func takeToken(ctx context.Context, limiter *ratelimit.Limiter, group string) error {
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	client := ratelimit.ClientKey(ctx, remoteAddr)
+
+	res, err := limiter.Take(ctx, group, client)
+	if err != nil {
+		// an unavailable store shouldn't take the API down with it
+		logging.FromContext(ctx).Error("failed to check rate limit", "error", err, "group", group)
+		return nil
+	}
+	if res.Allowed {
+		return nil
+	}
+
+	logging.FromContext(ctx).Warn("rate limit exceeded", "group", group, "client", client, "limit", res.Limit.String())
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry in %d seconds", int(math.Ceil(res.RetryAfter.Seconds()))))
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// authorize consults the access policy for the caller of ctx, failing with
+// PermissionDenied when it lacks perm.
+func authorize(ctx context.Context, perm auth.Permission) error {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil || !principal.Can(perm) {
+		return status.Error(codes.PermissionDenied, "missing permission: "+string(perm))
+	}
+	return nil
+}
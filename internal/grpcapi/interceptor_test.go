@@ -0,0 +1,103 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/ratelimit"
+	submanv1 "github.com/seeques/subman/proto/subman/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func callFrom(addr string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 40000},
+	})
+}
+
+func call(interceptor grpc.UnaryServerInterceptor, ctx context.Context, method string) error {
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	return err
+}
+
+func TestLimitRequests(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+		ratelimit.GroupDefault: {Requests: 1, Period: time.Minute},
+		ratelimit.GroupReports: {Requests: 1, Period: 10 * time.Second},
+	})
+	interceptor := limitRequests(limiter)
+
+	ctx := auth.WithPrincipal(callFrom("203.0.113.7"), &auth.Principal{ID: "apikey:1"})
+	list := submanv1.SubscriptionService_ListSubscriptions_FullMethodName
+	totalCost := submanv1.SubscriptionService_TotalCost_FullMethodName
+
+	if err := call(interceptor, ctx, list); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	// reports are limited separately
+	if err := call(interceptor, ctx, totalCost); err != nil {
+		t.Fatalf("first report: %v", err)
+	}
+
+	tests := []struct {
+		method    string
+		wantDelay time.Duration
+	}{
+		{list, time.Minute},
+		{totalCost, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		err := call(interceptor, ctx, tt.method)
+		st := status.Convert(err)
+		if st.Code() != codes.ResourceExhausted {
+			t.Fatalf("%s: code = %s, want ResourceExhausted", tt.method, st.Code())
+		}
+
+		var delay time.Duration
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				delay = info.GetRetryDelay().AsDuration()
+			}
+		}
+		if diff := delay - tt.wantDelay; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Errorf("%s: retry delay = %s, want %s", tt.method, delay, tt.wantDelay)
+		}
+	}
+
+	// health checks are not limited
+	if err := call(interceptor, ctx, "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check: %v", err)
+	}
+	// another caller has buckets of its own
+	other := auth.WithPrincipal(callFrom("203.0.113.7"), &auth.Principal{ID: "apikey:2"})
+	if err := call(interceptor, other, list); err != nil {
+		t.Errorf("other caller: %v", err)
+	}
+}
+
+func TestLimitUnauthenticated(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+		ratelimit.GroupAuth: {Requests: 1, Period: time.Minute},
+	})
+	interceptor := limitUnauthenticated(limiter)
+	list := submanv1.SubscriptionService_ListSubscriptions_FullMethodName
+
+	if err := call(interceptor, callFrom("203.0.113.7"), list); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if err := call(interceptor, callFrom("203.0.113.7"), list); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second call: %v, want ResourceExhausted", err)
+	}
+	if err := call(interceptor, callFrom("198.51.100.1"), list); err != nil {
+		t.Errorf("other client: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Package grpcapi serves the subscription API over gRPC, next to the REST
+// API of the api package and sharing its authentication, validation and
+// storage.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=../../proto --go_opt=paths=source_relative --go-grpc_out=../../proto --go-grpc_opt=paths=source_relative subman/v1/subscription.proto
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/budget"
+	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/ratelimit"
+	"github.com/seeques/subman/internal/storage"
+	submanv1 "github.com/seeques/subman/proto/subman/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server is the gRPC server. It answers the standard health checking
+// protocol and server reflection without authentication.
+type Server struct {
+	grpcServer *grpc.Server
+	health     *health.Server
+	cfg        config.Config
+}
+
+func NewServer(storage *storage.PostgresStorage, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, cfg config.Config) *Server {
+	s := &Server{
+		grpcServer: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(
+				logRequests,
+				recoverPanics,
+				limitUnauthenticated(limiter),
+				authenticate(authenticator),
+				limitRequests(limiter),
+			),
+		),
+		health: health.NewServer(),
+		cfg:    cfg,
+	}
+
+	submanv1.RegisterSubscriptionServiceServer(s.grpcServer, &subscriptionService{
+		storage: storage,
+		budgets: budget.NewEvaluator(storage),
+		cfg:     cfg,
+	})
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+	reflection.Register(s.grpcServer)
+
+	s.health.SetServingStatus(submanv1.SubscriptionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+func (s *Server) Run() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.GRPCPort))
+	if err != nil {
+		return err
+	}
+
+	slog.Info("starting gRPC server", "port", s.cfg.GRPCPort)
+
+	return s.grpcServer.Serve(listener)
+}
+
+// Drain reports every service as not serving, so clients checking health
+// move to other instances before the server stops.
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Shutdown waits for running calls to finish, cancelling them when ctx is
+// done first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	slog.Info("shutting down gRPC server")
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
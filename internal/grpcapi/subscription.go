@@ -0,0 +1,325 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/budget"
+	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/handler"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+	submanv1 "github.com/seeques/subman/proto/subman/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// subscriptionService implements the same operations as the subscription
+// handlers, with the same permissions and validation.
+type subscriptionService struct {
+	submanv1.UnimplementedSubscriptionServiceServer
+
+	storage *storage.PostgresStorage
+	budgets *budget.Evaluator
+	cfg     config.Config
+}
+
+var errNotFound = status.Error(codes.NotFound, "subscription not found")
+
+func internalError() error {
+	return status.Error(codes.Internal, "internal error")
+}
+
+// invalidArgument reports every invalid field, as a BadRequest detail for
+// clients that read details and in the message for those that don't.
+func invalidArgument(errs response.FieldErrors) error {
+	st := status.New(codes.InvalidArgument, errs.Error())
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(errs))
+	for i, fe := range errs {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       fe.Field,
+			Description: fe.Message,
+			Reason:      strings.ToUpper(string(fe.Code)),
+		}
+	}
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// errUnknownUser is returned for subscriptions of users that don't exist.
+var errUnknownUser = invalidArgument(response.FieldErrors{
+	{Field: "user_id", Code: response.CodeUnknown, Message: "user not found"},
+})
+
+// parseInput validates a subscription like the REST API does.
+func parseInput(ctx context.Context, input *submanv1.SubscriptionInput) (*models.Subscription, error) {
+	sub, errs := handler.ParseSubscriptionRequest(handler.SubscriptionRequest{
+		ServiceName:  input.GetServiceName(),
+		Price:        int(input.GetPrice()),
+		UserID:       input.GetUserId(),
+		StartDate:    input.GetStartDate(),
+		EndDate:      input.GetEndDate(),
+		TrialEndDate: input.GetTrialEndDate(),
+		Category:     input.GetCategory(),
+		Tags:         input.GetTags(),
+	})
+	if errs != nil {
+		return nil, invalidArgument(errs)
+	}
+
+	if !auth.CanAccessUser(ctx, sub.UserID) {
+		return nil, status.Error(codes.PermissionDenied, "access to other users is not allowed")
+	}
+	return sub, nil
+}
+
+// getOwned returns the subscription id when the caller may access it.
+// Subscriptions of other users are reported as missing so their ids don't
+// leak.
+func (s *subscriptionService) getOwned(ctx context.Context, id int64) (*models.Subscription, error) {
+	sub, err := s.storage.GetSubscription(ctx, int(id))
+	if err == nil && !auth.CanAccessUser(ctx, sub.UserID) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errNotFound
+		}
+		logging.FromContext(ctx).Error("failed to get subscription", "error", err, "id", id)
+		return nil, internalError()
+	}
+	return sub, nil
+}
+
+// evaluateBudgets checks the budgets of a user after their spend changed,
+// only logging failures.
+func (s *subscriptionService) evaluateBudgets(ctx context.Context, userID uuid.UUID) {
+	if err := s.budgets.EvaluateUser(ctx, &userID); err != nil {
+		logging.FromContext(ctx).Error("failed to evaluate budgets", "error", err, "user_id", userID)
+	}
+}
+
+func (s *subscriptionService) CreateSubscription(ctx context.Context, req *submanv1.CreateSubscriptionRequest) (*submanv1.Subscription, error) {
+	if err := authorize(ctx, auth.PermWriteSubscriptions); err != nil {
+		return nil, err
+	}
+
+	sub, err := parseInput(ctx, req.GetSubscription())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.CreateSubscription(ctx, sub); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, errUnknownUser
+		}
+		logging.FromContext(ctx).Error("failed to create subscription", "error", err, "service_name", sub.ServiceName)
+		return nil, internalError()
+	}
+
+	logging.FromContext(ctx).Info("subscription created", "subscription_id", sub.ID, "service_name", sub.ServiceName)
+
+	s.evaluateBudgets(ctx, sub.UserID)
+
+	return toSubscription(sub), nil
+}
+
+func (s *subscriptionService) GetSubscription(ctx context.Context, req *submanv1.GetSubscriptionRequest) (*submanv1.Subscription, error) {
+	if err := authorize(ctx, auth.PermReadSubscriptions); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.getOwned(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return toSubscription(sub), nil
+}
+
+func (s *subscriptionService) UpdateSubscription(ctx context.Context, req *submanv1.UpdateSubscriptionRequest) (*submanv1.Subscription, error) {
+	if err := authorize(ctx, auth.PermWriteSubscriptions); err != nil {
+		return nil, err
+	}
+
+	sub, err := parseInput(ctx, req.GetSubscription())
+	if err != nil {
+		return nil, err
+	}
+
+	// Restricted callers may only update their own subscriptions
+	if _, err := s.getOwned(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	sub.ID = int(req.GetId())
+	if err := s.storage.UpdateSubscription(ctx, sub); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errNotFound
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, errUnknownUser
+		}
+		logging.FromContext(ctx).Error("failed to update subscription", "error", err, "id", sub.ID)
+		return nil, internalError()
+	}
+
+	logging.FromContext(ctx).Info("subscription updated", "id", sub.ID)
+
+	s.evaluateBudgets(ctx, sub.UserID)
+
+	return toSubscription(sub), nil
+}
+
+func (s *subscriptionService) DeleteSubscription(ctx context.Context, req *submanv1.DeleteSubscriptionRequest) (*emptypb.Empty, error) {
+	if err := authorize(ctx, auth.PermWriteSubscriptions); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getOwned(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.storage.DeleteSubscription(ctx, int(req.GetId())); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errNotFound
+		}
+		logging.FromContext(ctx).Error("failed to delete subscription", "error", err, "id", req.GetId())
+		return nil, internalError()
+	}
+
+	logging.FromContext(ctx).Info("subscription deleted", "id", req.GetId())
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *subscriptionService) ListSubscriptions(ctx context.Context, req *submanv1.ListSubscriptionsRequest) (*submanv1.ListSubscriptionsResponse, error) {
+	if err := authorize(ctx, auth.PermReadSubscriptions); err != nil {
+		return nil, err
+	}
+
+	page := int(req.GetPage())
+	if page < 1 {
+		page = 1
+	}
+	limit := int(req.GetLimit())
+	if limit < 1 {
+		limit = s.cfg.DefaultPageSize
+	}
+	if limit > s.cfg.MaxPageSize {
+		limit = s.cfg.MaxPageSize
+	}
+
+	result, err := s.storage.ListAllSubscriptions(ctx, storage.ListParams{
+		Page:     page,
+		Limit:    limit,
+		UserID:   auth.RestrictedUserID(ctx),
+		Category: handler.NormalizeLabel(req.GetCategory()),
+		Tag:      handler.NormalizeLabel(req.GetTag()),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to list subscriptions", "error", err)
+		return nil, internalError()
+	}
+
+	resp := &submanv1.ListSubscriptionsResponse{
+		Subscriptions: make([]*submanv1.Subscription, len(result.Subscriptions)),
+		Page:          int32(page),
+		Limit:         int32(limit),
+		Total:         int32(result.Total),
+		TotalPages:    int32((result.Total + limit - 1) / limit),
+	}
+	for i := range result.Subscriptions {
+		resp.Subscriptions[i] = toSubscription(&result.Subscriptions[i])
+	}
+	return resp, nil
+}
+
+func (s *subscriptionService) TotalCost(ctx context.Context, req *submanv1.TotalCostRequest) (*submanv1.TotalCostResponse, error) {
+	if err := authorize(ctx, auth.PermReadReports); err != nil {
+		return nil, err
+	}
+
+	startPeriod, endPeriod, errs := handler.ParsePeriod(req.GetStartPeriod(), req.GetEndPeriod())
+	if errs != nil {
+		return nil, invalidArgument(errs)
+	}
+
+	params := storage.TotalCostParams{
+		StartPeriod: startPeriod,
+		EndPeriod:   endPeriod,
+		ServiceName: req.GetServiceName(),
+		Category:    handler.NormalizeLabel(req.GetCategory()),
+		Tag:         handler.NormalizeLabel(req.GetTag()),
+	}
+
+	if req.GetUserId() != "" {
+		userID, err := uuid.Parse(req.GetUserId())
+		if err != nil {
+			return nil, invalidArgument(response.FieldErrors{{Field: "user_id", Code: response.CodeInvalid, Message: "invalid user_id, must be UUID"}})
+		}
+		if !auth.CanReportOnUser(ctx, userID) {
+			return nil, status.Error(codes.PermissionDenied, "access to other users is not allowed")
+		}
+		params.UserID = &userID
+	} else if principal := auth.PrincipalFromContext(ctx); !principal.Can(auth.PermReadAllReports) {
+		params.UserID = auth.RestrictedUserID(ctx)
+	}
+
+	subs, err := s.storage.GetSubscriptionsForPeriod(ctx, params)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get subscriptions", "error", err)
+		return nil, internalError()
+	}
+
+	changes, err := s.storage.GetPriceChanges(ctx, billing.SubscriptionIDs(subs))
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get price changes", "error", err)
+		return nil, internalError()
+	}
+
+	total := billing.TotalCost(subs, changes, startPeriod, endPeriod)
+
+	return &submanv1.TotalCostResponse{
+		TotalCost:          int64(total),
+		Currency:           "RUB",
+		PeriodStart:        req.GetStartPeriod(),
+		PeriodEnd:          req.GetEndPeriod(),
+		SubscriptionsCount: int32(len(subs)),
+	}, nil
+}
+
+func toSubscription(sub *models.Subscription) *submanv1.Subscription {
+	resp := &submanv1.Subscription{
+		Id:          int64(sub.ID),
+		ServiceName: sub.ServiceName,
+		Price:       int64(sub.Price),
+		UserId:      sub.UserID.String(),
+		StartDate:   sub.StartDate.Format("01-2006"),
+		Category:    sub.Category,
+		Tags:        sub.Tags,
+		CreatedAt:   timestamppb.New(sub.CreatedAt),
+		UpdatedAt:   timestamppb.New(sub.UpdatedAt),
+	}
+	if sub.EndDate != nil {
+		resp.EndDate = sub.EndDate.Format("01-2006")
+	}
+	if sub.TrialEndDate != nil {
+		resp.TrialEndDate = sub.TrialEndDate.Format("01-2006")
+	}
+	if sub.ServiceID != nil {
+		resp.ServiceId = int64(*sub.ServiceID)
+	}
+	return resp
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/response"
+)
+
+// scopeUserFilter narrows an optional user filter to the caller's own user
+// when it is restricted. Asking for another user's data writes a 403 and
+// returns false.
+func scopeUserFilter(w http.ResponseWriter, r *http.Request, requested *uuid.UUID) (*uuid.UUID, bool) {
+	restricted := auth.RestrictedUserID(r.Context())
+	if restricted == nil {
+		return requested, true
+	}
+
+	if requested != nil && *requested != *restricted {
+		response.RespondError(w, r, http.StatusForbidden, response.CodeForeignUser, "access to other users is not allowed")
+		return nil, false
+	}
+	return restricted, true
+}
+
+// scopeReportFilter is scopeUserFilter for reports. Callers allowed to read
+// the reports of every user, such as finance, are not narrowed.
+func scopeReportFilter(w http.ResponseWriter, r *http.Request, requested *uuid.UUID) (*uuid.UUID, bool) {
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil && principal.Can(auth.PermReadAllReports) {
+		return requested, true
+	}
+	return scopeUserFilter(w, r, requested)
+}
+
+// ownsSubscription reports whether the subscription exists and the caller
+// may access it, writing a 404 or 500 otherwise. Subscriptions of other
+// users are reported as missing so their ids don't leak.
+func (h *Handler) ownsSubscription(w http.ResponseWriter, r *http.Request, id int) bool {
+	sub, err := h.storage.GetSubscription(r.Context(), id)
+	if err == nil && !auth.CanAccessUser(r.Context(), sub.UserID) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeSubscriptionNotFound, "subscription not found")
+			return false
+		}
+		logging.FromContext(r.Context()).Error("failed to get subscription", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return false
+	}
+	return true
+}
+
+// ownsBudget is the budget counterpart of ownsSubscription.
+func (h *Handler) ownsBudget(w http.ResponseWriter, r *http.Request, id int) bool {
+	budget, err := h.storage.GetBudget(r.Context(), id)
+	if err == nil && !auth.CanAccessUser(r.Context(), budget.UserID) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeBudgetNotFound, "budget not found")
+			return false
+		}
+		logging.FromContext(r.Context()).Error("failed to get budget", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return false
+	}
+	return true
+}
+
+// authorize consults the access policy for the caller of r, writing a 403
+// when it lacks perm.
+func authorize(w http.ResponseWriter, r *http.Request, perm auth.Permission) bool {
+	principal := auth.PrincipalFromContext(r.Context())
+	if principal == nil || !principal.Can(perm) {
+		response.RespondError(w, r, http.StatusForbidden, response.CodePermissionDenied, "missing permission: "+string(perm))
+		return false
+	}
+	return true
+}
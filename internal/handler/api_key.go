@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+)
+
+// CreateAPIKey godoc
+// @Summary Issue an API key
+// @Description Issue a new API key with the given scopes (read, write, reports, admin). The key is only returned once.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param input body APIKeyRequest true "API key data"
+// @Success 201 {object} APIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/api-keys [post]
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageAPIKeys) {
+		return
+	}
+
+	var req APIKeyRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !auth.IsScope(scope) {
+			errs.Add("scopes", response.CodeUnknown, "unknown scope: "+scope)
+		}
+	}
+
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	plain, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to generate API key", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	key := &models.APIKey{
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  req.Scopes,
+	}
+
+	if err := h.storage.CreateAPIKey(r.Context(), key); err != nil {
+		logging.FromContext(r.Context()).Error("failed to create API key", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to create API key")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("API key issued", "api_key_id", key.ID, "name", key.Name, "scopes", key.Scopes)
+
+	resp := toAPIKeyResponse(key)
+	resp.Key = plain
+	response.RespondJSON(w, http.StatusCreated, resp)
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description List all issued API keys including revoked ones. Secrets are never returned.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} APIKeyResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/api-keys [get]
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageAPIKeys) {
+		return
+	}
+
+	keys, err := h.storage.ListAPIKeys(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list API keys", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list API keys")
+		return
+	}
+
+	data := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		data[i] = toAPIKeyResponse(&key)
+	}
+
+	response.RespondJSON(w, http.StatusOK, data)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Tags admin
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageAPIKeys) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	key, err := h.storage.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeAPIKeyNotFound, "active API key not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to revoke API key", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("API key revoked", "api_key_id", id)
+
+	response.RespondJSON(w, http.StatusOK, toAPIKeyResponse(key))
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate an API key
+// @Description Replace the secret of an API key, keeping its name and scopes. The old key stops working immediately; the new one is only returned once.
+// @Tags admin
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/api-keys/{id}/rotate [post]
+func (h *Handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageAPIKeys) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	plain, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to generate API key", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	key, err := h.storage.RotateAPIKey(r.Context(), id, prefix, hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeAPIKeyNotFound, "active API key not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to rotate API key", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("API key rotated", "api_key_id", id)
+
+	resp := toAPIKeyResponse(key)
+	resp.Key = plain
+	response.RespondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// parseBudgetRequest adds the checks the validate tags of req can't express
+// to errs, the invalid fields found while decoding, and turns req into a
+// budget model when no field is invalid.
+func parseBudgetRequest(req BudgetRequest, errs response.FieldErrors) (*models.Budget, response.FieldErrors) {
+	// All prices are stored in RUB, so budgets in other currencies can't be evaluated yet
+	if req.Currency == "" {
+		req.Currency = "RUB"
+	}
+	if req.Currency != "RUB" {
+		errs.Add("currency", response.CodeInvalid, "only RUB currency is supported")
+	}
+
+	if len(req.Thresholds) == 0 {
+		req.Thresholds = []int{80, 100}
+	}
+	seen := make(map[int]bool, len(req.Thresholds))
+	for _, threshold := range req.Thresholds {
+		if threshold <= 0 || threshold > 1000 {
+			errs.Add("thresholds", response.CodeOutOfRange, "thresholds must be percentages between 1 and 1000")
+			break
+		}
+		// each threshold is recorded as a breach once, so a repeated one would be recorded twice
+		if seen[threshold] {
+			errs.Add("thresholds", response.CodeInvalid, "thresholds must not repeat")
+			break
+		}
+		seen[threshold] = true
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+	return &models.Budget{
+		UserID:     uuid.MustParse(req.UserID),
+		Period:     req.Period,
+		Limit:      req.Limit,
+		Currency:   req.Currency,
+		Thresholds: req.Thresholds,
+	}, nil
+}
+
+// evaluateBudgets checks the budgets of a user after their spend changed.
+// Failures are only logged so they never fail the triggering request.
+func (h *Handler) evaluateBudgets(ctx context.Context, userID uuid.UUID) {
+	if err := h.budgets.EvaluateUser(ctx, &userID); err != nil {
+		logging.FromContext(ctx).Error("failed to evaluate budgets", "error", err, "user_id", userID)
+	}
+}
+
+// CreateBudget godoc
+// @Summary Create a budget
+// @Description Create a monthly or yearly spending limit for a user with alert thresholds in percent
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param input body BudgetRequest true "Budget data"
+// @Success 201 {object} BudgetResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /budgets [post]
+func (h *Handler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteBudgets) {
+		return
+	}
+
+	var req BudgetRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	budget, errs := parseBudgetRequest(req, errs)
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+	if !auth.CanAccessUser(r.Context(), budget.UserID) {
+		response.RespondError(w, r, http.StatusForbidden, response.CodeForeignUser, "access to other users is not allowed")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.storage.CreateBudget(ctx, budget); err != nil {
+		logging.FromContext(r.Context()).Error("failed to create budget", "error", err, "user_id", req.UserID)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to create budget")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("budget created", "budget_id", budget.ID, "user_id", budget.UserID)
+
+	if err := h.budgets.Evaluate(ctx, budget); err != nil {
+		logging.FromContext(r.Context()).Error("failed to evaluate budget", "error", err, "budget_id", budget.ID)
+	}
+
+	response.RespondJSON(w, http.StatusCreated, toBudgetResponse(budget))
+}
+
+// GetBudget godoc
+// @Summary Get a budget by ID
+// @Tags budgets
+// @Produce json
+// @Param id path int true "Budget ID"
+// @Success 200 {object} BudgetResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /budgets/{id} [get]
+func (h *Handler) GetBudget(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadBudgets) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	budget, err := h.storage.GetBudget(r.Context(), id)
+	if err == nil && !auth.CanAccessUser(r.Context(), budget.UserID) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeBudgetNotFound, "budget not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to get budget", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	response.RespondJSON(w, http.StatusOK, toBudgetResponse(budget))
+}
+
+// ListBudgets godoc
+// @Summary List budgets
+// @Description Get a paginated list of budgets, optionally for a single user
+// @Tags budgets
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10) maximum(100)
+// @Param user_id query string false "Filter by user ID (UUID)"
+// @Success 200 {object} BudgetListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /budgets [get]
+func (h *Handler) ListBudgets(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadBudgets) {
+		return
+	}
+
+	page, limit := h.pagination(r)
+
+	params := storage.BudgetListParams{
+		Page:  page,
+		Limit: limit,
+	}
+
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			response.RespondFieldError(w, r, "user_id", response.CodeInvalid, "invalid user_id, must be UUID")
+			return
+		}
+		params.UserID = &userID
+	}
+
+	var ok bool
+	if params.UserID, ok = scopeUserFilter(w, r, params.UserID); !ok {
+		return
+	}
+
+	result, err := h.storage.ListBudgets(r.Context(), params)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list budgets", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list budgets")
+		return
+	}
+
+	data := make([]BudgetResponse, len(result.Budgets))
+	for i, budget := range result.Budgets {
+		data[i] = toBudgetResponse(&budget)
+	}
+
+	response.RespondJSON(w, http.StatusOK, BudgetListResponse{
+		Data: data,
+		Meta: ListMeta{
+			Page:       page,
+			Limit:      limit,
+			Total:      result.Total,
+			TotalPages: (result.Total + limit - 1) / limit,
+		},
+	})
+}
+
+// UpdateBudget godoc
+// @Summary Update a budget
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param id path int true "Budget ID"
+// @Param input body BudgetRequest true "Updated budget data"
+// @Success 200 {object} BudgetResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /budgets/{id} [put]
+func (h *Handler) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteBudgets) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	var req BudgetRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	budget, errs := parseBudgetRequest(req, errs)
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+	budget.ID = id
+
+	if !h.ownsBudget(w, r, id) {
+		return
+	}
+	if !auth.CanAccessUser(r.Context(), budget.UserID) {
+		response.RespondError(w, r, http.StatusForbidden, response.CodeForeignUser, "access to other users is not allowed")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.storage.UpdateBudget(ctx, budget); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeBudgetNotFound, "budget not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to update budget", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("budget updated", "id", budget.ID)
+
+	if err := h.budgets.Evaluate(ctx, budget); err != nil {
+		logging.FromContext(r.Context()).Error("failed to evaluate budget", "error", err, "budget_id", budget.ID)
+	}
+
+	response.RespondJSON(w, http.StatusOK, toBudgetResponse(budget))
+}
+
+// DeleteBudget godoc
+// @Summary Delete a budget
+// @Tags budgets
+// @Param id path int true "Budget ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /budgets/{id} [delete]
+func (h *Handler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteBudgets) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	if !h.ownsBudget(w, r, id) {
+		return
+	}
+
+	if err := h.storage.DeleteBudget(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeBudgetNotFound, "budget not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to delete budget", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("budget deleted", "id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BudgetStatus godoc
+// @Summary Get budget status
+// @Description Get the spend of the current budget period, the thresholds reached and all recorded breaches
+// @Tags budgets
+// @Produce json
+// @Param id path int true "Budget ID"
+// @Success 200 {object} BudgetStatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /budgets/{id}/status [get]
+func (h *Handler) BudgetStatus(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadReports) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	ctx := r.Context()
+	budget, err := h.storage.GetBudget(ctx, id)
+	if err == nil && !auth.CanReportOnUser(ctx, budget.UserID) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeBudgetNotFound, "budget not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to get budget", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	status, err := h.budgets.Status(ctx, budget)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to get budget status", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	breaches, err := h.storage.ListBudgetBreaches(ctx, id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list budget breaches", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	breachData := make([]BudgetBreachResponse, len(breaches))
+	for i, breach := range breaches {
+		breachData[i] = BudgetBreachResponse{
+			Threshold:   breach.Threshold,
+			PeriodStart: breach.PeriodStart.Format("01-2006"),
+			Spent:       breach.Spent,
+			Limit:       breach.Limit,
+			CreatedAt:   breach.CreatedAt,
+		}
+	}
+
+	reached := status.Reached
+	if reached == nil {
+		reached = []int{}
+	}
+
+	response.RespondJSON(w, http.StatusOK, BudgetStatusResponse{
+		BudgetID:          budget.ID,
+		Period:            budget.Period,
+		PeriodStart:       status.PeriodStart.Format("01-2006"),
+		PeriodEnd:         status.PeriodEnd.Format("01-2006"),
+		Limit:             budget.Limit,
+		Spent:             status.Spent,
+		Remaining:         budget.Limit - status.Spent,
+		PercentUsed:       float64(status.Spent) * 100 / float64(budget.Limit),
+		Currency:          budget.Currency,
+		ReachedThresholds: reached,
+		Breaches:          breachData,
+	})
+}
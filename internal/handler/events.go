@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/tenant"
+)
+
+const (
+	streamHeartbeat   = 15 * time.Second
+	streamReplayBatch = 500
+)
+
+var changeEventTypes = map[string]string{
+	"INSERT": events.SubscriptionCreated,
+	"UPDATE": events.SubscriptionUpdated,
+	"DELETE": events.SubscriptionDeleted,
+}
+
+// writeChangeEvent writes a change as a server-sent event whose id is the
+// change id clients pass back in Last-Event-ID.
+func writeChangeEvent(w http.ResponseWriter, change *models.SubscriptionChange) error {
+	data, err := json.Marshal(events.New(changeEventTypes[change.Operation], &change.Subscription).Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, changeEventTypes[change.Operation], data)
+	return err
+}
+
+// replayChanges writes every change after lastEventID, adds its id to
+// replayed and returns the id of the last one written.
+func (h *Handler) replayChanges(w http.ResponseWriter, r *http.Request, userID *uuid.UUID, lastEventID int64, replayed map[int64]bool) (int64, error) {
+	for {
+		changes, err := h.storage.ListSubscriptionChanges(r.Context(), lastEventID, userID, streamReplayBatch)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to replay subscription changes", "error", err)
+			return lastEventID, err
+		}
+
+		for i := range changes {
+			if err := writeChangeEvent(w, &changes[i]); err != nil {
+				return lastEventID, err
+			}
+			lastEventID = changes[i].ID
+			replayed[lastEventID] = true
+		}
+
+		if len(changes) < streamReplayBatch {
+			return lastEventID, nil
+		}
+	}
+}
+
+// Events godoc
+// @Summary Stream subscription changes
+// @Description Server-Sent Events stream of subscription changes. Every event has the change id as its id, the event type (subscription.created, subscription.updated or subscription.deleted) as its name and the subscription as JSON data. Clients reconnecting with a Last-Event-ID header (or last_event_id query parameter) first receive every change they missed.
+// @Tags subscriptions
+// @Produce text/event-stream
+// @Param user_id query string false "Only stream changes of this user (UUID)"
+// @Param last_event_id query int false "Resume after this event id, used when the Last-Event-ID header can't be set"
+// @Param Last-Event-ID header int false "Resume after this event id"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /subscriptions/events [get]
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadSubscriptions) {
+		return
+	}
+
+	var userID *uuid.UUID
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		parsed, err := uuid.Parse(userIDStr)
+		if err != nil {
+			response.RespondFieldError(w, r, "user_id", response.CodeInvalid, "invalid user_id, must be UUID")
+			return
+		}
+		userID = &parsed
+	}
+
+	var ok bool
+	if userID, ok = scopeUserFilter(w, r, userID); !ok {
+		return
+	}
+
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+
+	var lastEventID int64
+	resume := lastEventIDStr != ""
+	if resume {
+		parsed, err := strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || parsed < 0 {
+			response.RespondFieldError(w, r, "Last-Event-ID", response.CodeInvalid, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = parsed
+	}
+
+	// the server write timeout would otherwise end the stream after a few seconds
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logging.FromContext(r.Context()).Error("failed to disable write deadline for event stream", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "streaming unsupported")
+		return
+	}
+
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The history is replayed before subscribing, so a long replay can't
+	// fill the subscriber buffer and get the client dropped. A second replay
+	// after subscribing picks up what was committed in between; live changes
+	// that were replayed are skipped below. Live changes can have lower ids
+	// than replayed ones when their transaction committed late, so they are
+	// compared by id rather than order.
+	replayed := make(map[int64]bool)
+	if resume {
+		var err error
+		if lastEventID, err = h.replayChanges(w, r, userID, lastEventID, replayed); err != nil {
+			return
+		}
+	}
+
+	tenantID, _ := tenant.FromContext(ctx)
+	sub := h.broker.Subscribe(tenantID, userID)
+	defer h.broker.Unsubscribe(sub)
+
+	if resume {
+		var err error
+		if lastEventID, err = h.replayChanges(w, r, userID, lastEventID, replayed); err != nil {
+			return
+		}
+	}
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("subscription event stream opened", "user_id", userID, "last_event_id", lastEventID)
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case change, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			// already sent during replay
+			if replayed[change.ID] {
+				delete(replayed, change.ID)
+				continue
+			}
+			if err := writeChangeEvent(w, &change); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// Forecast godoc
+// @Summary Forecast subscription spend
+// @Description Project month-by-month spend for the next N months, starting with the current one. Scheduled end dates, price changes and trial periods are taken into account.
+// @Tags subscriptions
+// @Produce json
+// @Param months query int false "Number of months to forecast" default(12) maximum(60)
+// @Param user_id query string false "Filter by user ID (UUID)"
+// @Param service_name query string false "Filter by service name"
+// @Param category query string false "Filter by category"
+// @Param tag query string false "Filter by tag"
+// @Success 200 {object} ForecastResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /subscriptions/forecast [get]
+func (h *Handler) Forecast(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadReports) {
+		return
+	}
+
+	months := 12
+	if monthsStr := r.URL.Query().Get("months"); monthsStr != "" {
+		parsed, err := strconv.Atoi(monthsStr)
+		if err != nil || parsed < 1 || parsed > 60 {
+			response.RespondFieldError(w, r, "months", response.CodeOutOfRange, "months must be between 1 and 60")
+			return
+		}
+		months = parsed
+	}
+
+	startPeriod := billing.CurrentMonth()
+	endPeriod := startPeriod.AddDate(0, months-1, 0)
+
+	params := storage.TotalCostParams{
+		StartPeriod: startPeriod,
+		EndPeriod:   endPeriod,
+	}
+
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			response.RespondFieldError(w, r, "user_id", response.CodeInvalid, "invalid user_id, must be UUID")
+			return
+		}
+		params.UserID = &userID
+	}
+
+	var ok bool
+	if params.UserID, ok = scopeReportFilter(w, r, params.UserID); !ok {
+		return
+	}
+
+	costFilters(r, &params)
+
+	ctx := r.Context()
+	subs, err := h.storage.GetSubscriptionsForPeriod(ctx, params)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to get subscriptions", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	changes, err := h.storage.GetPriceChanges(ctx, billing.SubscriptionIDs(subs))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to get price changes", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	forecast := buildForecast(subs, changes, startPeriod, endPeriod)
+
+	logging.FromContext(r.Context()).Info("forecast calculated",
+		"months", months,
+		"subscriptions_count", len(subs),
+		"total_cost", forecast.TotalCost,
+	)
+
+	response.RespondJSON(w, http.StatusOK, forecast)
+}
@@ -1,45 +1,76 @@
 package handler
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/seeques/subman/internal/budget"
 	"github.com/seeques/subman/internal/config"
 	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/stream"
 )
 
 type Handler struct {
 	storage *storage.PostgresStorage
+	budgets *budget.Evaluator
+	broker  *stream.Broker
 	cfg     config.Config
 }
 
-func NewHandler(storage *storage.PostgresStorage, cfg config.Config) *Handler {
+func NewHandler(storage *storage.PostgresStorage, broker *stream.Broker, cfg config.Config) *Handler {
 	return &Handler{
 		storage: storage,
+		budgets: budget.NewEvaluator(storage),
+		broker:  broker,
 		cfg:     cfg,
 	}
 }
 
+// SubscriptionRequest is checked by the validate tags, see package validate.
+// Lengths and bounds match the columns they are stored in.
 type SubscriptionRequest struct {
-    ServiceName string `json:"service_name" example:"Yandex Plus"`
-    Price       int    `json:"price" example:"400"`
-    UserID      string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
-    StartDate   string `json:"start_date" example:"07-2025"`
-    EndDate     string `json:"end_date,omitempty" example:"12-2025"`
+    ServiceName string `json:"service_name" validate:"required,max=255" example:"Yandex Plus"`
+    Price       int    `json:"price" validate:"required,min=1,max=2147483647" example:"400"`
+    UserID      string `json:"user_id" validate:"required,uuid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+    StartDate   string `json:"start_date" validate:"required,month" example:"07-2025"`
+    EndDate     string `json:"end_date,omitempty" validate:"month,notbefore=start_date" example:"12-2025"`
+    TrialEndDate string `json:"trial_end_date,omitempty" validate:"month,notbefore=start_date" example:"08-2025"`
+    Category    string   `json:"category,omitempty" validate:"max=64" example:"entertainment"`
+    Tags        []string `json:"tags,omitempty" validate:"max=20" example:"family,music"`
 }
 
 type SubscriptionResponse struct {
     ID          int       `json:"id" example:"1"`
     ServiceName string    `json:"service_name" example:"Yandex Plus"`
+    ServiceID   *int      `json:"service_id,omitempty" example:"1"`
     Price       int       `json:"price" example:"400"`
     UserID      string    `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
     StartDate   string    `json:"start_date" example:"07-2025"`
     EndDate     *string   `json:"end_date,omitempty" example:"12-2025"`
+    TrialEndDate *string  `json:"trial_end_date,omitempty" example:"08-2025"`
+    Category    string    `json:"category,omitempty" example:"entertainment"`
+    Tags        []string  `json:"tags" example:"family,music"`
     CreatedAt   time.Time `json:"created_at"`
     UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// ErrorResponse documents response.Problem, the RFC 7807 problem details of
+// failed requests. With ERROR_FORMAT=legacy it is {"error": "<detail>"}.
 type ErrorResponse struct {
-    Error string `json:"error" example:"invalid request"`
+    Type      string               `json:"type" example:"about:blank"`
+    Title     string               `json:"title" example:"Bad Request"`
+    Status    int                  `json:"status" example:"400"`
+    Detail    string               `json:"detail" example:"price must be more than zero"`
+    Instance  string               `json:"instance" example:"/api/v1/subscriptions"`
+    Code      string               `json:"code" example:"validation_failed"`
+    Errors    []FieldErrorResponse `json:"errors,omitempty"`
+    RequestID string               `json:"request_id" example:"host/abcdef-000001"`
+}
+
+type FieldErrorResponse struct {
+    Field   string `json:"field" example:"price"`
+    Code    string `json:"code" example:"out_of_range"`
+    Message string `json:"message" example:"price must be more than zero"`
 }
 
 type ListResponse struct {
@@ -60,4 +91,264 @@ type TotalCostResponse struct {
     PeriodStart        string `json:"period_start" example:"01-2025"`
     PeriodEnd          string `json:"period_end" example:"06-2025"`
     SubscriptionsCount int    `json:"subscriptions_count" example:"3"`
-}
\ No newline at end of file
+    GroupBy            string      `json:"group_by,omitempty" example:"tag"`
+    Breakdown          []CostGroup `json:"breakdown,omitempty"`
+}
+
+// CostGroup is the spend of a tag or category. Subscriptions with several
+// tags count towards each of them, and those without any are grouped
+// under an empty name.
+type CostGroup struct {
+    Group              string `json:"group" example:"entertainment"`
+    TotalCost          int    `json:"total_cost" example:"2400"`
+    SubscriptionsCount int    `json:"subscriptions_count" example:"2"`
+}
+
+type PriceChangeRequest struct {
+    Price         int    `json:"price" validate:"required,min=1,max=2147483647" example:"500"`
+    EffectiveDate string `json:"effective_date" validate:"required,month" example:"01-2026"`
+}
+
+type PriceChangeResponse struct {
+    ID             int       `json:"id" example:"1"`
+    SubscriptionID int       `json:"subscription_id" example:"1"`
+    Price          int       `json:"price" example:"500"`
+    EffectiveDate  string    `json:"effective_date" example:"01-2026"`
+    CreatedAt      time.Time `json:"created_at"`
+}
+
+type ServiceCost struct {
+    ServiceName string `json:"service_name" example:"Yandex Plus"`
+    TotalCost   int    `json:"total_cost" example:"1200"`
+}
+
+type ForecastMonth struct {
+    Month     string        `json:"month" example:"11-2025"`
+    TotalCost int           `json:"total_cost" example:"900"`
+    Services  []ServiceCost `json:"services"`
+}
+
+type ForecastResponse struct {
+    TotalCost   int             `json:"total_cost" example:"10800"`
+    Currency    string          `json:"currency" example:"RUB"`
+    PeriodStart string          `json:"period_start" example:"11-2025"`
+    PeriodEnd   string          `json:"period_end" example:"10-2026"`
+    Months      []ForecastMonth `json:"months"`
+    Services    []ServiceCost   `json:"services"`
+}
+
+type BudgetRequest struct {
+    UserID     string `json:"user_id" validate:"required,uuid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+    Period     string `json:"period" validate:"required,oneof=monthly yearly" example:"monthly" enums:"monthly,yearly"`
+    Limit      int    `json:"limit" validate:"required,min=1" example:"2000"`
+    Currency   string `json:"currency,omitempty" example:"RUB"`
+    Thresholds []int  `json:"thresholds,omitempty" example:"80,100"`
+}
+
+type BudgetResponse struct {
+    ID         int       `json:"id" example:"1"`
+    UserID     string    `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+    Period     string    `json:"period" example:"monthly"`
+    Limit      int       `json:"limit" example:"2000"`
+    Currency   string    `json:"currency" example:"RUB"`
+    Thresholds []int     `json:"thresholds" example:"80,100"`
+    CreatedAt  time.Time `json:"created_at"`
+    UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type BudgetListResponse struct {
+    Data []BudgetResponse `json:"data"`
+    Meta ListMeta         `json:"meta"`
+}
+
+type BudgetBreachResponse struct {
+    Threshold   int       `json:"threshold" example:"80"`
+    PeriodStart string    `json:"period_start" example:"11-2025"`
+    Spent       int       `json:"spent" example:"1700"`
+    Limit       int       `json:"limit" example:"2000"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+type BudgetStatusResponse struct {
+    BudgetID          int                    `json:"budget_id" example:"1"`
+    Period            string                 `json:"period" example:"monthly"`
+    PeriodStart       string                 `json:"period_start" example:"11-2025"`
+    PeriodEnd         string                 `json:"period_end" example:"11-2025"`
+    Limit             int                    `json:"limit" example:"2000"`
+    Spent             int                    `json:"spent" example:"1700"`
+    Remaining         int                    `json:"remaining" example:"300"`
+    PercentUsed       float64                `json:"percent_used" example:"85"`
+    Currency          string                 `json:"currency" example:"RUB"`
+    ReachedThresholds []int                  `json:"reached_thresholds" example:"80"`
+    Breaches          []BudgetBreachResponse `json:"breaches"`
+}
+
+type WebhookRequest struct {
+    URL        string   `json:"url" validate:"required" example:"https://example.com/hooks/subman"`
+    Secret     string   `json:"secret,omitempty" example:"whsec_3f9a..."`
+    EventTypes []string `json:"event_types" validate:"required" example:"subscription.created,subscription.deleted"`
+}
+
+type WebhookResponse struct {
+    ID         int       `json:"id" example:"1"`
+    URL        string    `json:"url" example:"https://example.com/hooks/subman"`
+    Secret     string    `json:"secret,omitempty" example:"whsec_3f9a..."`
+    EventTypes []string  `json:"event_types" example:"subscription.created,subscription.deleted"`
+    Active     bool      `json:"active" example:"true"`
+    CreatedAt  time.Time `json:"created_at"`
+    UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookDeliveryResponse struct {
+    ID         int64           `json:"id" example:"42"`
+    EndpointID int             `json:"endpoint_id" example:"1"`
+    EventID    string          `json:"event_id" example:"0b4c2f6e-6d0c-4a47-9a0e-1c6f3f0f4d4e"`
+    EventType  string          `json:"event_type" example:"subscription.created"`
+    Payload    json.RawMessage `json:"payload" swaggertype:"object"`
+    Status     string          `json:"status" example:"dead"`
+    Attempts   int             `json:"attempts" example:"8"`
+    LastError  *string         `json:"last_error,omitempty" example:"endpoint responded with status 500"`
+    CreatedAt  time.Time       `json:"created_at"`
+    UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+type WebhookDeliveryListResponse struct {
+    Data []WebhookDeliveryResponse `json:"data"`
+    Meta ListMeta                  `json:"meta"`
+}
+
+type APIKeyRequest struct {
+    Name   string   `json:"name" validate:"required,max=255" example:"billing-dashboard"`
+    Scopes []string `json:"scopes" validate:"required" example:"read,reports"`
+}
+
+type APIKeyResponse struct {
+    ID         int        `json:"id" example:"1"`
+    TenantID   string     `json:"tenant_id" example:"00000000-0000-0000-0000-000000000001"`
+    Name       string     `json:"name" example:"billing-dashboard"`
+    Prefix     string     `json:"prefix" example:"3f9a0c1b2d4e"`
+    Key        string     `json:"key,omitempty" example:"sm_3f9a0c1b2d4e_9b1f..."`
+    Scopes     []string   `json:"scopes" example:"read,reports"`
+    CreatedAt  time.Time  `json:"created_at"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type TenantRequest struct {
+    Name string `json:"name" validate:"required,max=255" example:"finance"`
+}
+
+type TenantResponse struct {
+    ID        string    `json:"id" example:"7c3e5f8a-1b2d-4e6f-9a0b-3c4d5e6f7a8b"`
+    Name      string    `json:"name" example:"finance"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type UserRequest struct {
+    ID              string `json:"id,omitempty" validate:"uuid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+    DisplayName     string `json:"display_name" validate:"required,max=255" example:"Ivan Petrov"`
+    Email           string `json:"email,omitempty" validate:"max=255,email" example:"ivan@example.com"`
+    DefaultCurrency string `json:"default_currency,omitempty" validate:"currency" example:"RUB"`
+    Timezone        string `json:"timezone,omitempty" validate:"timezone" example:"Europe/Moscow"`
+}
+
+type UserResponse struct {
+    ID              string    `json:"id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+    DisplayName     string    `json:"display_name" example:"Ivan Petrov"`
+    Email           *string   `json:"email,omitempty" example:"ivan@example.com"`
+    DefaultCurrency string    `json:"default_currency" example:"RUB"`
+    Timezone        string    `json:"timezone" example:"Europe/Moscow"`
+    CreatedAt       time.Time `json:"created_at"`
+    UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type UserListResponse struct {
+    Data []UserResponse `json:"data"`
+    Meta ListMeta       `json:"meta"`
+}
+
+// ServiceRequest is a catalog entry. Subscriptions are matched to it by
+// name or alias, ignoring case and repeated spaces.
+type ServiceRequest struct {
+    Name     string        `json:"name" validate:"required,max=255" example:"Yandex Plus"`
+    Aliases  []string      `json:"aliases,omitempty" validate:"max=50" example:"Яндекс Плюс,Yandex+"`
+    Category string        `json:"category,omitempty" validate:"max=64" example:"entertainment"`
+    Vendor   string        `json:"vendor,omitempty" validate:"max=255" example:"Yandex"`
+    Website  string        `json:"website,omitempty" validate:"max=255" example:"https://plus.yandex.ru"`
+    Plans    []ServicePlan `json:"plans,omitempty" validate:"max=50"`
+}
+
+// ServicePlan is a plan of a service with its default monthly price.
+type ServicePlan struct {
+    Name  string `json:"name" example:"Family"`
+    Price int    `json:"price" example:"699"`
+}
+
+type ServiceResponse struct {
+    ID        int           `json:"id" example:"1"`
+    Name      string        `json:"name" example:"Yandex Plus"`
+    Aliases   []string      `json:"aliases" example:"Яндекс Плюс,Yandex+"`
+    Category  string        `json:"category,omitempty" example:"entertainment"`
+    Vendor    string        `json:"vendor,omitempty" example:"Yandex"`
+    Website   string        `json:"website,omitempty" example:"https://plus.yandex.ru"`
+    Plans     []ServicePlan `json:"plans"`
+    CreatedAt time.Time     `json:"created_at"`
+    UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type ServiceListResponse struct {
+    Data []ServiceResponse `json:"data"`
+    Meta ListMeta          `json:"meta"`
+}
+
+type ServiceNameUsage struct {
+    ServiceName        string `json:"service_name" example:"yandex plus"`
+    ServiceID          *int   `json:"service_id,omitempty" example:"1"`
+    SubscriptionsCount int    `json:"subscriptions_count" example:"3"`
+}
+
+// ServiceMergeProposal is a cluster of similar service names with the name
+// they are proposed to be merged into.
+type ServiceMergeProposal struct {
+    Target     string             `json:"target" example:"Yandex Plus"`
+    Sources    []string           `json:"sources" example:"yandex plus,Яндекс Плюс"`
+    Similarity float64            `json:"similarity" example:"0.77"`
+    Names      []ServiceNameUsage `json:"names"`
+}
+
+type ServiceMergeProposalsResponse struct {
+    Threshold float64                `json:"threshold" example:"0.75"`
+    Proposals []ServiceMergeProposal `json:"proposals"`
+}
+
+type ServiceMergeRequest struct {
+    Target  string   `json:"target" validate:"required,max=255" example:"Yandex Plus"`
+    Sources []string `json:"sources" validate:"required,max=100" example:"yandex plus,Яндекс Плюс"`
+}
+
+type ServiceMergeResponse struct {
+    ID                 int       `json:"id" example:"1"`
+    Target             string    `json:"target" example:"Yandex Plus"`
+    Sources            []string  `json:"sources" example:"yandex plus,Яндекс Плюс"`
+    ServiceID          *int      `json:"service_id,omitempty" example:"1"`
+    SubscriptionsCount int       `json:"subscriptions_count" example:"4"`
+    MergedBy           string    `json:"merged_by" example:"apikey:1"`
+    CreatedAt          time.Time `json:"created_at"`
+}
+
+type ServiceMergeListResponse struct {
+    Data []ServiceMergeResponse `json:"data"`
+    Meta ListMeta               `json:"meta"`
+}
+
+type RoleAssignmentRequest struct {
+    Principal string `json:"principal" validate:"required,max=255" example:"jwt:8f4e7a2c-9b1d-4c3e-a5f6-7d8e9f0a1b2c"`
+    Role      string `json:"role" validate:"required" example:"finance"`
+}
+
+type RoleAssignmentResponse struct {
+    ID        int       `json:"id" example:"1"`
+    Principal string    `json:"principal" example:"jwt:8f4e7a2c-9b1d-4c3e-a5f6-7d8e9f0a1b2c"`
+    Role      string    `json:"role" example:"finance"`
+    CreatedAt time.Time `json:"created_at"`
+}
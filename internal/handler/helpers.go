@@ -1,13 +1,61 @@
 package handler
 
 import (
+	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/logging"
 	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/validate"
 )
 
+// decodeRequest reads the JSON body into v, a pointer to a request DTO, and
+// checks it with its validate tags. It writes a 400 and returns false when
+// the body isn't JSON, otherwise it returns the invalid fields, so handlers
+// can add the checks tags can't express before responding.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) (response.FieldErrors, bool) {
+	errs, err := validate.Decode(r.Body, v)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("invalid JSON in request body", "error", err)
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidJSON, "invalid JSON")
+		return nil, false
+	}
+	return errs, true
+}
+
+// pagination reads the page and limit query parameters, defaulting to the
+// first page of the configured page size and capping limit at the
+// configured maximum.
+func (h *Handler) pagination(r *http.Request) (page, limit int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err = strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = h.cfg.DefaultPageSize
+	}
+	if limit > h.cfg.MaxPageSize {
+		limit = h.cfg.MaxPageSize
+	}
+	return page, limit
+}
+
 func parseMonthYear(s string) (time.Time, error) {
-	return time.Parse("01-2006", s)
+	return time.Parse(validate.MonthLayout, s)
+}
+
+func formatMonthYear(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("01-2006")
+	return &s
 }
 
 func toSubscriptionResponse(sub *models.Subscription) SubscriptionResponse {
@@ -15,48 +63,237 @@ func toSubscriptionResponse(sub *models.Subscription) SubscriptionResponse {
     if sub.EndDate != nil {
         endDate = sub.EndDate.Format("01-2006")
     }
+    tags := sub.Tags
+    if tags == nil {
+        tags = []string{}
+    }
 
 	return SubscriptionResponse{
-        ID:          sub.ID,
-        ServiceName: sub.ServiceName,
-        Price:       sub.Price,
-        UserID:      sub.UserID.String(),
-        StartDate:   sub.StartDate.Format("01-2006"),
-        EndDate:     &endDate,
-        CreatedAt:   sub.CreatedAt,
-        UpdatedAt:   sub.UpdatedAt,
+        ID:           sub.ID,
+        ServiceName:  sub.ServiceName,
+        ServiceID:    sub.ServiceID,
+        Price:        sub.Price,
+        UserID:       sub.UserID.String(),
+        StartDate:    sub.StartDate.Format("01-2006"),
+        EndDate:      &endDate,
+        TrialEndDate: formatMonthYear(sub.TrialEndDate),
+        Category:     sub.Category,
+        Tags:         tags,
+        CreatedAt:    sub.CreatedAt,
+        UpdatedAt:    sub.UpdatedAt,
     }
 }
 
-func countMonths(start, end time.Time) int {
-    years := end.Year() - start.Year()
-    months := int(end.Month()) - int(start.Month())
-    return years*12 + months + 1  // +1 because inclusive
+func toPriceChangeResponse(change *models.PriceChange) PriceChangeResponse {
+	return PriceChangeResponse{
+		ID:             change.ID,
+		SubscriptionID: change.SubscriptionID,
+		Price:          change.Price,
+		EffectiveDate:  change.EffectiveDate.Format("01-2006"),
+		CreatedAt:      change.CreatedAt,
+	}
+}
+
+func toBudgetResponse(budget *models.Budget) BudgetResponse {
+	return BudgetResponse{
+		ID:         budget.ID,
+		UserID:     budget.UserID.String(),
+		Period:     budget.Period,
+		Limit:      budget.Limit,
+		Currency:   budget.Currency,
+		Thresholds: budget.Thresholds,
+		CreatedAt:  budget.CreatedAt,
+		UpdatedAt:  budget.UpdatedAt,
+	}
 }
 
-func calculateTotalCost(subs []models.Subscription, startPeriod, endPeriod time.Time) int {
-    total := 0
+func toWebhookResponse(endpoint *models.WebhookEndpoint) WebhookResponse {
+	return WebhookResponse{
+		ID:         endpoint.ID,
+		URL:        endpoint.URL,
+		EventTypes: endpoint.EventTypes,
+		Active:     endpoint.Active,
+		CreatedAt:  endpoint.CreatedAt,
+		UpdatedAt:  endpoint.UpdatedAt,
+	}
+}
 
-    for _, sub := range subs {
-        // Find if startPeriod overlaps with the startDate
-        overlapStart := sub.StartDate
-        if startPeriod.After(overlapStart) {
-            overlapStart = startPeriod
-        }
+func toWebhookDeliveryResponse(delivery *models.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:         delivery.ID,
+		EndpointID: delivery.EndpointID,
+		EventID:    delivery.EventID.String(),
+		EventType:  delivery.EventType,
+		Payload:    delivery.Payload,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+		LastError:  delivery.LastError,
+		CreatedAt:  delivery.CreatedAt,
+		UpdatedAt:  delivery.UpdatedAt,
+	}
+}
 
-        // Find if endDate overlaps with the endPeriod
-        overlapEnd := endPeriod
-        if sub.EndDate != nil && sub.EndDate.Before(overlapEnd) {
-            overlapEnd = *sub.EndDate
-        }
+func toAPIKeyResponse(key *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		TenantID:   key.TenantID.String(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
 
-        if overlapStart.After(overlapEnd) {
-            continue
-        }
+func toTenantResponse(t *models.Tenant) TenantResponse {
+	return TenantResponse{
+		ID:        t.ID.String(),
+		Name:      t.Name,
+		CreatedAt: t.CreatedAt,
+	}
+}
 
-        months := countMonths(overlapStart, overlapEnd)
-        total += months * sub.Price
-    }
+func toUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:              user.ID.String(),
+		DisplayName:     user.DisplayName,
+		Email:           user.Email,
+		DefaultCurrency: user.DefaultCurrency,
+		Timezone:        user.Timezone,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+func toServiceResponse(service *models.Service) ServiceResponse {
+	plans := make([]ServicePlan, len(service.Plans))
+	for i, plan := range service.Plans {
+		plans[i] = ServicePlan{Name: plan.Name, Price: plan.Price}
+	}
+
+	return ServiceResponse{
+		ID:        service.ID,
+		Name:      service.Name,
+		Aliases:   service.Aliases,
+		Category:  service.Category,
+		Vendor:    service.Vendor,
+		Website:   service.Website,
+		Plans:     plans,
+		CreatedAt: service.CreatedAt,
+		UpdatedAt: service.UpdatedAt,
+	}
+}
+
+func toServiceMergeResponse(merge *models.ServiceMerge) ServiceMergeResponse {
+	return ServiceMergeResponse{
+		ID:                 merge.ID,
+		Target:             merge.Target,
+		Sources:            merge.Sources,
+		ServiceID:          merge.ServiceID,
+		SubscriptionsCount: merge.SubscriptionsCount,
+		MergedBy:           merge.MergedBy,
+		CreatedAt:          merge.CreatedAt,
+	}
+}
+
+func toRoleAssignmentResponse(assignment *models.RoleAssignment) RoleAssignmentResponse {
+	return RoleAssignmentResponse{
+		ID:        assignment.ID,
+		Principal: assignment.Principal,
+		Role:      assignment.Role,
+		CreatedAt: assignment.CreatedAt,
+	}
+}
+
+// buildForecast projects the monthly spend of subs over every month between
+// startPeriod and endPeriod inclusive, broken down by service.
+func buildForecast(subs []models.Subscription, changes map[int][]models.PriceChange, startPeriod, endPeriod time.Time) ForecastResponse {
+	forecast := ForecastResponse{
+		Currency:    "RUB",
+		PeriodStart: startPeriod.Format("01-2006"),
+		PeriodEnd:   endPeriod.Format("01-2006"),
+		Months:      []ForecastMonth{},
+	}
+
+	serviceTotals := make(map[string]int)
+	for month := startPeriod; !month.After(endPeriod); month = month.AddDate(0, 1, 0) {
+		monthTotals := make(map[string]int)
+		monthTotal := 0
+
+		for i := range subs {
+			price := billing.MonthlyPrice(&subs[i], changes[subs[i].ID], month)
+			if price == 0 {
+				continue
+			}
+			monthTotals[subs[i].ServiceName] += price
+			monthTotal += price
+		}
+
+		for name, cost := range monthTotals {
+			serviceTotals[name] += cost
+		}
 
-    return total
-}
\ No newline at end of file
+		forecast.Months = append(forecast.Months, ForecastMonth{
+			Month:     month.Format("01-2006"),
+			TotalCost: monthTotal,
+			Services:  toServiceCosts(monthTotals),
+		})
+		forecast.TotalCost += monthTotal
+	}
+
+	forecast.Services = toServiceCosts(serviceTotals)
+	return forecast
+}
+
+// buildCostBreakdown totals the cost of subs per tag or category, most
+// expensive first. Subscriptions count towards each of their tags, and
+// those without a tag or category are grouped under an empty name.
+func buildCostBreakdown(subs []models.Subscription, changes map[int][]models.PriceChange, groupBy string, startPeriod, endPeriod time.Time) []CostGroup {
+	groups := make(map[string][]models.Subscription)
+	for _, sub := range subs {
+		names := []string{sub.Category}
+		if groupBy == "tag" {
+			names = sub.Tags
+			if len(names) == 0 {
+				names = []string{""}
+			}
+		}
+		for _, name := range names {
+			groups[name] = append(groups[name], sub)
+		}
+	}
+
+	breakdown := make([]CostGroup, 0, len(groups))
+	for name, members := range groups {
+		breakdown = append(breakdown, CostGroup{
+			Group:              name,
+			TotalCost:          billing.TotalCost(members, changes, startPeriod, endPeriod),
+			SubscriptionsCount: len(members),
+		})
+	}
+
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].TotalCost != breakdown[j].TotalCost {
+			return breakdown[i].TotalCost > breakdown[j].TotalCost
+		}
+		return breakdown[i].Group < breakdown[j].Group
+	})
+	return breakdown
+}
+
+// toServiceCosts flattens per-service totals, most expensive first.
+func toServiceCosts(totals map[string]int) []ServiceCost {
+	costs := make([]ServiceCost, 0, len(totals))
+	for name, cost := range totals {
+		costs = append(costs, ServiceCost{ServiceName: name, TotalCost: cost})
+	}
+
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].TotalCost != costs[j].TotalCost {
+			return costs[i].TotalCost > costs[j].TotalCost
+		}
+		return costs[i].ServiceName < costs[j].ServiceName
+	})
+	return costs
+}
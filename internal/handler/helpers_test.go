@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/models"
+)
+
+func month(m time.Month, year int) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func monthPtr(m time.Month, year int) *time.Time {
+	t := month(m, year)
+	return &t
+}
+
+func TestBuildForecast(t *testing.T) {
+	tests := []struct {
+		name         string
+		subs         []models.Subscription
+		changes      map[int][]models.PriceChange
+		wantMonths   []int
+		wantServices []ServiceCost
+	}{
+		{
+			name: "ends mid-window",
+			subs: []models.Subscription{
+				{ID: 1, ServiceName: "Netflix", Price: 400, StartDate: month(time.January, 2025), EndDate: monthPtr(time.March, 2025)},
+			},
+			wantMonths:   []int{400, 400, 400, 0, 0, 0},
+			wantServices: []ServiceCost{{ServiceName: "Netflix", TotalCost: 1200}},
+		},
+		{
+			name: "trial ends inside the window",
+			subs: []models.Subscription{
+				{ID: 1, ServiceName: "Okko", Price: 300, StartDate: month(time.December, 2024), TrialEndDate: monthPtr(time.February, 2025)},
+			},
+			wantMonths:   []int{0, 0, 300, 300, 300, 300},
+			wantServices: []ServiceCost{{ServiceName: "Okko", TotalCost: 1200}},
+		},
+		{
+			name: "price change in a later month",
+			subs: []models.Subscription{
+				{ID: 1, ServiceName: "Yandex Plus", Price: 300, StartDate: month(time.January, 2024)},
+			},
+			changes: map[int][]models.PriceChange{
+				1: {{SubscriptionID: 1, Price: 450, EffectiveDate: month(time.April, 2025)}},
+			},
+			wantMonths:   []int{300, 300, 300, 450, 450, 450},
+			wantServices: []ServiceCost{{ServiceName: "Yandex Plus", TotalCost: 2250}},
+		},
+		{
+			name: "services are totalled across subscriptions",
+			subs: []models.Subscription{
+				{ID: 1, ServiceName: "Netflix", Price: 400, StartDate: month(time.January, 2025)},
+				{ID: 2, ServiceName: "Netflix", Price: 200, StartDate: month(time.May, 2025)},
+				{ID: 3, ServiceName: "Spotify", Price: 100, StartDate: month(time.January, 2025), EndDate: monthPtr(time.January, 2025)},
+				// starts after the window
+				{ID: 4, ServiceName: "Okko", Price: 500, StartDate: month(time.July, 2025)},
+			},
+			wantMonths: []int{500, 400, 400, 400, 600, 600},
+			wantServices: []ServiceCost{
+				{ServiceName: "Netflix", TotalCost: 2800},
+				{ServiceName: "Spotify", TotalCost: 100},
+			},
+		},
+	}
+
+	start, end := month(time.January, 2025), month(time.June, 2025)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecast := buildForecast(tt.subs, tt.changes, start, end)
+
+			if forecast.PeriodStart != "01-2025" || forecast.PeriodEnd != "06-2025" {
+				t.Errorf("period = %s to %s, want 01-2025 to 06-2025", forecast.PeriodStart, forecast.PeriodEnd)
+			}
+
+			var months []int
+			for _, m := range forecast.Months {
+				months = append(months, m.TotalCost)
+
+				services := 0
+				for _, s := range m.Services {
+					services += s.TotalCost
+				}
+				if services != m.TotalCost {
+					t.Errorf("services of %s add up to %d, want %d", m.Month, services, m.TotalCost)
+				}
+			}
+			if !reflect.DeepEqual(months, tt.wantMonths) {
+				t.Errorf("monthly totals = %v, want %v", months, tt.wantMonths)
+			}
+			if forecast.Months[0].Month != "01-2025" || forecast.Months[5].Month != "06-2025" {
+				t.Errorf("months run from %s to %s", forecast.Months[0].Month, forecast.Months[len(forecast.Months)-1].Month)
+			}
+
+			if !reflect.DeepEqual(forecast.Services, tt.wantServices) {
+				t.Errorf("services = %v, want %v", forecast.Services, tt.wantServices)
+			}
+
+			// the forecast agrees with total cost over the same period
+			want := billing.TotalCost(tt.subs, tt.changes, start, end)
+			if forecast.TotalCost != want {
+				t.Errorf("total = %d, want TotalCost %d", forecast.TotalCost, want)
+			}
+			services := 0
+			for _, s := range forecast.Services {
+				services += s.TotalCost
+			}
+			if services != want {
+				t.Errorf("services add up to %d, want TotalCost %d", services, want)
+			}
+		})
+	}
+}
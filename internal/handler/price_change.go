@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+)
+
+// CreatePriceChange godoc
+// @Summary Schedule a price change
+// @Description Schedule a new price for a subscription starting from the given month. Scheduling a second change for the same month replaces the first.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param input body PriceChangeRequest true "Price change data"
+// @Success 201 {object} PriceChangeResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /subscriptions/{id}/price-changes [post]
+func (h *Handler) CreatePriceChange(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteSubscriptions) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	var req PriceChangeRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+	effectiveDate, _ := parseMonthYear(req.EffectiveDate)
+
+	ctx := r.Context()
+	sub, err := h.storage.GetSubscription(ctx, id)
+	if err == nil && !auth.CanAccessUser(ctx, sub.UserID) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeSubscriptionNotFound, "subscription not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to get subscription", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	if effectiveDate.Before(sub.StartDate) {
+		response.RespondFieldError(w, r, "effective_date", response.CodeOutOfRange, "effective_date must not be before start_date")
+		return
+	}
+
+	change := &models.PriceChange{
+		SubscriptionID: sub.ID,
+		Price:          req.Price,
+		EffectiveDate:  effectiveDate,
+	}
+
+	if err := h.storage.CreatePriceChange(ctx, change); err != nil {
+		logging.FromContext(r.Context()).Error("failed to create price change", "error", err, "subscription_id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("price change scheduled",
+		"subscription_id", sub.ID,
+		"price", change.Price,
+		"effective_date", req.EffectiveDate,
+	)
+
+	response.RespondJSON(w, http.StatusCreated, toPriceChangeResponse(change))
+}
+
+// ListPriceChanges godoc
+// @Summary List price changes
+// @Description List the scheduled price changes of a subscription ordered by effective date
+// @Tags subscriptions
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {array} PriceChangeResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /subscriptions/{id}/price-changes [get]
+func (h *Handler) ListPriceChanges(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadSubscriptions) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	if !h.ownsSubscription(w, r, id) {
+		return
+	}
+
+	ctx := r.Context()
+
+	changes, err := h.storage.GetPriceChanges(ctx, []int{id})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to get price changes", "error", err, "subscription_id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	data := make([]PriceChangeResponse, len(changes[id]))
+	for i, change := range changes[id] {
+		data[i] = toPriceChangeResponse(&change)
+	}
+
+	response.RespondJSON(w, http.StatusOK, data)
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+)
+
+// CreateRoleAssignment godoc
+// @Summary Assign a role
+// @Description Assign a role (viewer, editor, finance, admin) to a principal of the current tenant. Principals are API keys ("apikey:<id>") and token subjects ("jwt:<sub>"). Roles add to the permissions granted by scopes; finance can see the totals of every user. Assigning a role twice returns the existing assignment.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param input body RoleAssignmentRequest true "Role assignment"
+// @Success 201 {object} RoleAssignmentResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/roles [post]
+func (h *Handler) CreateRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageRoles) {
+		return
+	}
+
+	var req RoleAssignmentRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	kind, id, _ := strings.Cut(req.Principal, ":")
+	if (kind != "apikey" && kind != "jwt") || id == "" {
+		errs.Add("principal", response.CodeInvalid, `principal must be "apikey:<id>" or "jwt:<sub>"`)
+	}
+
+	if req.Role != "" && !auth.IsRole(req.Role) {
+		errs.Add("role", response.CodeUnknown, "unknown role: "+req.Role)
+	}
+
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	assignment := &models.RoleAssignment{
+		Principal: req.Principal,
+		Role:      req.Role,
+	}
+
+	if err := h.storage.CreateRoleAssignment(r.Context(), assignment); err != nil {
+		logging.FromContext(r.Context()).Error("failed to create role assignment", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to assign role")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("role assigned", "assignment_id", assignment.ID, "principal", assignment.Principal, "role", assignment.Role)
+
+	response.RespondJSON(w, http.StatusCreated, toRoleAssignmentResponse(assignment))
+}
+
+// ListRoleAssignments godoc
+// @Summary List role assignments
+// @Description List the role assignments of the current tenant, optionally of a single principal.
+// @Tags admin
+// @Produce json
+// @Param principal query string false "Filter by principal, e.g. apikey:12"
+// @Success 200 {array} RoleAssignmentResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/roles [get]
+func (h *Handler) ListRoleAssignments(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageRoles) {
+		return
+	}
+
+	assignments, err := h.storage.ListRoleAssignments(r.Context(), r.URL.Query().Get("principal"))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list role assignments", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list role assignments")
+		return
+	}
+
+	data := make([]RoleAssignmentResponse, len(assignments))
+	for i, assignment := range assignments {
+		data[i] = toRoleAssignmentResponse(&assignment)
+	}
+
+	response.RespondJSON(w, http.StatusOK, data)
+}
+
+// DeleteRoleAssignment godoc
+// @Summary Remove a role assignment
+// @Tags admin
+// @Param id path int true "Role assignment ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/roles/{id} [delete]
+func (h *Handler) DeleteRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageRoles) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	if err := h.storage.DeleteRoleAssignment(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeRoleAssignmentNotFound, "role assignment not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to delete role assignment", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("role assignment removed", "assignment_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// parseServiceRequest adds the checks the validate tags of req can't express
+// to errs, the invalid fields found while decoding, and turns req into a
+// service model when no field is invalid.
+func parseServiceRequest(req ServiceRequest, errs response.FieldErrors) (*models.Service, response.FieldErrors) {
+	service := &models.Service{
+		Name:     strings.TrimSpace(req.Name),
+		Aliases:  []string{},
+		Category: strings.TrimSpace(req.Category),
+		Vendor:   strings.TrimSpace(req.Vendor),
+		Website:  req.Website,
+		Plans:    []models.ServicePlan{},
+	}
+	if service.Name == "" && !errs.Has("name") {
+		errs.Add("name", response.CodeRequired, "name is required")
+	}
+
+	for _, alias := range req.Aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || utf8.RuneCountInString(alias) > 255 {
+			errs.Add("aliases", response.CodeInvalid, "aliases must be between 1 and 255 characters")
+			break
+		}
+		service.Aliases = append(service.Aliases, alias)
+	}
+
+	u, err := url.Parse(req.Website)
+	if req.Website != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		errs.Add("website", response.CodeInvalid, "invalid website, expected absolute http(s) URL")
+	}
+
+	names := make(map[string]bool)
+	for _, plan := range req.Plans {
+		plan.Name = strings.TrimSpace(plan.Name)
+		if plan.Name == "" || utf8.RuneCountInString(plan.Name) > 255 {
+			errs.Add("plans", response.CodeInvalid, "plan names must be between 1 and 255 characters")
+			break
+		}
+		if names[plan.Name] {
+			errs.Add("plans", response.CodeInvalid, "duplicate plan "+plan.Name)
+			break
+		}
+		if plan.Price <= 0 {
+			errs.Add("plans", response.CodeOutOfRange, "plan prices must be more than zero")
+			break
+		}
+		names[plan.Name] = true
+		service.Plans = append(service.Plans, models.ServicePlan{Name: plan.Name, Price: plan.Price})
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+	return service, nil
+}
+
+// CreateService godoc
+// @Summary Add a service to the catalog
+// @Description Add a service with its aliases, category, vendor, website and default plans. Subscriptions whose service name matches the name or an alias, ignoring case and repeated spaces, are linked to it and renamed to its name, both existing ones and those created or updated later.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param input body ServiceRequest true "Service data"
+// @Success 201 {object} ServiceResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services [post]
+func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageServices) {
+		return
+	}
+
+	var req ServiceRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	service, errs := parseServiceRequest(req, errs)
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	if err := h.storage.CreateService(r.Context(), service); err != nil {
+		if errors.Is(err, storage.ErrServiceNameTaken) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeServiceNameTaken, "name or alias already belongs to another service")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to create service", "error", err, "name", service.Name)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to create service")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("service created", "service_id", service.ID, "name", service.Name)
+
+	response.RespondJSON(w, http.StatusCreated, toServiceResponse(service))
+}
+
+// ListServices godoc
+// @Summary List the service catalog
+// @Description Get a paginated list of catalog services ordered by name
+// @Tags services
+// @Produce json
+// @Param category query string false "Filter by category"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10) maximum(100)
+// @Success 200 {object} ServiceListResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services [get]
+func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadSubscriptions) {
+		return
+	}
+
+	page, limit := h.pagination(r)
+
+	result, err := h.storage.ListServices(r.Context(), storage.ServiceListParams{
+		Page:     page,
+		Limit:    limit,
+		Category: r.URL.Query().Get("category"),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list services", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list services")
+		return
+	}
+
+	data := make([]ServiceResponse, len(result.Services))
+	for i, service := range result.Services {
+		data[i] = toServiceResponse(&service)
+	}
+
+	response.RespondJSON(w, http.StatusOK, ServiceListResponse{
+		Data: data,
+		Meta: ListMeta{
+			Page:       page,
+			Limit:      limit,
+			Total:      result.Total,
+			TotalPages: (result.Total + limit - 1) / limit,
+		},
+	})
+}
+
+// GetService godoc
+// @Summary Get a catalog service by ID
+// @Tags services
+// @Produce json
+// @Param id path int true "Service ID"
+// @Success 200 {object} ServiceResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services/{id} [get]
+func (h *Handler) GetService(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadSubscriptions) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	service, err := h.storage.GetService(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeServiceNotFound, "service not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to get service", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	response.RespondJSON(w, http.StatusOK, toServiceResponse(service))
+}
+
+// UpdateService godoc
+// @Summary Update a catalog service
+// @Description Replace a catalog service. Its subscriptions are renamed along with it, and subscriptions matching new aliases are linked to it.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path int true "Service ID"
+// @Param input body ServiceRequest true "Updated service data"
+// @Success 200 {object} ServiceResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services/{id} [put]
+func (h *Handler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageServices) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	var req ServiceRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	service, errs := parseServiceRequest(req, errs)
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	service.ID = id
+	if err := h.storage.UpdateService(r.Context(), service); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeServiceNotFound, "service not found")
+			return
+		}
+		if errors.Is(err, storage.ErrServiceNameTaken) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeServiceNameTaken, "name or alias already belongs to another service")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to update service", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("service updated", "service_id", id, "name", service.Name)
+
+	response.RespondJSON(w, http.StatusOK, toServiceResponse(service))
+}
+
+// DeleteService godoc
+// @Summary Remove a service from the catalog
+// @Description Remove a catalog service. Its subscriptions keep their service name but are no longer linked.
+// @Tags services
+// @Param id path int true "Service ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services/{id} [delete]
+func (h *Handler) DeleteService(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageServices) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	if err := h.storage.DeleteService(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeServiceNotFound, "service not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to delete service", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("service deleted", "service_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
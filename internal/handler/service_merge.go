@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/servicename"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// defaultMergeThreshold is low enough for transliterations such as
+// "Яндекс Плюс" of "Yandex Plus", which score 0.77.
+const defaultMergeThreshold = 0.75
+
+// proposeMerge turns a cluster of similar names into a proposal. Names
+// linked to the catalog are the canonical spelling, otherwise the most
+// used name is.
+func proposeMerge(names []storage.ServiceNameUsage) ServiceMergeProposal {
+	target := names[0]
+	for _, name := range names[1:] {
+		if (name.ServiceID != nil) != (target.ServiceID != nil) {
+			if name.ServiceID != nil {
+				target = name
+			}
+			continue
+		}
+		if name.SubscriptionsCount > target.SubscriptionsCount {
+			target = name
+		}
+	}
+
+	proposal := ServiceMergeProposal{
+		Target:     target.Name,
+		Sources:    []string{},
+		Similarity: 1,
+		Names:      make([]ServiceNameUsage, len(names)),
+	}
+	folded := servicename.Fold(target.Name)
+	for i, name := range names {
+		proposal.Names[i] = ServiceNameUsage{
+			ServiceName:        name.Name,
+			ServiceID:          name.ServiceID,
+			SubscriptionsCount: name.SubscriptionsCount,
+		}
+		if name.Name == target.Name {
+			continue
+		}
+		proposal.Sources = append(proposal.Sources, name.Name)
+		proposal.Similarity = min(proposal.Similarity, servicename.Similarity(folded, servicename.Fold(name.Name)))
+	}
+	proposal.Similarity = math.Round(proposal.Similarity*100) / 100
+	return proposal
+}
+
+// ServiceMergeProposals godoc
+// @Summary Propose merges of service name spellings
+// @Description Cluster the distinct service names of subscriptions by similarity and propose a name for each cluster. Names are compared case folded, with accents dropped and Cyrillic transliterated, by edit distance relative to the longer name. The similarity of a proposal is that of its least similar source to the target. Proposals are applied with POST /services/merges.
+// @Tags services
+// @Produce json
+// @Param threshold query number false "Minimum similarity between 0 and 1" default(0.75)
+// @Success 200 {object} ServiceMergeProposalsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services/merge-proposals [get]
+func (h *Handler) ServiceMergeProposals(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageServices) {
+		return
+	}
+
+	threshold := defaultMergeThreshold
+	if s := r.URL.Query().Get("threshold"); s != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(s, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			response.RespondFieldError(w, r, "threshold", response.CodeOutOfRange, "threshold must be a number above 0 and at most 1")
+			return
+		}
+	}
+
+	usages, err := h.storage.ListServiceNames(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list service names", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	names := make([]string, len(usages))
+	for i, usage := range usages {
+		names[i] = usage.Name
+	}
+
+	proposals := []ServiceMergeProposal{}
+	for _, cluster := range servicename.Cluster(names, threshold) {
+		members := make([]storage.ServiceNameUsage, len(cluster))
+		for i, k := range cluster {
+			members[i] = usages[k]
+		}
+		proposals = append(proposals, proposeMerge(members))
+	}
+
+	response.RespondJSON(w, http.StatusOK, ServiceMergeProposalsResponse{
+		Threshold: threshold,
+		Proposals: proposals,
+	})
+}
+
+// MergeServices godoc
+// @Summary Merge service name spellings
+// @Description Rename the subscriptions named after any of the sources to the target in one transaction. A target in the catalog, by name or alias, is replaced by its canonical name and the subscriptions are linked to it. The merge and the previous name of every subscription are recorded, and every renamed subscription is published as updated.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param input body ServiceMergeRequest true "Merge"
+// @Success 201 {object} ServiceMergeResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services/merges [post]
+func (h *Handler) MergeServices(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageServices) {
+		return
+	}
+
+	var req ServiceMergeRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	merge := &models.ServiceMerge{
+		Target:   strings.TrimSpace(req.Target),
+		MergedBy: auth.PrincipalFromContext(r.Context()).ID,
+	}
+	if merge.Target == "" && !errs.Has("target") {
+		errs.Add("target", response.CodeRequired, "target is required")
+	}
+	for _, source := range req.Sources {
+		if source == "" {
+			errs.Add("sources", response.CodeInvalid, "sources must not be empty")
+			break
+		}
+		merge.Sources = append(merge.Sources, source)
+	}
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	if err := h.storage.MergeServiceNames(r.Context(), merge); err != nil {
+		if errors.Is(err, storage.ErrNothingToMerge) {
+			response.RespondFieldError(w, r, "sources", response.CodeUnknown, "no subscriptions to rename are named after the sources")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to merge services", "error", err, "target", merge.Target)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to merge services")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("services merged",
+		"merge_id", merge.ID,
+		"target", merge.Target,
+		"sources", merge.Sources,
+		"subscriptions_count", merge.SubscriptionsCount,
+	)
+
+	response.RespondJSON(w, http.StatusCreated, toServiceMergeResponse(merge))
+}
+
+// ListServiceMerges godoc
+// @Summary List applied service merges
+// @Description Get a paginated list of applied merges, most recent first
+// @Tags services
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10) maximum(100)
+// @Success 200 {object} ServiceMergeListResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /services/merges [get]
+func (h *Handler) ListServiceMerges(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageServices) {
+		return
+	}
+
+	page, limit := h.pagination(r)
+
+	result, err := h.storage.ListServiceMerges(r.Context(), storage.ServiceMergeListParams{
+		Page:  page,
+		Limit: limit,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list service merges", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list service merges")
+		return
+	}
+
+	data := make([]ServiceMergeResponse, len(result.Merges))
+	for i, merge := range result.Merges {
+		data[i] = toServiceMergeResponse(&merge)
+	}
+
+	response.RespondJSON(w, http.StatusOK, ServiceMergeListResponse{
+		Data: data,
+		Meta: ListMeta{
+			Page:       page,
+			Limit:      limit,
+			Total:      result.Total,
+			TotalPages: (result.Total + limit - 1) / limit,
+		},
+	})
+}
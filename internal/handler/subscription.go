@@ -1,21 +1,127 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
-	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/logging"
 	"github.com/seeques/subman/internal/models"
 	"github.com/seeques/subman/internal/response"
 	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/validate"
 )
 
+// ParseSubscriptionRequest validates req and turns it into a subscription
+// model, returning every invalid field when the request is invalid.
+// Whether the caller may access the user is left to the caller.
+func ParseSubscriptionRequest(req SubscriptionRequest) (*models.Subscription, response.FieldErrors) {
+	errs := checkTags(req.Tags, validate.Struct(req))
+	if errs != nil {
+		return nil, errs
+	}
+	return newSubscription(req), nil
+}
+
+// NormalizeLabel reduces a category or tag to the form it is stored and
+// filtered in: lower case, with spaces trimmed and collapsed.
+func NormalizeLabel(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// checkTags adds the tags the validate tags of the request can't check to
+// errs.
+func checkTags(tags []string, errs response.FieldErrors) response.FieldErrors {
+	for _, tag := range tags {
+		if n := utf8.RuneCountInString(NormalizeLabel(tag)); n == 0 || n > 64 {
+			errs.Add("tags", response.CodeInvalid, "tags must be between 1 and 64 characters")
+			break
+		}
+	}
+	return errs
+}
+
+// newSubscription turns a valid request into a subscription model.
+func newSubscription(req SubscriptionRequest) *models.Subscription {
+	sub := &models.Subscription{
+		ServiceName: req.ServiceName,
+		Category:    NormalizeLabel(req.Category),
+		Tags:        make([]string, len(req.Tags)),
+		Price:       req.Price,
+		UserID:      uuid.MustParse(req.UserID),
+	}
+	for i, tag := range req.Tags {
+		sub.Tags[i] = NormalizeLabel(tag)
+	}
+	sub.StartDate, _ = parseMonthYear(req.StartDate)
+	if req.EndDate != "" {
+		endDate, _ := parseMonthYear(req.EndDate)
+		sub.EndDate = &endDate
+	}
+	if req.TrialEndDate != "" {
+		trialEndDate, _ := parseMonthYear(req.TrialEndDate)
+		sub.TrialEndDate = &trialEndDate
+	}
+	return sub
+}
+
+// decodeSubscription reads and validates the subscription in the body of a
+// write request, writing a 400 or 403 and returning false when the request
+// is invalid or the caller may not access the user.
+func decodeSubscription(w http.ResponseWriter, r *http.Request) (*models.Subscription, bool) {
+	var req SubscriptionRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return nil, false
+	}
+	if errs = checkTags(req.Tags, errs); errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return nil, false
+	}
+
+	sub := newSubscription(req)
+	if !auth.CanAccessUser(r.Context(), sub.UserID) {
+		response.RespondError(w, r, http.StatusForbidden, response.CodeForeignUser, "access to other users is not allowed")
+		return nil, false
+	}
+	return sub, true
+}
+
+// ParsePeriod parses the months of a report period, formatted as MM-YYYY,
+// returning every invalid one.
+func ParsePeriod(start, end string) (time.Time, time.Time, response.FieldErrors) {
+	var errs response.FieldErrors
+	startPeriod, err := parseMonthYear(start)
+	if start == "" {
+		errs.Add("start_period", response.CodeRequired, "start_period is required")
+	} else if err != nil {
+		errs.Add("start_period", response.CodeInvalid, "invalid start_period, expected MM-YYYY")
+	}
+
+	endPeriod, err := parseMonthYear(end)
+	if end == "" {
+		errs.Add("end_period", response.CodeRequired, "end_period is required")
+	} else if err != nil {
+		errs.Add("end_period", response.CodeInvalid, "invalid end_period, expected MM-YYYY")
+	}
+
+	if errs == nil && endPeriod.Before(startPeriod) {
+		errs.Add("end_period", response.CodeOutOfRange, "end_period must be after start_period")
+	}
+	if errs != nil {
+		return time.Time{}, time.Time{}, errs
+	}
+	return startPeriod, endPeriod, nil
+}
+
 // Create godoc
 // @Summary Create a subscription
 // @Description Create a new subscription for a user
@@ -26,92 +132,45 @@ import (
 // @Success 201 {object} SubscriptionResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
 // @Router /subscriptions [post]
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
-	var req SubscriptionRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		slog.Warn("invalid JSON in request body", "error", err)
-		response.RespondError(w, http.StatusBadRequest, "invalid JSON")
+	if !authorize(w, r, auth.PermWriteSubscriptions) {
 		return
 	}
 
-	// Validation
-	if req.Price <= 0 {
-		response.RespondError(w, http.StatusBadRequest, "price must be more than zero")
+	sub, ok := decodeSubscription(w, r)
+	if !ok {
 		return
 	}
 
-	if req.ServiceName == "" || req.UserID == "" || req.StartDate == "" {
-		response.RespondError(w, http.StatusBadRequest, "request field is empty")
-		return
-	}
-
-	// Parse uuid
-	userID, err := uuid.Parse(req.UserID)
-	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid user_id, must be UUID")
-		return
-	}
-
-	// Parse dates to check if they match MM-YYYY format
-	startDate, err := parseMonthYear(req.StartDate)
-	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid start_date, expected MM-YYYY")
-		return
-	}
-
-	var endDate *time.Time
-	if req.EndDate != "" {
-		parsed, err := parseMonthYear(req.EndDate)
-		if err != nil {
-			response.RespondError(w, http.StatusBadRequest, "invalid end_date, expected MM-YYYY")
-			return
-		}
-		endDate = &parsed
-	}
-
+	// Create new subscription
 	ctx := r.Context()
-	sub := &models.Subscription{
-		ServiceName: req.ServiceName,
-		Price:       req.Price,
-		UserID:      userID,
-		StartDate:   startDate,
-		EndDate:     endDate,
+	err := h.storage.CreateSubscription(ctx, sub)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		response.RespondFieldError(w, r, "user_id", response.CodeUnknown, "user not found, create it under /users first")
+		return
 	}
-
-	// Create new subscription
-	err = h.storage.CreateSubscription(ctx, sub)
 	if err != nil {
-		slog.Error("failed to create subscription",
+		logging.FromContext(r.Context()).Error("failed to create subscription",
 			"error", err,
-			"service_name", req.ServiceName,
+			"service_name", sub.ServiceName,
 		)
-		response.RespondError(w, http.StatusInternalServerError, "failed to create subscriptions")
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to create subscriptions")
 		return
 	}
 
-	slog.Info("subscription created",
+	logging.FromContext(r.Context()).Info("subscription created",
 		"subscription_id", sub.ID,
 		"service_name", sub.ServiceName,
 	)
 
-	var subEndDate string
-	if sub.EndDate != nil {
-		subEndDate = sub.EndDate.Format("01-2006")
-	}
+	h.evaluateBudgets(ctx, sub.UserID)
 
 	// Make a response
-	response.RespondJSON(w, http.StatusCreated, SubscriptionResponse{
-		ID:          sub.ID,
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		UserID:      sub.UserID.String(),
-		StartDate:   sub.StartDate.Format("01-2006"),
-		EndDate:     &subEndDate,
-		CreatedAt:   sub.CreatedAt,
-		UpdatedAt:   sub.UpdatedAt,
-	})
+	response.RespondJSON(w, http.StatusCreated, toSubscriptionResponse(sub))
 }
 
 // GetById godoc
@@ -124,24 +183,34 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [get]
 func (h *Handler) GetById(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadSubscriptions) {
+		return
+	}
+
 	idStr := chi.URLParam(r, "id")
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid id")
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
 		return
 	}
 
 	sub, err := h.storage.GetSubscription(r.Context(), id)
+	if err == nil && !auth.CanAccessUser(r.Context(), sub.UserID) {
+		err = pgx.ErrNoRows
+	}
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			response.RespondError(w, http.StatusNotFound, "subbscription not found")
+			response.RespondError(w, r, http.StatusNotFound, response.CodeSubscriptionNotFound, "subscription not found")
 			return
 		}
-		slog.Error("failed to get subscription", "error", err, "id", id)
-		response.RespondError(w, http.StatusInternalServerError, "internal error")
+		logging.FromContext(r.Context()).Error("failed to get subscription", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
 		return
 	}
 
@@ -160,79 +229,51 @@ func (h *Handler) GetById(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [put]
 func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid id")
+	if !authorize(w, r, auth.PermWriteSubscriptions) {
 		return
 	}
 
-	var req SubscriptionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Warn("invalid JSON in request body", "error", err)
-		response.RespondError(w, http.StatusBadRequest, "invalid JSON")
-		return
-	}
-
-	// Validation
-	if req.Price <= 0 {
-		response.RespondError(w, http.StatusBadRequest, "price must be more than zero")
-		return
-	}
-
-	if req.ServiceName == "" || req.UserID == "" || req.StartDate == "" {
-		response.RespondError(w, http.StatusBadRequest, "request field is empty")
-		return
-	}
+	idStr := chi.URLParam(r, "id")
 
-	// Parse uuid
-	userID, err := uuid.Parse(req.UserID)
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid user_id, must be UUID")
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
 		return
 	}
 
-	// Parse dates to check if they match MM-YYYY format
-	startDate, err := parseMonthYear(req.StartDate)
-	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid start_date, expected MM-YYYY")
+	sub, ok := decodeSubscription(w, r)
+	if !ok {
 		return
 	}
 
-	var endDate *time.Time
-	if req.EndDate != "" {
-		parsed, err := parseMonthYear(req.EndDate)
-		if err != nil {
-			response.RespondError(w, http.StatusBadRequest, "invalid end_date, expected MM-YYYY")
-			return
-		}
-		endDate = &parsed
-	}
-
-	// model for database call
-	sub := &models.Subscription{
-		ID:          id,
-		ServiceName: req.ServiceName,
-		Price:       req.Price,
-		UserID:      userID,
-		StartDate:   startDate,
-		EndDate:     endDate,
+	// Restricted callers may only update their own subscriptions
+	if !h.ownsSubscription(w, r, id) {
+		return
 	}
 
+	sub.ID = id
 	if err := h.storage.UpdateSubscription(r.Context(), sub); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			response.RespondError(w, http.StatusNotFound, "subscription not found")
+			response.RespondError(w, r, http.StatusNotFound, response.CodeSubscriptionNotFound, "subscription not found")
+			return
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			response.RespondFieldError(w, r, "user_id", response.CodeUnknown, "user not found, create it under /users first")
 			return
 		}
-		slog.Error("failed to update subscription", "error", err, "id", id)
-		response.RespondError(w, http.StatusInternalServerError, "internal error")
+		logging.FromContext(r.Context()).Error("failed to update subscription", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
 		return
 	}
 
-	slog.Info("subscription updated", "id", sub.ID)
+	logging.FromContext(r.Context()).Info("subscription updated", "id", sub.ID)
+
+	h.evaluateBudgets(r.Context(), sub.UserID)
 
 	response.RespondJSON(w, http.StatusOK, toSubscriptionResponse(sub))
 }
@@ -246,28 +287,39 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [delete]
 func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteSubscriptions) {
+		return
+	}
+
 	idStr := chi.URLParam(r, "id")
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid id")
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	if !h.ownsSubscription(w, r, id) {
 		return
 	}
 
 	ctx := r.Context()
-	if err := h.storage.DeleteSubscription(ctx, id); err != nil {
+	if _, err := h.storage.DeleteSubscription(ctx, id); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			response.RespondError(w, http.StatusNotFound, "subscription not found")
+			response.RespondError(w, r, http.StatusNotFound, response.CodeSubscriptionNotFound, "subscription not found")
 			return
 		}
-		slog.Error("failed to delete subscription", "error", err, "id", id)
-		response.RespondError(w, http.StatusInternalServerError, "internal error")
+		logging.FromContext(r.Context()).Error("failed to delete subscription", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
 		return
 	}
 
-	slog.Info("subscription deleted", "id", id)
+	logging.FromContext(r.Context()).Info("subscription deleted", "id", id)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -277,39 +329,40 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 // @Description Get a paginated list of all subscriptions
 // @Tags subscriptions
 // @Produce json
+// @Param category query string false "Filter by category"
+// @Param tag query string false "Filter by tag"
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(10) maximum(100)
 // @Success 200 {object} ListResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
 // @Router /subscriptions [get]
 func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
-
-	// default to 1 page
-	if err != nil || page < 1 {
-		page = 1
+	if !authorize(w, r, auth.PermReadSubscriptions) {
+		return
 	}
 
-	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-
-	// default limit to 10
-	if err != nil || limit < 1 {
-		limit = 10
-	}
+	h.listSubscriptions(w, r, auth.RestrictedUserID(r.Context()))
+}
 
-	// cap limit to 100
-	if limit > 100 {
-		limit = 100
-	}
+// listSubscriptions writes a page of the subscriptions of userID, or of
+// every user when it is nil, filtered by the category and tag of the query.
+func (h *Handler) listSubscriptions(w http.ResponseWriter, r *http.Request, userID *uuid.UUID) {
+	page, limit := h.pagination(r)
 
 	result, err := h.storage.ListAllSubscriptions(r.Context(), storage.ListParams{
-		Page:  page,
-		Limit: limit,
+		Page:     page,
+		Limit:    limit,
+		UserID:   userID,
+		Category: NormalizeLabel(r.URL.Query().Get("category")),
+		Tag:      NormalizeLabel(r.URL.Query().Get("tag")),
 	})
 	if err != nil {
-		slog.Error("failed to list subscriptions",
+		logging.FromContext(r.Context()).Error("failed to list subscriptions",
 			"error", err)
-		response.RespondError(w, http.StatusInternalServerError, "failed to list subscriptions")
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list subscriptions")
 		return
 	}
 
@@ -333,41 +386,35 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 
 // TotalCost godoc
 // @Summary Calculate total subscription cost
-// @Description Calculate the total cost of subscriptions for a given period with optional filters
+// @Description Calculate the total cost of subscriptions for a given period with optional filters. Trial months are free and scheduled price changes apply from their effective month
 // @Tags subscriptions
 // @Produce json
 // @Param start_period query string true "Start of period (MM-YYYY)" example("01-2025")
 // @Param end_period query string true "End of period (MM-YYYY)" example("06-2025")
 // @Param user_id query string false "Filter by user ID (UUID)"
 // @Param service_name query string false "Filter by service name"
+// @Param category query string false "Filter by category"
+// @Param tag query string false "Filter by tag"
+// @Param group_by query string false "Break the total down by tag or category" Enums(tag, category)
 // @Success 200 {object} TotalCostResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
 // @Router /subscriptions/total-cost [get]
 func (h *Handler) TotalCost(w http.ResponseWriter, r *http.Request) {
-	// Parse required params
-	startPeriodStr := r.URL.Query().Get("start_period")
-	endPeriodStr := r.URL.Query().Get("end_period")
-
-	if startPeriodStr == "" || endPeriodStr == "" {
-		response.RespondError(w, http.StatusBadRequest, "start_period and end_period are required")
-		return
-	}
-
-	startPeriod, err := parseMonthYear(startPeriodStr)
-	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid start_period, expected MM-YYYY")
+	if !authorize(w, r, auth.PermReadReports) {
 		return
 	}
 
-	endPeriod, err := parseMonthYear(endPeriodStr)
-	if err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid end_period, expected MM-YYYY")
-		return
-	}
+	// Parse required params
+	startPeriodStr := r.URL.Query().Get("start_period")
+	endPeriodStr := r.URL.Query().Get("end_period")
 
-	if endPeriod.Before(startPeriod) {
-		response.RespondError(w, http.StatusBadRequest, "end_period must be after start_period")
+	startPeriod, endPeriod, errs := ParsePeriod(startPeriodStr, endPeriodStr)
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
 		return
 	}
 
@@ -380,38 +427,78 @@ func (h *Handler) TotalCost(w http.ResponseWriter, r *http.Request) {
 	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
 		userID, err := uuid.Parse(userIDStr)
 		if err != nil {
-			response.RespondError(w, http.StatusBadRequest, "invalid user_id format")
+			response.RespondFieldError(w, r, "user_id", response.CodeInvalid, "invalid user_id, must be UUID")
 			return
 		}
 		params.UserID = &userID
 	}
 
+	var ok bool
+	if params.UserID, ok = scopeReportFilter(w, r, params.UserID); !ok {
+		return
+	}
+
+	costFilters(r, &params)
+
+	h.respondTotalCost(w, r, params)
+}
+
+// costFilters sets the service name, category and tag filters of a cost
+// report from the query.
+func costFilters(r *http.Request, params *storage.TotalCostParams) {
 	params.ServiceName = r.URL.Query().Get("service_name")
+	params.Category = NormalizeLabel(r.URL.Query().Get("category"))
+	params.Tag = NormalizeLabel(r.URL.Query().Get("tag"))
+}
+
+// respondTotalCost writes the total cost of the subscriptions matching
+// params over their period, broken down by the group_by of the query.
+func (h *Handler) respondTotalCost(w http.ResponseWriter, r *http.Request, params storage.TotalCostParams) {
+	startPeriodStr := r.URL.Query().Get("start_period")
+	endPeriodStr := r.URL.Query().Get("end_period")
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy != "" && groupBy != "tag" && groupBy != "category" {
+		response.RespondFieldError(w, r, "group_by", response.CodeInvalid, "invalid group_by, expected tag or category")
+		return
+	}
 
 	// Get subscriptions for period
 	ctx := r.Context()
 	subs, err := h.storage.GetSubscriptionsForPeriod(ctx, params)
 	if err != nil {
-		slog.Error("failed to get subscriptions", "error", err)
-		response.RespondError(w, http.StatusInternalServerError, "internal error")
+		logging.FromContext(r.Context()).Error("failed to get subscriptions", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	changes, err := h.storage.GetPriceChanges(ctx, billing.SubscriptionIDs(subs))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to get price changes", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
 		return
 	}
 
 	// Calculate total cost of subscription
-	total := calculateTotalCost(subs, startPeriod, endPeriod)
+	total := billing.TotalCost(subs, changes, params.StartPeriod, params.EndPeriod)
 
-	slog.Info("total cost calculated",
+	logging.FromContext(r.Context()).Info("total cost calculated",
 		"start_period", startPeriodStr,
 		"end_period", endPeriodStr,
 		"subscriptions_count", len(subs),
 		"total_cost", total,
 	)
 
-	response.RespondJSON(w, http.StatusOK, TotalCostResponse{
+	resp := TotalCostResponse{
 		TotalCost:          total,
 		Currency:           "RUB",
 		PeriodStart:        startPeriodStr,
 		PeriodEnd:          endPeriodStr,
 		SubscriptionsCount: len(subs),
-	})
+	}
+	if groupBy != "" {
+		resp.GroupBy = groupBy
+		resp.Breakdown = buildCostBreakdown(subs, changes, groupBy, params.StartPeriod, params.EndPeriod)
+	}
+	response.RespondJSON(w, http.StatusOK, resp)
 }
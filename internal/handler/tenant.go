@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/tenant"
+)
+
+// CreateTenant godoc
+// @Summary Create a tenant
+// @Description Create a tenant whose data is isolated from every other tenant. Issue its first API key by calling POST /admin/api-keys with the X-Tenant-ID header. Operators only.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param input body TenantRequest true "Tenant data"
+// @Success 201 {object} TenantResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/tenants [post]
+func (h *Handler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req TenantRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	t := &models.Tenant{Name: strings.TrimSpace(req.Name)}
+	if t.Name == "" && !errs.Has("name") {
+		errs.Add("name", response.CodeRequired, "name is required")
+	}
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	if err := h.storage.CreateTenant(r.Context(), t); err != nil {
+		if errors.Is(err, storage.ErrTenantExists) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeTenantExists, "tenant already exists")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to create tenant", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to create tenant")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("tenant created", "tenant_id", t.ID, "name", t.Name)
+
+	response.RespondJSON(w, http.StatusCreated, toTenantResponse(t))
+}
+
+// ListTenants godoc
+// @Summary List tenants
+// @Description Operators only.
+// @Tags tenants
+// @Produce json
+// @Success 200 {array} TenantResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/tenants [get]
+func (h *Handler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.storage.ListTenants(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list tenants", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list tenants")
+		return
+	}
+
+	data := make([]TenantResponse, len(tenants))
+	for i, t := range tenants {
+		data[i] = toTenantResponse(&t)
+	}
+
+	response.RespondJSON(w, http.StatusOK, data)
+}
+
+// GetTenant godoc
+// @Summary Get a tenant by ID
+// @Description Operators only.
+// @Tags tenants
+// @Produce json
+// @Param id path string true "Tenant ID (UUID)"
+// @Success 200 {object} TenantResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/tenants/{id} [get]
+func (h *Handler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id, must be UUID")
+		return
+	}
+
+	t, err := h.storage.GetTenant(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeTenantNotFound, "tenant not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to get tenant", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	response.RespondJSON(w, http.StatusOK, toTenantResponse(t))
+}
+
+// DeleteTenant godoc
+// @Summary Delete a tenant
+// @Description Delete a tenant together with its API keys and event history. Its users, subscriptions, budgets and webhooks have to be deleted first. The default tenant can't be deleted. Operators only.
+// @Tags tenants
+// @Param id path string true "Tenant ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /admin/tenants/{id} [delete]
+func (h *Handler) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id, must be UUID")
+		return
+	}
+
+	if id == tenant.DefaultID {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeDefaultTenant, "the default tenant can't be deleted")
+		return
+	}
+
+	if err := h.storage.DeleteTenant(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeTenantNotFound, "tenant not found")
+			return
+		}
+		if errors.Is(err, storage.ErrTenantInUse) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeTenantNotEmpty, "tenant still has users, subscriptions, budgets or webhooks")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to delete tenant", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("tenant deleted", "tenant_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
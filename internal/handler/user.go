@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// parseUserRequest adds the checks the validate tags of req can't express
+// to errs and turns req into a user model when no field is invalid.
+func parseUserRequest(req UserRequest, errs response.FieldErrors) (*models.User, response.FieldErrors) {
+	user := &models.User{
+		DisplayName:     strings.TrimSpace(req.DisplayName),
+		DefaultCurrency: req.DefaultCurrency,
+		Timezone:        req.Timezone,
+	}
+	if user.DisplayName == "" && !errs.Has("display_name") {
+		errs.Add("display_name", response.CodeRequired, "display_name is required")
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	if req.ID != "" {
+		user.ID = uuid.MustParse(req.ID)
+	}
+	if req.Email != "" {
+		user.Email = &req.Email
+	}
+	if user.DefaultCurrency == "" {
+		user.DefaultCurrency = "RUB"
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	return user, nil
+}
+
+// userFromPath parses the user id of the path and checks that the user
+// exists and the caller may access it, writing a 400, 404 or 500 otherwise.
+// Users the caller may not access are reported as missing, like their
+// subscriptions.
+func (h *Handler) userFromPath(w http.ResponseWriter, r *http.Request, canAccess func(*http.Request, uuid.UUID) bool) (*models.User, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id, must be UUID")
+		return nil, false
+	}
+
+	user, err := h.storage.GetUser(r.Context(), id)
+	if err == nil && !canAccess(r, id) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeUserNotFound, "user not found")
+			return nil, false
+		}
+		logging.FromContext(r.Context()).Error("failed to get user", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return nil, false
+	}
+	return user, true
+}
+
+func canAccessUser(r *http.Request, id uuid.UUID) bool {
+	return auth.CanAccessUser(r.Context(), id)
+}
+
+func canReportOnUser(r *http.Request, id uuid.UUID) bool {
+	return auth.CanReportOnUser(r.Context(), id)
+}
+
+// CreateUser godoc
+// @Summary Create a user
+// @Description Create a user that subscriptions can belong to. The id is generated unless given, so users known by id elsewhere keep it.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body UserRequest true "User data"
+// @Success 201 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /users [post]
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteUsers) {
+		return
+	}
+
+	var req UserRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	user, errs := parseUserRequest(req, errs)
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	// Restricted callers may only create themselves
+	if restricted := auth.RestrictedUserID(r.Context()); restricted != nil {
+		if user.ID == uuid.Nil {
+			user.ID = *restricted
+		}
+		if !auth.CanAccessUser(r.Context(), user.ID) {
+			response.RespondError(w, r, http.StatusForbidden, response.CodeForeignUser, "access to other users is not allowed")
+			return
+		}
+	}
+
+	if err := h.storage.CreateUser(r.Context(), user); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeUserExists, "user already exists")
+			return
+		}
+		if errors.Is(err, storage.ErrEmailTaken) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeEmailTaken, "email is taken by another user")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to create user", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to create user")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("user created", "user_id", user.ID)
+
+	response.RespondJSON(w, http.StatusCreated, toUserResponse(user))
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description Get a paginated list of users ordered by display name
+// @Tags users
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10) maximum(100)
+// @Success 200 {object} UserListResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /users [get]
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadUsers) {
+		return
+	}
+
+	page, limit := h.pagination(r)
+
+	result, err := h.storage.ListUsers(r.Context(), storage.UserListParams{
+		Page:  page,
+		Limit: limit,
+		ID:    auth.RestrictedUserID(r.Context()),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list users", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list users")
+		return
+	}
+
+	data := make([]UserResponse, len(result.Users))
+	for i, user := range result.Users {
+		data[i] = toUserResponse(&user)
+	}
+
+	response.RespondJSON(w, http.StatusOK, UserListResponse{
+		Data: data,
+		Meta: ListMeta{
+			Page:       page,
+			Limit:      limit,
+			Total:      result.Total,
+			TotalPages: (result.Total + limit - 1) / limit,
+		},
+	})
+}
+
+// GetUser godoc
+// @Summary Get a user by ID
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /users/{id} [get]
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadUsers) {
+		return
+	}
+
+	user, ok := h.userFromPath(w, r, canAccessUser)
+	if !ok {
+		return
+	}
+
+	response.RespondJSON(w, http.StatusOK, toUserResponse(user))
+}
+
+// UpdateUser godoc
+// @Summary Update a user
+// @Description Update the profile of a user. The id can't be changed, so the id of the body must be empty or match the path.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Param input body UserRequest true "Updated user data"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /users/{id} [put]
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteUsers) {
+		return
+	}
+
+	existing, ok := h.userFromPath(w, r, canAccessUser)
+	if !ok {
+		return
+	}
+
+	var req UserRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	user, errs := parseUserRequest(req, errs)
+	if errs == nil && user.ID != uuid.Nil && user.ID != existing.ID {
+		errs.Add("id", response.CodeInvalid, "id can't be changed")
+	}
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	user.ID = existing.ID
+	if err := h.storage.UpdateUser(r.Context(), user); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeUserNotFound, "user not found")
+			return
+		}
+		if errors.Is(err, storage.ErrEmailTaken) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeEmailTaken, "email is taken by another user")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to update user", "error", err, "id", user.ID)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("user updated", "user_id", user.ID)
+
+	response.RespondJSON(w, http.StatusOK, toUserResponse(user))
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Delete a user. Users with subscriptions are kept until their subscriptions are deleted.
+// @Tags users
+// @Param id path string true "User ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /users/{id} [delete]
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermWriteUsers) {
+		return
+	}
+
+	user, ok := h.userFromPath(w, r, canAccessUser)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteUser(r.Context(), user.ID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeUserNotFound, "user not found")
+			return
+		}
+		if errors.Is(err, storage.ErrUserInUse) {
+			response.RespondError(w, r, http.StatusConflict, response.CodeUserInUse, "user still has subscriptions")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to delete user", "error", err, "id", user.ID)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("user deleted", "user_id", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UserSubscriptions godoc
+// @Summary List the subscriptions of a user
+// @Description Get a paginated list of the subscriptions of a user
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Param category query string false "Filter by category"
+// @Param tag query string false "Filter by tag"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10) maximum(100)
+// @Success 200 {object} ListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /users/{id}/subscriptions [get]
+func (h *Handler) UserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadSubscriptions) {
+		return
+	}
+
+	user, ok := h.userFromPath(w, r, canAccessUser)
+	if !ok {
+		return
+	}
+
+	h.listSubscriptions(w, r, &user.ID)
+}
+
+// UserTotalCost godoc
+// @Summary Calculate the total subscription cost of a user
+// @Description Calculate the total cost of the subscriptions of a user for a given period
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Param start_period query string true "Start of period (MM-YYYY)" example("01-2025")
+// @Param end_period query string true "End of period (MM-YYYY)" example("06-2025")
+// @Param service_name query string false "Filter by service name"
+// @Param category query string false "Filter by category"
+// @Param tag query string false "Filter by tag"
+// @Param group_by query string false "Break the total down by tag or category" Enums(tag, category)
+// @Success 200 {object} TotalCostResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /users/{id}/total-cost [get]
+func (h *Handler) UserTotalCost(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReadReports) {
+		return
+	}
+
+	user, ok := h.userFromPath(w, r, canReportOnUser)
+	if !ok {
+		return
+	}
+
+	startPeriod, endPeriod, errs := ParsePeriod(r.URL.Query().Get("start_period"), r.URL.Query().Get("end_period"))
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	params := storage.TotalCostParams{
+		StartPeriod: startPeriod,
+		EndPeriod:   endPeriod,
+		UserID:      &user.ID,
+	}
+	costFilters(r, &params)
+
+	h.respondTotalCost(w, r, params)
+}
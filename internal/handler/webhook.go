@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+)
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// CreateWebhook godoc
+// @Summary Register a webhook endpoint
+// @Description Register a URL that receives subscription lifecycle events. Payloads are signed with HMAC-SHA256 of "<X-Subman-Timestamp>.<body>" using the endpoint secret and sent in the X-Subman-Signature header. A secret is generated when none is given; it is only returned on creation.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param input body WebhookRequest true "Webhook endpoint data"
+// @Success 201 {object} WebhookResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks [post]
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageWebhooks) {
+		return
+	}
+
+	var req WebhookRequest
+	errs, ok := decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if req.URL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		errs.Add("url", response.CodeInvalid, "invalid url, expected absolute http(s) URL")
+	}
+	for _, eventType := range req.EventTypes {
+		if !events.IsType(eventType) {
+			errs.Add("event_types", response.CodeUnknown, "unknown event type: "+eventType)
+		}
+	}
+
+	if errs != nil {
+		response.RespondValidationError(w, r, errs)
+		return
+	}
+
+	if req.Secret == "" {
+		req.Secret, err = generateWebhookSecret()
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to generate webhook secret", "error", err)
+			response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+			return
+		}
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+		Active:     true,
+	}
+
+	if err := h.storage.CreateWebhookEndpoint(r.Context(), endpoint); err != nil {
+		logging.FromContext(r.Context()).Error("failed to create webhook endpoint", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to create webhook")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("webhook endpoint created", "webhook_id", endpoint.ID, "event_types", endpoint.EventTypes)
+
+	resp := toWebhookResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	response.RespondJSON(w, http.StatusCreated, resp)
+}
+
+// ListWebhooks godoc
+// @Summary List webhook endpoints
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} WebhookResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks [get]
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageWebhooks) {
+		return
+	}
+
+	endpoints, err := h.storage.ListWebhookEndpoints(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list webhook endpoints", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list webhooks")
+		return
+	}
+
+	data := make([]WebhookResponse, len(endpoints))
+	for i, endpoint := range endpoints {
+		data[i] = toWebhookResponse(&endpoint)
+	}
+
+	response.RespondJSON(w, http.StatusOK, data)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook endpoint by ID
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} WebhookResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks/{id} [get]
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageWebhooks) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	endpoint, err := h.storage.GetWebhookEndpoint(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeWebhookNotFound, "webhook not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to get webhook endpoint", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	response.RespondJSON(w, http.StatusOK, toWebhookResponse(endpoint))
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook endpoint
+// @Description Delete a webhook endpoint together with its queued and dead deliveries
+// @Tags webhooks
+// @Param id path int true "Webhook ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageWebhooks) {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	if err := h.storage.DeleteWebhookEndpoint(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeWebhookNotFound, "webhook not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to delete webhook endpoint", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("webhook endpoint deleted", "id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeadLetters godoc
+// @Summary List dead webhook deliveries
+// @Description Get a paginated list of deliveries that exhausted their retries
+// @Tags webhooks
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10) maximum(100)
+// @Success 200 {object} WebhookDeliveryListResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks/dead-letters [get]
+func (h *Handler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageWebhooks) {
+		return
+	}
+
+	page, limit := h.pagination(r)
+
+	result, err := h.storage.ListDeadWebhookDeliveries(r.Context(), storage.ListParams{
+		Page:  page,
+		Limit: limit,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list dead webhook deliveries", "error", err)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "failed to list dead letters")
+		return
+	}
+
+	data := make([]WebhookDeliveryResponse, len(result.Deliveries))
+	for i, delivery := range result.Deliveries {
+		data[i] = toWebhookDeliveryResponse(&delivery)
+	}
+
+	response.RespondJSON(w, http.StatusOK, WebhookDeliveryListResponse{
+		Data: data,
+		Meta: ListMeta{
+			Page:       page,
+			Limit:      limit,
+			Total:      result.Total,
+			TotalPages: (result.Total + limit - 1) / limit,
+		},
+	})
+}
+
+// RetryDeadLetter godoc
+// @Summary Retry a dead webhook delivery
+// @Description Move a dead delivery back to the queue with a fresh set of retries
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 202 {object} WebhookDeliveryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks/dead-letters/{id}/retry [post]
+func (h *Handler) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageWebhooks) {
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		response.RespondError(w, r, http.StatusBadRequest, response.CodeInvalidID, "invalid id")
+		return
+	}
+
+	delivery, err := h.storage.RetryWebhookDelivery(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.RespondError(w, r, http.StatusNotFound, response.CodeDeadLetterNotFound, "dead letter not found")
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to retry webhook delivery", "error", err, "id", id)
+		response.RespondError(w, r, http.StatusInternalServerError, response.CodeInternal, "internal error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("dead webhook delivery requeued", "delivery_id", id)
+
+	response.RespondJSON(w, http.StatusAccepted, toWebhookDeliveryResponse(delivery))
+}
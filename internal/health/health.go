@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/seeques/subman/internal/response"
+	"github.com/seeques/subman/internal/storage"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// checkTimeout bounds each readiness check, so a hanging database fails
+// the probe instead of timing it out.
+const checkTimeout = 2 * time.Second
+
+// Component is the state of a dependency of the service.
+type Component struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	// Version and ExpectedVersion are set for the migrations component
+	Version         *uint `json:"version,omitempty"`
+	ExpectedVersion *uint `json:"expected_version,omitempty"`
+}
+
+type Report struct {
+	Status     string               `json:"status"`
+	Draining   bool                 `json:"draining,omitempty"`
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+// Checker answers liveness and readiness probes.
+type Checker struct {
+	storage         *storage.PostgresStorage
+	expectedVersion uint
+	draining        atomic.Bool
+}
+
+// NewChecker returns a checker reporting ready while the database is
+// reachable and migrated to at least expectedVersion.
+func NewChecker(storage *storage.PostgresStorage, expectedVersion uint) *Checker {
+	return &Checker{storage: storage, expectedVersion: expectedVersion}
+}
+
+// Drain makes readiness fail from now on, so load balancers stop sending
+// new requests before the server shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Liveness reports that the process is up and serving requests.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	response.RespondJSON(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Readiness reports whether the service can take traffic, with the state
+// of every dependency. It responds 503 while draining or when a dependency
+// is unavailable.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := Report{
+		Status:   StatusOK,
+		Draining: c.draining.Load(),
+		Components: map[string]Component{
+			"database":   c.checkDatabase(r.Context()),
+			"migrations": c.checkMigrations(r.Context()),
+		},
+	}
+
+	if report.Draining {
+		report.Status = StatusUnavailable
+	}
+	for _, component := range report.Components {
+		if component.Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	response.RespondJSON(w, status, report)
+}
+
+func (c *Checker) checkDatabase(ctx context.Context) Component {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.storage.Ping(ctx)
+	return component(start, err)
+}
+
+func (c *Checker) checkMigrations(ctx context.Context) Component {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	version, dirty, err := c.storage.MigrationVersion(ctx)
+	if err == nil {
+		switch {
+		case dirty:
+			err = fmt.Errorf("migration %d failed and has to be fixed manually", version)
+		// a newer schema is fine, it's what a rolling deploy of the next
+		// release leaves behind
+		case version < c.expectedVersion:
+			err = fmt.Errorf("schema is at version %d, expected %d", version, c.expectedVersion)
+		}
+	}
+
+	result := component(start, err)
+	if version > 0 {
+		result.Version = &version
+	}
+	result.ExpectedVersion = &c.expectedVersion
+	return result
+}
+
+func component(start time.Time, err error) Component {
+	result := Component{Status: StatusOK, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Setup makes the default logger write format, json or text, to stdout,
+// dropping records below level: debug, info, warn or error.
+func Setup(format, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// requestLogger is the logger of a request. Middlewares further down the
+// chain add attributes as they learn about the request, e.g. the
+// principal, and the access log written at the end carries them too.
+type requestLogger struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+type loggerKey struct{}
+
+func withRequestLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, &requestLogger{logger: logger})
+}
+
+// NewContext returns a copy of ctx carrying logger as the logger of a
+// request, for servers other than the HTTP one, see Middleware.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return withRequestLogger(ctx, logger)
+}
+
+// FromContext returns the logger of the request ctx belongs to, carrying
+// its request ID, or the default logger outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	rl, ok := ctx.Value(loggerKey{}).(*requestLogger)
+	if !ok {
+		return slog.Default()
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.logger
+}
+
+// With adds attributes to every later record logged for the request ctx
+// belongs to, including its access log. It does nothing outside of a
+// request.
+func With(ctx context.Context, args ...any) {
+	rl, ok := ctx.Value(loggerKey{}).(*requestLogger)
+	if !ok {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.logger = rl.logger.With(args...)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// Run refreshes the domain gauges every interval until ctx is cancelled.
+// They are computed in the background rather than at scrape time, as that
+// means loading every active subscription. ctx has to cover all tenants.
+func (m *Metrics) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.refreshDomain(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to refresh domain metrics", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshDomain counts the subscriptions active in the current month and
+// what they cost per tenant.
+func (m *Metrics) refreshDomain(ctx context.Context) error {
+	month := billing.CurrentMonth()
+
+	subs, err := m.storage.GetSubscriptionsForPeriod(ctx, storage.TotalCostParams{
+		StartPeriod: month,
+		EndPeriod:   month,
+	})
+	if err != nil {
+		return err
+	}
+
+	changes, err := m.storage.GetPriceChanges(ctx, billing.SubscriptionIDs(subs))
+	if err != nil {
+		return err
+	}
+
+	active := make(map[uuid.UUID]int)
+	spend := make(map[uuid.UUID]int)
+	for _, sub := range subs {
+		active[sub.TenantID]++
+		spend[sub.TenantID] += billing.MonthlyPrice(&sub, changes[sub.ID], month)
+	}
+
+	// drop tenants that no longer have active subscriptions
+	m.activeSubscriptions.Reset()
+	m.monthlySpend.Reset()
+	for tenantID, count := range active {
+		m.activeSubscriptions.WithLabelValues(tenantID.String()).Set(float64(count))
+		m.monthlySpend.WithLabelValues(tenantID.String()).Set(float64(spend[tenantID]))
+	}
+	return nil
+}
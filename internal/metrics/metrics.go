@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/seeques/subman/internal/storage"
+)
+
+const namespace = "subman"
+
+// Metrics collects HTTP, database pool and domain metrics and serves them
+// in the Prometheus format.
+type Metrics struct {
+	registry *prometheus.Registry
+	storage  *storage.PostgresStorage
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+
+	activeSubscriptions *prometheus.GaugeVec
+	monthlySpend        *prometheus.GaugeVec
+}
+
+func New(pool *pgxpool.Pool, storage *storage.PostgresStorage) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		storage:  storage,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+		activeSubscriptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_subscriptions",
+			Help:      "Subscriptions active in the current month by tenant.",
+		}, []string{"tenant_id"}),
+		monthlySpend: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "monthly_recurring_spend",
+			Help:      "What active subscriptions cost in the current month by tenant, after trials and price changes.",
+		}, []string{"tenant_id"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newPoolCollector(pool),
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.activeSubscriptions,
+		m.monthlySpend,
+	)
+	return m
+}
+
+// Handler serves the collected metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,301 @@
+// Package migrate applies the embedded SQL migrations. It keeps track of
+// the schema version in the schema_migrations table the same way
+// golang-migrate does, so databases migrated with either tool can be
+// managed with the other.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// lockID is the key of the session advisory lock held while migrating, so
+// instances migrating on start don't race each other.
+const lockID int64 = 0x7375626d616e // "subman"
+
+// ErrDirty is returned when a migration failed halfway, e.g. when run by
+// golang-migrate, and the schema has to be fixed by hand.
+var ErrDirty = errors.New("database is dirty, fix the schema by hand and run migrate force with the version it is at")
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a pair of up and down SQL scripts.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the migrations in fsys, named like 000001_create_subscription.up.sql,
+// ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q", entry.Name())
+		}
+		sql, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration: %w", err)
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = m
+		}
+		if match[3] == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return int(a.Version) - int(b.Version) })
+	return migrations, nil
+}
+
+// Migrator applies migrations over a single connection, which holds the
+// advisory lock.
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+}
+
+func New(ctx context.Context, databaseURL string, migrations []Migration) (*Migrator, error) {
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`
+	if _, err := conn.Exec(ctx, query); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+func (m *Migrator) Close(ctx context.Context) error {
+	return m.conn.Close(ctx)
+}
+
+// Version returns the current schema version, zero before the first
+// migration, and whether the last migration failed halfway.
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := m.conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("get version: %w", err)
+	}
+	return uint(version), dirty, nil
+}
+
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the version of the newest migration.
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.Goto(ctx, m.Latest())
+}
+
+// Down reverts the last steps migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(current uint) error {
+		i := m.index(current)
+		if i < 0 && current != 0 {
+			return fmt.Errorf("unknown schema version %d", current)
+		}
+
+		target := uint(0)
+		if i-steps >= 0 {
+			target = m.migrations[i-steps].Version
+		}
+		return m.migrate(ctx, current, target)
+	})
+}
+
+// Goto migrates up or down to version, zero reverting every migration.
+func (m *Migrator) Goto(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("no migration with version %d", version)
+	}
+
+	return m.withLock(ctx, func(current uint) error {
+		if current != 0 && m.index(current) < 0 {
+			return fmt.Errorf("unknown schema version %d", current)
+		}
+		return m.migrate(ctx, current, version)
+	})
+}
+
+// Force records version as the current one and clears the dirty flag
+// without running any migration.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("no migration with version %d", version)
+	}
+
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.unlock(ctx)
+
+	return pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		return setVersion(ctx, tx, version)
+	})
+}
+
+// migrate runs the up scripts from current to target, or the down scripts
+// back to it. Every migration runs in its own transaction together with
+// the version update, so a failing one leaves the schema at the previous
+// version rather than dirty.
+func (m *Migrator) migrate(ctx context.Context, current, target uint) error {
+	if target == current {
+		slog.Info("schema is up to date", "version", current)
+		return nil
+	}
+
+	for _, step := range m.steps(current, target) {
+		direction, script, version := "up", step.Up, step.Version
+		if target < current {
+			direction, script, version = "down", step.Down, m.previous(step.Version)
+		}
+
+		slog.Info("applying migration", "version", step.Version, "name", step.Name, "direction", direction)
+
+		err := pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, script); err != nil {
+				return err
+			}
+			return setVersion(ctx, tx, version)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d_%s %s: %w", step.Version, step.Name, direction, err)
+		}
+	}
+
+	slog.Info("schema migrated", "from", current, "to", target)
+	return nil
+}
+
+// steps returns the migrations between current and target in the order
+// they have to run.
+func (m *Migrator) steps(current, target uint) []Migration {
+	var steps []Migration
+	if target > current {
+		for _, migration := range m.migrations {
+			if migration.Version > current && migration.Version <= target {
+				steps = append(steps, migration)
+			}
+		}
+		return steps
+	}
+
+	for _, migration := range slices.Backward(m.migrations) {
+		if migration.Version <= current && migration.Version > target {
+			steps = append(steps, migration)
+		}
+	}
+	return steps
+}
+
+// withLock runs fn with the current version while holding the advisory
+// lock, refusing to touch a dirty database.
+func (m *Migrator) withLock(ctx context.Context, fn func(current uint) error) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.unlock(ctx)
+
+	current, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("version %d: %w", current, ErrDirty)
+	}
+	return fn(current)
+}
+
+// lock waits until no other instance is migrating.
+func (m *Migrator) lock(ctx context.Context) error {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) unlock(ctx context.Context) {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+		slog.Error("failed to release migration lock", "error", err)
+	}
+}
+
+func (m *Migrator) index(version uint) int {
+	return slices.IndexFunc(m.migrations, func(migration Migration) bool {
+		return migration.Version == version
+	})
+}
+
+// previous returns the version before version, zero for the first one.
+func (m *Migrator) previous(version uint) uint {
+	if i := m.index(version); i > 0 {
+		return m.migrations[i-1].Version
+	}
+	return 0
+}
+
+func setVersion(ctx context.Context, tx pgx.Tx, version uint) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("set version: %w", err)
+	}
+	if version == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, int64(version)); err != nil {
+		return fmt.Errorf("set version: %w", err)
+	}
+	return nil
+}
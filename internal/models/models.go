@@ -6,13 +6,197 @@ import (
 	"github.com/google/uuid"
 )
 
+// Tenant is a department whose data is isolated from every other tenant.
+type Tenant struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+// User owns subscriptions and budgets. Its id is the user_id of their
+// records, and may be chosen by clients to match their own user ids.
+type User struct {
+	ID              uuid.UUID
+	TenantID        uuid.UUID
+	DisplayName     string
+	Email           *string
+	DefaultCurrency string
+	// Timezone is an IANA time zone name, e.g. Europe/Moscow
+	Timezone  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type Subscription struct {
 	ID          int
+	TenantID    uuid.UUID
 	ServiceName string
-	Price       int
-	UserID      uuid.UUID
-	StartDate   time.Time
-	EndDate     *time.Time
+	// ServiceID is the catalog entry ServiceName resolved to, if any
+	ServiceID *int
+	// Category and Tags group spend, e.g. "entertainment" or "cloud"
+	Category     string
+	Tags         []string
+	Price        int
+	UserID       uuid.UUID
+	StartDate    time.Time
+	EndDate      *time.Time
+	TrialEndDate *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Service is a catalog entry. Subscriptions naming the service or any of
+// its aliases are linked to it and take its name.
+type Service struct {
+	ID        int
+	TenantID  uuid.UUID
+	Name      string
+	Aliases   []string
+	Category  string
+	Vendor    string
+	Website   string
+	Plans     []ServicePlan
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ServicePlan is a plan a service offers with its default monthly price.
+// It is stored as JSON.
+type ServicePlan struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+// ServiceMerge records that the subscriptions named after Sources were
+// renamed to Target, linked to ServiceID when Target is in the catalog.
+type ServiceMerge struct {
+	ID                 int
+	TenantID           uuid.UUID
+	Target             string
+	Sources            []string
+	ServiceID          *int
+	SubscriptionsCount int
+	// MergedBy is the principal that applied the merge
+	MergedBy  string
+	CreatedAt time.Time
+}
+
+// PriceChange is a scheduled price for a subscription, effective from the
+// first day of EffectiveDate's month onwards.
+type PriceChange struct {
+	ID             int
+	SubscriptionID int
+	Price          int
+	EffectiveDate  time.Time
+	CreatedAt      time.Time
+}
+
+const (
+	BudgetPeriodMonthly = "monthly"
+	BudgetPeriodYearly  = "yearly"
+)
+
+// Budget caps the spend of a single user per calendar month or year.
+// Thresholds are percentages of Limit that trigger a breach once reached.
+type Budget struct {
+	ID         int
+	TenantID   uuid.UUID
+	UserID     uuid.UUID
+	Period     string
+	Limit      int
+	Currency   string
+	Thresholds []int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// BudgetBreach records that a budget threshold was reached in a period.
+// Each threshold is recorded at most once per period.
+type BudgetBreach struct {
+	ID          int
+	BudgetID    int
+	Threshold   int
+	PeriodStart time.Time
+	Spent       int
+	Limit       int
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
+}
+
+// WebhookEndpoint is a URL that receives signed subscription lifecycle events.
+type WebhookEndpoint struct {
+	ID         int
+	URL        string
+	Secret     string
+	EventTypes []string
+	Active     bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// WebhookDelivery is a single event queued for a single endpoint.
+type WebhookDelivery struct {
+	ID            int64
+	EndpointID    int
+	EventID       uuid.UUID
+	EventType     string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     *string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// OutboxEntry is an event stored in the same transaction as the change that
+// caused it, waiting to be handed to the sinks.
+type OutboxEntry struct {
+	ID          int64
+	TenantID    uuid.UUID
+	EventID     uuid.UUID
+	EventType   string
+	AggregateID int
+	Payload     []byte
+	Attempts    int
+	CreatedAt   time.Time
+}
+
+// SubscriptionChange is a row level change to a subscription recorded by a
+// database trigger. Subscription holds the row after the change, or before
+// it for deletes.
+type SubscriptionChange struct {
+	ID           int64
+	Operation    string
+	Subscription Subscription
+	CreatedAt    time.Time
+}
+
+// APIKey grants the holder of the matching secret the listed scopes.
+// Only a hash of the secret is stored; Prefix identifies the key.
+type APIKey struct {
+	ID         int
+	TenantID   uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    []byte
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// RoleAssignment grants a role to a principal within a tenant. Principal is
+// the authenticated caller id, e.g. "apikey:12" or "jwt:<sub>".
+type RoleAssignment struct {
+	ID        int
+	TenantID  uuid.UUID
+	Principal string
+	Role      string
+	CreatedAt time.Time
 }
@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/seeques/subman/internal/billing"
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/tenant"
+)
+
+const (
+	batchSize = 100
+	// How long claimed entries are hidden from other relays while they are published
+	leaseDuration = 30 * time.Second
+	maxRetryDelay = 5 * time.Minute
+	// Entries are parked as dead after this many failed publishes, about an
+	// hour after they were written
+	maxAttempts = 20
+	// How long processed entries and delivered webhooks are kept
+	retention = 7 * 24 * time.Hour
+)
+
+// Relay hands events written to the outbox to the sinks. Several relays can
+// run against the same database; leasing makes sure each batch is only
+// handled by one of them at a time.
+type Relay struct {
+	storage *storage.PostgresStorage
+	sinks   []Sink
+}
+
+func NewRelay(storage *storage.PostgresStorage, sinks ...Sink) *Relay {
+	return &Relay{
+		storage: storage,
+		sinks:   sinks,
+	}
+}
+
+// Run publishes outbox entries every interval, and hourly queues ending soon
+// events and prunes what was published, until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastScan time.Time
+	for {
+		if time.Since(lastScan) >= time.Hour {
+			if err := r.QueueEndingSoon(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed to queue ending soon events", "error", err)
+			}
+			if err := r.Prune(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed to prune outbox", "error", err)
+			}
+			lastScan = time.Now()
+		}
+
+		if err := r.PublishPending(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to relay outbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending publishes every unprocessed entry that is not leased.
+func (r *Relay) PublishPending(ctx context.Context) error {
+	for {
+		entries, err := r.storage.ClaimOutbox(ctx, batchSize, leaseDuration)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			r.publish(ctx, entry)
+		}
+
+		if len(entries) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, entry models.OutboxEntry) {
+	// sinks only see the data of the tenant the event belongs to
+	ctx = tenant.WithTenant(ctx, entry.TenantID)
+
+	var event events.Event
+	err := json.Unmarshal(entry.Payload, &event)
+	if err != nil {
+		err = fmt.Errorf("unmarshal event: %w", err)
+	}
+
+	if err == nil {
+		for _, sink := range r.sinks {
+			if sinkErr := sink.Publish(ctx, event); sinkErr != nil {
+				err = errors.Join(err, fmt.Errorf("%s sink: %w", sink.Name(), sinkErr))
+			}
+		}
+	}
+
+	if err != nil {
+		attempts := entry.Attempts + 1
+		dead := attempts >= maxAttempts
+		retryIn := retryDelay(attempts)
+
+		if dead {
+			slog.Error("outbox entry parked as dead after repeated failures",
+				"error", err,
+				"outbox_id", entry.ID,
+				"event_type", entry.EventType,
+				"attempts", attempts,
+			)
+		} else {
+			slog.Warn("failed to publish outbox entry",
+				"error", err,
+				"outbox_id", entry.ID,
+				"event_type", entry.EventType,
+				"attempts", attempts,
+				"retry_in", retryIn,
+			)
+		}
+		if err := r.storage.MarkOutboxFailed(ctx, entry.ID, err.Error(), retryIn, dead); err != nil {
+			slog.Error("failed to mark outbox entry failed", "error", err, "outbox_id", entry.ID)
+		}
+		return
+	}
+
+	if err := r.storage.MarkOutboxProcessed(ctx, entry.ID); err != nil {
+		slog.Error("failed to mark outbox entry processed", "error", err, "outbox_id", entry.ID)
+	}
+}
+
+// QueueEndingSoon writes subscription.ending_soon events for subscriptions
+// whose last paid month is the current or the next one.
+func (r *Relay) QueueEndingSoon(ctx context.Context) error {
+	from := billing.CurrentMonth()
+	queued, err := r.storage.QueueEndingSoonEvents(ctx, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		slog.Info("ending soon events queued", "count", queued)
+	}
+	return nil
+}
+
+// Prune deletes outbox entries and webhook deliveries that were handled
+// longer than retention ago. Dead entries are kept for inspection.
+func (r *Relay) Prune(ctx context.Context) error {
+	entries, err := r.storage.DeleteProcessedOutbox(ctx, retention)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := r.storage.DeleteDeliveredWebhookDeliveries(ctx, retention)
+	if err != nil {
+		return err
+	}
+
+	if entries > 0 || deliveries > 0 {
+		slog.Info("pruned outbox", "entries", entries, "webhook_deliveries", deliveries)
+	}
+	return nil
+}
+
+// retryDelay doubles the wait after every failed attempt, up to maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := time.Second << attempts
+	if delay <= 0 || delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/webhook"
+)
+
+// Sink receives every event written to the outbox. Publish may be called
+// more than once for the same event, e.g. when another sink failed or the
+// relay crashed before marking the event processed, so sinks should be
+// idempotent on Event.ID.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event events.Event) error
+}
+
+// WebhookSink queues events for the registered webhook endpoints.
+type WebhookSink struct {
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookSink(dispatcher *webhook.Dispatcher) *WebhookSink {
+	return &WebhookSink{dispatcher: dispatcher}
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Publish(ctx context.Context, event events.Event) error {
+	return s.dispatcher.Emit(ctx, event)
+}
+
+// LogSink writes every event to the application log.
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Publish(ctx context.Context, event events.Event) error {
+	slog.Info("subscription event",
+		"event_id", event.ID,
+		"event_type", event.Type,
+		"subscription_id", event.Data.ID,
+		"user_id", event.Data.UserID,
+	)
+	return nil
+}
+
+// FileSink appends every event as a line of JSON to a file.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open outbox file sink: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Publish(ctx context.Context, event events.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// NewSinks builds the sinks named in names. The returned close function
+// releases resources held by the sinks, such as open files.
+func NewSinks(names []string, filePath string, dispatcher *webhook.Dispatcher) ([]Sink, func() error, error) {
+	var sinks []Sink
+	var files []*FileSink
+
+	closeAll := func() error {
+		var err error
+		for _, f := range files {
+			err = errors.Join(err, f.Close())
+		}
+		return err
+	}
+
+	for _, name := range names {
+		switch name {
+		case "webhook":
+			sinks = append(sinks, NewWebhookSink(dispatcher))
+		case "log":
+			sinks = append(sinks, LogSink{})
+		case "file":
+			sink, err := NewFileSink(filePath)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			files = append(files, sink)
+			sinks = append(sinks, sink)
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, closeAll, nil
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route groups limited separately, each client has a bucket per group.
+const (
+	GroupDefault = "default"
+	// GroupReports covers total cost, forecasts and budget status
+	GroupReports = "reports"
+	// GroupStream covers opening event streams
+	GroupStream = "stream"
+	// GroupAdmin covers webhooks, API keys, roles and tenants
+	GroupAdmin = "admin"
+	// GroupAuth covers every authenticated route before the credentials are
+	// checked, per client IP, so failing requests are limited too
+	GroupAuth = "auth"
+)
+
+var Groups = []string{GroupDefault, GroupReports, GroupStream, GroupAdmin, GroupAuth}
+
+// Limit allows bursts of up to Requests requests, refilled evenly over Period.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ratePerSecond is how many tokens are added to a bucket every second.
+func (l Limit) ratePerSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is the state of a client's bucket after a request.
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is the number of requests that can be made right away
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, zero if it
+	// already is
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// result describes a bucket holding tokens after a request was let through
+// or not.
+func (l Limit) result(allowed bool, tokens float64) Result {
+	rate := l.ratePerSecond()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     time.Duration((float64(l.Requests) - tokens) / rate * float64(time.Second)),
+	}
+	if tokens < 1 {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return res
+}
+
+// ParseLimits parses limits per route group such as "default=600/m" or
+// "reports=30/10s". The period is s, m, h or a duration like 10s.
+func ParseLimits(specs []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(specs))
+	for _, spec := range specs {
+		group, value, ok := strings.Cut(spec, "=")
+		group = strings.TrimSpace(group)
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q, expected group=requests/period", spec)
+		}
+		if !slices.Contains(Groups, group) {
+			return nil, fmt.Errorf("unknown rate limit group %q, expected one of %s", group, strings.Join(Groups, ", "))
+		}
+
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit for %s: %w", group, err)
+		}
+		limits[group] = limit
+	}
+	return limits, nil
+}
+
+// ParseLimit parses a limit such as "600/m".
+func ParseLimit(value string) (Limit, error) {
+	count, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected requests/period", value)
+	}
+
+	requests, err := strconv.Atoi(count)
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("invalid request count %q", count)
+	}
+
+	var d time.Duration
+	switch period {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		d, err = time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return Limit{}, fmt.Errorf("invalid period %q", period)
+		}
+	}
+	if d > maxPeriod {
+		return Limit{}, fmt.Errorf("period %s is longer than %s", d, maxPeriod)
+	}
+
+	return Limit{Requests: requests, Period: d}, nil
+}
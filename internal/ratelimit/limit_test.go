@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "600/m", want: Limit{Requests: 600, Period: time.Minute}},
+		{value: "30/10s", want: Limit{Requests: 30, Period: 10 * time.Second}},
+		{value: "5/s", want: Limit{Requests: 5, Period: time.Second}},
+		{value: "1000/h", want: Limit{Requests: 1000, Period: time.Hour}},
+		{value: "100/1m30s", want: Limit{Requests: 100, Period: 90 * time.Second}},
+		{value: " 10/m ", want: Limit{Requests: 10, Period: time.Minute}},
+		{value: "24h", wantErr: true},
+		{value: "600", wantErr: true},
+		{value: "/m", wantErr: true},
+		{value: "abc/m", wantErr: true},
+		{value: "0/m", wantErr: true},
+		{value: "-5/m", wantErr: true},
+		{value: "10/", wantErr: true},
+		{value: "10/d", wantErr: true},
+		{value: "10/0s", wantErr: true},
+		{value: "10/-1s", wantErr: true},
+		{value: "10/25h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseLimit(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLimit(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	got, err := ParseLimits([]string{"default=600/m", " reports =30/10s"})
+	if err != nil {
+		t.Fatalf("ParseLimits: %v", err)
+	}
+
+	want := map[string]Limit{
+		GroupDefault: {Requests: 600, Period: time.Minute},
+		GroupReports: {Requests: 30, Period: 10 * time.Second},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseLimits = %v, want %v", got, want)
+	}
+	for group, limit := range want {
+		if got[group] != limit {
+			t.Errorf("limit of %s = %v, want %v", group, got[group], limit)
+		}
+	}
+
+	for _, specs := range [][]string{
+		{"600/m"},
+		{"unknown=600/m"},
+		{"default=600"},
+		{"default=0/m"},
+	} {
+		if _, err := ParseLimits(specs); err == nil {
+			t.Errorf("ParseLimits(%q) succeeded, want an error", specs)
+		}
+	}
+}
+
+func TestLimitResult(t *testing.T) {
+	perMinute := Limit{Requests: 600, Period: time.Minute}
+	perTenSeconds := Limit{Requests: 30, Period: 10 * time.Second}
+
+	tests := []struct {
+		name           string
+		limit          Limit
+		allowed        bool
+		tokens         float64
+		wantRemaining  int
+		wantRetryAfter time.Duration
+		wantReset      time.Duration
+	}{
+		{
+			name:           "empty",
+			limit:          perMinute,
+			tokens:         0,
+			wantRemaining:  0,
+			wantRetryAfter: 100 * time.Millisecond,
+			wantReset:      time.Minute,
+		},
+		{
+			name:           "half a token",
+			limit:          perMinute,
+			tokens:         0.5,
+			wantRemaining:  0,
+			wantRetryAfter: 50 * time.Millisecond,
+			wantReset:      59950 * time.Millisecond,
+		},
+		{
+			name:          "fractional tokens left",
+			limit:         perMinute,
+			allowed:       true,
+			tokens:        3.7,
+			wantRemaining: 3,
+			wantReset:     59630 * time.Millisecond,
+		},
+		{
+			name:          "one token left",
+			limit:         perMinute,
+			allowed:       true,
+			tokens:        1,
+			wantRemaining: 1,
+			wantReset:     59900 * time.Millisecond,
+		},
+		{
+			name:          "full",
+			limit:         perMinute,
+			allowed:       true,
+			tokens:        600,
+			wantRemaining: 600,
+		},
+		{
+			name:           "empty, slow refill",
+			limit:          perTenSeconds,
+			tokens:         0,
+			wantRemaining:  0,
+			wantRetryAfter: time.Second / 3,
+			wantReset:      10 * time.Second,
+		},
+		{
+			name:           "fractional, slow refill",
+			limit:          perTenSeconds,
+			tokens:         0.25,
+			wantRemaining:  0,
+			wantRetryAfter: 250 * time.Millisecond,
+			wantReset:      9916666666 * time.Nanosecond,
+		},
+		{
+			name:          "full, slow refill",
+			limit:         perTenSeconds,
+			allowed:       true,
+			tokens:        30,
+			wantRemaining: 30,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.limit.result(tt.allowed, tt.tokens)
+
+			if got.Allowed != tt.allowed || got.Limit != tt.limit {
+				t.Errorf("result() = %+v, want allowed %v and limit %v", got, tt.allowed, tt.limit)
+			}
+			if got.Remaining != tt.wantRemaining {
+				t.Errorf("Remaining = %d, want %d", got.Remaining, tt.wantRemaining)
+			}
+			if !near(got.RetryAfter, tt.wantRetryAfter) {
+				t.Errorf("RetryAfter = %s, want %s", got.RetryAfter, tt.wantRetryAfter)
+			}
+			if !near(got.Reset, tt.wantReset) {
+				t.Errorf("Reset = %s, want %s", got.Reset, tt.wantReset)
+			}
+		})
+	}
+}
+
+// near allows for the rounding of the float token math.
+func near(got, want time.Duration) bool {
+	diff := got - want
+	return diff > -time.Microsecond && diff < time.Microsecond
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/response"
+)
+
+// pruneInterval is how often buckets unused for maxPeriod are dropped.
+const pruneInterval = time.Hour
+
+// Limiter limits the requests of every client per route group with a
+// token bucket.
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+}
+
+// New returns a limiter applying limits per route group. Groups without a
+// limit of their own use the default group's limit, still with separate
+// buckets. A nil store disables rate limiting.
+func New(store Store, limits map[string]Limit) *Limiter {
+	return &Limiter{store: store, limits: limits}
+}
+
+func (l *Limiter) limit(group string) (Limit, bool) {
+	if limit, ok := l.limits[group]; ok {
+		return limit, true
+	}
+	limit, ok := l.limits[GroupDefault]
+	return limit, ok
+}
+
+// Middleware limits the requests to the routes of group. Authenticated
+// callers are limited per principal, anyone else per client IP: after
+// auth.Authenticator.Middleware it tells API keys apart, before it, as
+// GroupAuth, it limits requests with missing or invalid credentials.
+func (l *Limiter) Middleware(group string) func(http.Handler) http.Handler {
+	limit, ok := l.limit(group)
+	if l.store == nil || !ok {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := ClientKey(r.Context(), r.RemoteAddr)
+
+			res, err := l.Take(r.Context(), group, client)
+			if err != nil {
+				// an unavailable store shouldn't take the API down with it
+				logging.FromContext(r.Context()).Error("failed to check rate limit", "error", err, "group", group)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setHeaders(w, res)
+
+			if !res.Allowed {
+				retryAfter := ceilSeconds(res.RetryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				logging.FromContext(r.Context()).Warn("rate limit exceeded", "group", group, "client", client, "limit", limit.String())
+				response.RespondError(w, r, http.StatusTooManyRequests, response.CodeRateLimited, fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Take spends a token from the bucket of client in group, as returned by
+// ClientKey. Calls are always allowed when rate limiting is disabled.
+func (l *Limiter) Take(ctx context.Context, group, client string) (Result, error) {
+	limit, ok := l.limit(group)
+	if l.store == nil || !ok {
+		return Result{Allowed: true}, nil
+	}
+	return l.store.Take(ctx, group+"|"+client, limit)
+}
+
+// Run drops idle buckets until ctx is cancelled.
+func (l *Limiter) Run(ctx context.Context) {
+	if l.store == nil {
+		return
+	}
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := l.store.Prune(ctx, maxPeriod); err != nil && ctx.Err() == nil {
+			slog.Error("failed to prune rate limit buckets", "error", err)
+		}
+	}
+}
+
+// ClientKey identifies the caller of a request: its principal, qualified by
+// tenant as token subjects are only unique within one, or else the IP of
+// remoteAddr.
+func ClientKey(ctx context.Context, remoteAddr string) string {
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		return p.TenantID.String() + "/" + p.ID
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// setHeaders reports the bucket's state as described by the IETF RateLimit
+// header fields draft.
+func setHeaders(w http.ResponseWriter, res Result) {
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", res.Limit.Requests, ceilSeconds(res.Limit.Period)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seeques/subman/internal/auth"
+)
+
+func TestMiddlewareLimitsUnauthenticatedRequests(t *testing.T) {
+	l := New(NewMemoryStore(), map[string]Limit{
+		GroupAuth: {Requests: 2, Period: time.Minute},
+	})
+
+	// stands in for auth.Authenticator.Middleware rejecting every token
+	reject := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	h := l.Middleware(GroupAuth)(reject)
+
+	request := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/subscriptions", nil)
+		r.RemoteAddr = remoteAddr
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("203.0.113.7:40000", ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("request %d: status = %d, want 401", i+1, w.Code)
+		}
+	}
+
+	// other ports and invalid tokens share the bucket of the address
+	for _, token := range []string{"", "sk_invalid"} {
+		w := request("203.0.113.7:40001", token)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("token %q: status = %d, want 429", token, w.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != "30" {
+			t.Errorf("Retry-After = %q, want 30", got)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+			t.Errorf("RateLimit-Remaining = %q, want 0", got)
+		}
+	}
+
+	if w := request("198.51.100.1:40000", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("other client: status = %d, want 401", w.Code)
+	}
+}
+
+func TestMiddlewareLimitsPrincipalsSeparately(t *testing.T) {
+	l := New(NewMemoryStore(), map[string]Limit{
+		GroupDefault: {Requests: 1, Period: time.Minute},
+	})
+	h := l.Middleware(GroupDefault)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(p *auth.Principal) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/subscriptions", nil)
+		r.RemoteAddr = "203.0.113.7:40000"
+		if p != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	first := &auth.Principal{ID: "apikey:1"}
+	second := &auth.Principal{ID: "apikey:2"}
+	if got := request(first); got != http.StatusOK {
+		t.Fatalf("first key: status = %d, want 200", got)
+	}
+	if got := request(first); got != http.StatusTooManyRequests {
+		t.Errorf("first key again: status = %d, want 429", got)
+	}
+	// neither another key nor the shared address are limited by it
+	if got := request(second); got != http.StatusOK {
+		t.Errorf("second key: status = %d, want 200", got)
+	}
+	if got := request(nil); got != http.StatusOK {
+		t.Errorf("no key: status = %d, want 200", got)
+	}
+}
+
+func TestMiddlewareWithoutStore(t *testing.T) {
+	l := New(nil, map[string]Limit{GroupAuth: {Requests: 1, Period: time.Minute}})
+	h := l.Middleware(GroupAuth)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/subscriptions", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, w.Code)
+		}
+	}
+}
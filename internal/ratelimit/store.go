@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/seeques/subman/internal/storage"
+)
+
+// maxPeriod bounds the period of a limit, so buckets unused for longer are
+// full and can be dropped.
+const maxPeriod = 24 * time.Hour
+
+// Store keeps the token buckets.
+type Store interface {
+	// Take refills the bucket under key and takes a token from it if one is
+	// left. Missing buckets start full.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Prune drops buckets unused for longer than idle.
+	Prune(ctx context.Context, idle time.Duration) error
+}
+
+// NewStore returns the store named by RATE_LIMIT_STORE: memory, postgres
+// or off, for which it returns nil.
+func NewStore(name string, storage *storage.PostgresStorage) (Store, error) {
+	switch name {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		return NewPostgresStore(storage), nil
+	case "off":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", name)
+	}
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryStore keeps buckets in the process, so every instance limits on its
+// own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+elapsed*limit.ratePerSecond())
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return limit.result(allowed, b.tokens), nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, idle time.Duration) error {
+	cutoff := time.Now().Add(-idle)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if b.updatedAt.Before(cutoff) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
+
+// PostgresStore keeps buckets in the database, so all instances share them.
+type PostgresStore struct {
+	storage *storage.PostgresStorage
+}
+
+func NewPostgresStore(storage *storage.PostgresStorage) *PostgresStore {
+	return &PostgresStore{storage: storage}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	tokens, allowed, err := s.storage.TakeRateLimitToken(ctx, key, float64(limit.Requests), limit.ratePerSecond())
+	if err != nil {
+		return Result{}, err
+	}
+	return limit.result(allowed, tokens), nil
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, idle time.Duration) error {
+	_, err := s.storage.DeleteIdleRateLimitBuckets(ctx, idle)
+	return err
+}
@@ -0,0 +1,180 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ContentType is the media type of problem details, see RFC 7807.
+const ContentType = "application/problem+json"
+
+// Code identifies an error for clients. Codes are stable, unlike the
+// wording of details, so clients should match on them.
+type Code string
+
+const (
+	CodeInvalidJSON      Code = "invalid_json"
+	CodeInvalidID        Code = "invalid_id"
+	CodeValidationFailed Code = "validation_failed"
+	CodeRouteNotFound    Code = "route_not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeRateLimited      Code = "rate_limited"
+	CodeInternal         Code = "internal_error"
+
+	CodeUnauthenticated  Code = "unauthenticated"
+	CodePermissionDenied Code = "permission_denied"
+	CodeForeignUser      Code = "foreign_user"
+	CodeInvalidTenant    Code = "invalid_tenant"
+	CodeForeignTenant    Code = "foreign_tenant"
+	CodeOperatorRequired Code = "operator_required"
+
+	CodeSubscriptionNotFound   Code = "subscription_not_found"
+	CodeBudgetNotFound         Code = "budget_not_found"
+	CodeAPIKeyNotFound         Code = "api_key_not_found"
+	CodeRoleAssignmentNotFound Code = "role_assignment_not_found"
+	CodeTenantNotFound         Code = "tenant_not_found"
+	CodeWebhookNotFound        Code = "webhook_not_found"
+	CodeDeadLetterNotFound     Code = "dead_letter_not_found"
+	CodeUserNotFound           Code = "user_not_found"
+	CodeServiceNotFound        Code = "service_not_found"
+
+	CodeTenantExists     Code = "tenant_exists"
+	CodeTenantNotEmpty   Code = "tenant_not_empty"
+	CodeDefaultTenant    Code = "default_tenant"
+	CodeUserExists       Code = "user_exists"
+	CodeEmailTaken       Code = "email_taken"
+	CodeUserInUse        Code = "user_in_use"
+	CodeServiceNameTaken Code = "service_name_taken"
+)
+
+// Codes of field errors, saying what is wrong with the field.
+const (
+	CodeRequired   Code = "required"
+	CodeInvalid    Code = "invalid"
+	CodeOutOfRange Code = "out_of_range"
+	CodeUnknown    Code = "unknown"
+	CodeTooShort   Code = "too_short"
+	CodeTooLong    Code = "too_long"
+	// the field doesn't exist, which usually means it is misspelled
+	CodeUnknownField Code = "unknown_field"
+)
+
+// Problem is an error response in the format of RFC 7807, extended with the
+// error code, the invalid fields and the id of the request.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError is one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+// FieldErrors collects every invalid field of a request, so clients can fix
+// them all at once. A nil FieldErrors means the request is valid.
+type FieldErrors []FieldError
+
+func (e *FieldErrors) Add(field string, code Code, message string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Has reports whether field is invalid, to skip checks that depend on it.
+func (e FieldErrors) Has(field string) bool {
+	for _, fe := range e {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Format is the shape of error responses.
+type Format string
+
+const (
+	// FormatProblem answers with problem details
+	FormatProblem Format = "problem"
+	// FormatLegacy answers with {"error": "<detail>"} like older versions,
+	// unless the client accepts problem details
+	FormatLegacy Format = "legacy"
+)
+
+type formatKey struct{}
+
+// Middleware sets the format of the error responses to requests.
+func Middleware(format Format) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), formatKey{}, format)))
+		})
+	}
+}
+
+func legacy(r *http.Request) bool {
+	format, _ := r.Context().Value(formatKey{}).(Format)
+	return format == FormatLegacy && !strings.Contains(r.Header.Get("Accept"), ContentType)
+}
+
+// RespondError writes a problem with code and a detail for humans.
+func RespondError(w http.ResponseWriter, r *http.Request, status int, code Code, detail string) {
+	RespondProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// RespondValidationError writes a 400 listing every invalid field.
+func RespondValidationError(w http.ResponseWriter, r *http.Request, errs FieldErrors) {
+	RespondProblem(w, r, Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: errs.Error(),
+		Errors: errs,
+	})
+}
+
+// RespondFieldError writes a 400 for a single invalid field, such as a
+// query parameter.
+func RespondFieldError(w http.ResponseWriter, r *http.Request, field string, code Code, message string) {
+	RespondValidationError(w, r, FieldErrors{{Field: field, Code: code, Message: message}})
+}
+
+// RespondProblem fills in the fields of p common to all problems and writes
+// it in the format of the request.
+func RespondProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if legacy(r) {
+		RespondJSON(w, p.Status, map[string]string{"error": p.Detail})
+		return
+	}
+
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
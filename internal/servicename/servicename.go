@@ -0,0 +1,157 @@
+// Package servicename compares free text service names, so spelling
+// variants such as "Yandex Plus", "yandex  plus" and "Яндекс Плюс" can be
+// found and merged.
+package servicename
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// cyrillic transliterates Russian letters to Latin, following the common
+// passport style.
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// Fold reduces name to the form names are compared in: lower case Latin
+// letters and digits separated by single spaces, with accents dropped and
+// Cyrillic transliterated.
+func Fold(name string) string {
+	var b strings.Builder
+	space := false
+	write := func(s string) {
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteString(s)
+	}
+
+	for _, r := range norm.NFC.String(name) {
+		r = unicode.ToLower(r)
+
+		// looked up before decomposing, which would turn й into и
+		if latin, ok := cyrillic[r]; ok {
+			// hard and soft signs have no Latin letter, so they must not
+			// leave a separator behind on their own
+			if latin != "" {
+				write(latin)
+			}
+			continue
+		}
+
+		for _, d := range norm.NFD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			if unicode.IsLetter(d) || unicode.IsDigit(d) {
+				write(string(d))
+				continue
+			}
+			// punctuation separates words like spaces do, so "Yandex.Plus"
+			// folds like "Yandex Plus"
+			space = b.Len() > 0
+		}
+	}
+	return b.String()
+}
+
+// Similarity scores folded names between 0 and 1 by their edit distance
+// relative to the longer name. Spaces are ignored when that scores higher,
+// so "YouTube Premium" and "Youtube-Premium" match fully.
+func Similarity(a, b string) float64 {
+	score := similarity(a, b)
+	if joined := similarity(strings.ReplaceAll(a, " ", ""), strings.ReplaceAll(b, " ", "")); joined > score {
+		score = joined
+	}
+	return score
+}
+
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(distance(ra, rb))/float64(longest)
+}
+
+// distance is the Levenshtein distance of a and b.
+func distance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Cluster groups names whose folded forms score at least threshold with
+// another name of the group, returning the indexes of names in groups of
+// two or more. Groups and their indexes keep the order of names.
+func Cluster(names []string, threshold float64) [][]int {
+	folded := make([]string, len(names))
+	for i, name := range names {
+		folded[i] = Fold(name)
+	}
+
+	parent := make([]int, len(names))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			if find(i) != find(j) && Similarity(folded[i], folded[j]) >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	var clusters [][]int
+	index := make(map[int]int)
+	for i := range names {
+		root := find(i)
+		k, ok := index[root]
+		if !ok {
+			k = len(clusters)
+			index[root] = k
+			clusters = append(clusters, nil)
+		}
+		clusters[k] = append(clusters[k], i)
+	}
+
+	result := clusters[:0]
+	for _, c := range clusters {
+		if len(c) > 1 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
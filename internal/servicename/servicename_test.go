@@ -0,0 +1,137 @@
+package servicename
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Yandex Plus", "yandex plus"},
+		{"  yandex   PLUS ", "yandex plus"},
+		{"Yandex.Plus", "yandex plus"},
+		{"Yandex—Plus!", "yandex plus"},
+		{"YouTube-Premium", "youtube premium"},
+		{"Disney+", "disney"},
+		{"Apple TV+ (4K)", "apple tv 4k"},
+		{"Café Crème", "cafe creme"},
+		{"Spotify 2.0", "spotify 2 0"},
+		{"Яндекс Плюс", "yandeks plyus"},
+		{"Кинопоиск", "kinopoisk"},
+		{"ЁЖИК", "ezhik"},
+		{"Щука Чай Цех Шум Хор", "shchuka chay tsekh shum khor"},
+		{"Ёлка Йога", "elka yoga"},
+		{"Объявление", "obyavlenie"},
+		{"Кино ЪЬ", "kino"},
+		{"", ""},
+		{"+++", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fold(tt.name); got != tt.want {
+				t.Errorf("Fold(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Netflix", "Netflix", 1},
+		{"Netflix", "netflix!", 1},
+		{"Netflix", "Netflx", 6.0 / 7},
+		{"Spotify", "Netflix", 0},
+		// transliteration gets Cyrillic spellings close, which is what
+		// the default merge threshold of 0.75 is set for
+		{"Яндекс Плюс", "Yandex Plus", 10.0 / 13},
+		{"Кинопоиск", "Kinopoisk", 1},
+		// spaces are ignored when that scores higher
+		{"YouTubePremium", "YouTube Premium", 1},
+		{"Net Flix", "Netflix", 1},
+		{"Kinopoisk", "Kinopoisk HD", 9.0 / 11},
+		// but kept when that scores higher
+		{"Okko TV", "Okko HD", 5.0 / 7},
+		{"", "", 1},
+		{"Netflix", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			got := Similarity(Fold(tt.a), Fold(tt.b))
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Similarity(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+			}
+			if reverse := Similarity(Fold(tt.b), Fold(tt.a)); reverse != got {
+				t.Errorf("Similarity is not symmetric: %.4f and %.4f", got, reverse)
+			}
+		})
+	}
+}
+
+func TestCluster(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		threshold float64
+		want      [][]int
+	}{
+		{
+			name:      "spellings of one service",
+			names:     []string{"Yandex Plus", "Spotify", "yandex  plus", "Яндекс Плюс", "Yandex.Plus"},
+			threshold: 0.75,
+			want:      [][]int{{0, 2, 3, 4}},
+		},
+		{
+			name:      "several groups keep the order of names",
+			names:     []string{"Spotify", "Netflix", "Netflx", "Spotifi", "Okko"},
+			threshold: 0.75,
+			want:      [][]int{{0, 3}, {1, 2}},
+		},
+		{
+			// "Kinopoisk" and "Kinopoisk HDR" only score 0.75, but both
+			// are close to "Kinopoisk HD"
+			name:      "transitive",
+			names:     []string{"Kinopoisk", "Spotify", "Kinopoisk HDR", "Kinopoisk HD"},
+			threshold: 0.8,
+			want:      [][]int{{0, 2, 3}},
+		},
+		{
+			name:      "threshold above the transliteration score",
+			names:     []string{"Yandex Plus", "Яндекс Плюс"},
+			threshold: 0.8,
+			want:      nil,
+		},
+		{
+			name:      "no similar names",
+			names:     []string{"Spotify", "Netflix", "Okko"},
+			threshold: 0.75,
+			want:      nil,
+		},
+		{
+			name:      "empty",
+			names:     nil,
+			threshold: 0.75,
+			want:      nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Cluster(tt.names, tt.threshold)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cluster(%q, %v) = %v, want %v", tt.names, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/models"
+)
+
+const apiKeyColumns = `id, tenant_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row pgx.Row, key *models.APIKey) error {
+	return row.Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+}
+
+func (s *PostgresStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := `INSERT INTO api_key (name, prefix, key_hash, scopes)
+	VALUES ($1, $2, $3, $4)
+	RETURNING ` + apiKeyColumns
+
+	if err := scanAPIKey(s.pool.QueryRow(ctx, query, key.Name, key.Prefix, key.KeyHash, key.Scopes), key); err != nil {
+		return fmt.Errorf("create api key: %w", err)
+	}
+	return nil
+}
+
+// GetActiveAPIKeyByPrefix returns the key with the given prefix unless it
+// was revoked.
+func (s *PostgresStorage) GetActiveAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_key WHERE prefix = $1 AND revoked_at IS NULL AND tenant_visible(tenant_id)`
+
+	var key models.APIKey
+	if err := scanAPIKey(s.pool.QueryRow(ctx, query, prefix), &key); err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	return &key, nil
+}
+
+func (s *PostgresStorage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_key WHERE tenant_visible(tenant_id) ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresStorage) RevokeAPIKey(ctx context.Context, id int) (*models.APIKey, error) {
+	query := `UPDATE api_key SET revoked_at = NOW()
+	WHERE id = $1 AND revoked_at IS NULL AND tenant_visible(tenant_id)
+	RETURNING ` + apiKeyColumns
+
+	var key models.APIKey
+	if err := scanAPIKey(s.pool.QueryRow(ctx, query, id), &key); err != nil {
+		return nil, fmt.Errorf("revoke api key: %w", err)
+	}
+	return &key, nil
+}
+
+// RotateAPIKey replaces the secret of an active key. The old secret stops
+// working immediately.
+func (s *PostgresStorage) RotateAPIKey(ctx context.Context, id int, prefix string, keyHash []byte) (*models.APIKey, error) {
+	query := `UPDATE api_key SET prefix = $2, key_hash = $3, last_used_at = NULL
+	WHERE id = $1 AND revoked_at IS NULL AND tenant_visible(tenant_id)
+	RETURNING ` + apiKeyColumns
+
+	var key models.APIKey
+	if err := scanAPIKey(s.pool.QueryRow(ctx, query, id, prefix, keyHash), &key); err != nil {
+		return nil, fmt.Errorf("rotate api key: %w", err)
+	}
+	return &key, nil
+}
+
+// TouchAPIKey records that a key was used, at most once a minute to keep
+// authenticated requests from writing on every call.
+func (s *PostgresStorage) TouchAPIKey(ctx context.Context, id int) error {
+	query := `UPDATE api_key SET last_used_at = NOW()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute') AND tenant_visible(tenant_id)`
+
+	if _, err := s.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+	return nil
+}
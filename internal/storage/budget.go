@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/models"
+)
+
+type BudgetListParams struct {
+	Page   int
+	Limit  int
+	UserID *uuid.UUID
+}
+
+type BudgetListResult struct {
+	Budgets []models.Budget
+	Total   int
+}
+
+const budgetColumns = `id, tenant_id, user_id, period, limit_amount, currency, thresholds, created_at, updated_at`
+
+func scanBudget(row pgx.Row, budget *models.Budget) error {
+	return row.Scan(
+		&budget.ID,
+		&budget.TenantID,
+		&budget.UserID,
+		&budget.Period,
+		&budget.Limit,
+		&budget.Currency,
+		&budget.Thresholds,
+		&budget.CreatedAt,
+		&budget.UpdatedAt,
+	)
+}
+
+func (s *PostgresStorage) CreateBudget(ctx context.Context, budget *models.Budget) error {
+	query := `INSERT INTO budget (user_id, period, limit_amount, currency, thresholds)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + budgetColumns
+
+	row := s.pool.QueryRow(ctx, query, budget.UserID, budget.Period, budget.Limit, budget.Currency, budget.Thresholds)
+	if err := scanBudget(row, budget); err != nil {
+		return fmt.Errorf("create budget: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetBudget(ctx context.Context, id int) (*models.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budget WHERE id = $1 AND tenant_visible(tenant_id)`
+
+	var budget models.Budget
+	if err := scanBudget(s.pool.QueryRow(ctx, query, id), &budget); err != nil {
+		return nil, fmt.Errorf("get budget: %w", err)
+	}
+	return &budget, nil
+}
+
+func (s *PostgresStorage) UpdateBudget(ctx context.Context, budget *models.Budget) error {
+	query := `UPDATE budget SET user_id = $1, period = $2, limit_amount = $3, currency = $4, thresholds = $5, updated_at = NOW()
+	WHERE id = $6 AND tenant_visible(tenant_id)
+	RETURNING ` + budgetColumns
+
+	row := s.pool.QueryRow(ctx, query, budget.UserID, budget.Period, budget.Limit, budget.Currency, budget.Thresholds, budget.ID)
+	if err := scanBudget(row, budget); err != nil {
+		return fmt.Errorf("update budget: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) DeleteBudget(ctx context.Context, id int) error {
+	result, err := s.pool.Exec(ctx, `DELETE FROM budget WHERE id = $1 AND tenant_visible(tenant_id)`, id)
+	if err != nil {
+		return fmt.Errorf("delete budget: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) ListBudgets(ctx context.Context, params BudgetListParams) (*BudgetListResult, error) {
+	offset := (params.Page - 1) * params.Limit
+
+	where := " WHERE tenant_visible(tenant_id)"
+	args := []interface{}{}
+	if params.UserID != nil {
+		where += " AND user_id = $1"
+		args = append(args, *params.UserID)
+	}
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM budget`+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count budgets: %w", err)
+	}
+
+	pageQuery := `SELECT ` + budgetColumns + ` FROM budget` + where +
+		fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+
+	budgets, err := s.queryBudgets(ctx, pageQuery, append(args, params.Limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("list budgets: %w", err)
+	}
+
+	return &BudgetListResult{
+		Budgets: budgets,
+		Total:   total,
+	}, nil
+}
+
+// GetBudgetsForUser returns every budget of a user. A nil userID returns all
+// budgets, which the scheduled evaluation relies on.
+func (s *PostgresStorage) GetBudgetsForUser(ctx context.Context, userID *uuid.UUID) ([]models.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budget WHERE tenant_visible(tenant_id)`
+	args := []interface{}{}
+	if userID != nil {
+		query += ` AND user_id = $1`
+		args = append(args, *userID)
+	}
+	query += ` ORDER BY id`
+
+	budgets, err := s.queryBudgets(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get budgets for user: %w", err)
+	}
+	return budgets, nil
+}
+
+func (s *PostgresStorage) queryBudgets(ctx context.Context, query string, args ...interface{}) ([]models.Budget, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []models.Budget
+	for rows.Next() {
+		var budget models.Budget
+		if err := scanBudget(rows, &budget); err != nil {
+			return nil, fmt.Errorf("scan budget: %w", err)
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
+}
+
+// RecordBudgetBreach stores a breach unless the same threshold was already
+// recorded for the period. It reports whether a new breach was stored.
+func (s *PostgresStorage) RecordBudgetBreach(ctx context.Context, breach *models.BudgetBreach) (bool, error) {
+	query := `INSERT INTO budget_breach (budget_id, threshold, period_start, spent, limit_amount, tenant_id)
+	SELECT id, $2, $3, $4, $5, tenant_id FROM budget WHERE id = $1 AND tenant_visible(tenant_id)
+	ON CONFLICT (budget_id, threshold, period_start) DO NOTHING
+	RETURNING id, created_at`
+
+	err := s.pool.QueryRow(ctx, query, breach.BudgetID, breach.Threshold, breach.PeriodStart, breach.Spent, breach.Limit).Scan(
+		&breach.ID,
+		&breach.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("record budget breach: %w", err)
+	}
+	return true, nil
+}
+
+func (s *PostgresStorage) ListBudgetBreaches(ctx context.Context, budgetID int) ([]models.BudgetBreach, error) {
+	query := `SELECT id, budget_id, threshold, period_start, spent, limit_amount, created_at
+	FROM budget_breach
+	WHERE budget_id = $1 AND tenant_visible(tenant_id)
+	ORDER BY period_start DESC, threshold DESC`
+
+	rows, err := s.pool.Query(ctx, query, budgetID)
+	if err != nil {
+		return nil, fmt.Errorf("list budget breaches: %w", err)
+	}
+	defer rows.Close()
+
+	var breaches []models.BudgetBreach
+	for rows.Next() {
+		var breach models.BudgetBreach
+		err := rows.Scan(
+			&breach.ID,
+			&breach.BudgetID,
+			&breach.Threshold,
+			&breach.PeriodStart,
+			&breach.Spent,
+			&breach.Limit,
+			&breach.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan budget breach: %w", err)
+		}
+		breaches = append(breaches, breach)
+	}
+	return breaches, rows.Err()
+}
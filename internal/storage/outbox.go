@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/models"
+)
+
+// insertOutbox stores event in the outbox as part of tx, so it is only
+// published if the change that caused it is committed.
+func insertOutbox(ctx context.Context, tx pgx.Tx, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal outbox event: %w", err)
+	}
+
+	query := `INSERT INTO outbox (event_id, event_type, aggregate_id, payload, tenant_id)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	if _, err := tx.Exec(ctx, query, event.ID, event.Type, event.Data.ID, payload, event.TenantID); err != nil {
+		return fmt.Errorf("insert outbox: %w", err)
+	}
+	return nil
+}
+
+// ClaimOutbox leases up to limit unprocessed entries for the given duration.
+// Rows leased by another relay are skipped, and an entry whose lease expired
+// without being processed is claimed again. Entries are returned in the
+// order they were written.
+func (s *PostgresStorage) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	query := `WITH due AS (
+		SELECT id FROM outbox
+		WHERE processed_at IS NULL AND dead_at IS NULL AND (locked_until IS NULL OR locked_until <= NOW()) AND tenant_visible(tenant_id)
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE outbox o
+	SET locked_until = NOW() + make_interval(secs => $2)
+	FROM due
+	WHERE o.id = due.id
+	RETURNING o.id, o.tenant_id, o.event_id, o.event_type, o.aggregate_id, o.payload, o.attempts, o.created_at`
+
+	rows, err := s.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+	for rows.Next() {
+		var entry models.OutboxEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.TenantID,
+			&entry.EventID,
+			&entry.EventType,
+			&entry.AggregateID,
+			&entry.Payload,
+			&entry.Attempts,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan outbox entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim outbox: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+func (s *PostgresStorage) MarkOutboxProcessed(ctx context.Context, id int64) error {
+	query := `UPDATE outbox SET processed_at = NOW(), locked_until = NULL, last_error = NULL WHERE id = $1 AND tenant_visible(tenant_id)`
+
+	if _, err := s.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("mark outbox processed: %w", err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed records a failed publish and keeps the entry hidden from
+// relays for retryIn, or parks it for good when dead is set.
+func (s *PostgresStorage) MarkOutboxFailed(ctx context.Context, id int64, lastError string, retryIn time.Duration, dead bool) error {
+	query := `UPDATE outbox
+	SET attempts = attempts + 1, last_error = $2, locked_until = NOW() + make_interval(secs => $3),
+		dead_at = CASE WHEN $4 THEN NOW() END
+	WHERE id = $1 AND tenant_visible(tenant_id)`
+
+	if _, err := s.pool.Exec(ctx, query, id, lastError, retryIn.Seconds(), dead); err != nil {
+		return fmt.Errorf("mark outbox failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteProcessedOutbox removes entries processed longer than retention ago.
+// Entries that are pending or dead are kept.
+func (s *PostgresStorage) DeleteProcessedOutbox(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM outbox WHERE processed_at < NOW() - make_interval(secs => $1) AND tenant_visible(tenant_id)`
+
+	result, err := s.pool.Exec(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("delete processed outbox: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// QueueEndingSoonEvents writes a subscription.ending_soon event to the outbox
+// for every subscription whose end date falls between from and to, once per
+// end date. It returns how many events were queued.
+func (s *PostgresStorage) QueueEndingSoonEvents(ctx context.Context, from, to time.Time) (int, error) {
+	query := `WITH noticed AS (
+		INSERT INTO subscription_ending_notice (subscription_id, end_date, tenant_id)
+		SELECT id, end_date, tenant_id FROM subscription
+		WHERE end_date BETWEEN $1 AND $2 AND tenant_visible(tenant_id)
+		ON CONFLICT DO NOTHING
+		RETURNING subscription_id
+	)
+	SELECT ` + subscriptionColumns + `
+	FROM subscription
+	WHERE id IN (SELECT subscription_id FROM noticed) AND tenant_visible(tenant_id)`
+
+	var queued int
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, from, to)
+		if err != nil {
+			return err
+		}
+
+		subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Subscription, error) {
+			var sub models.Subscription
+			err := scanSubscription(row, &sub)
+			return sub, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range subs {
+			if err := insertOutbox(ctx, tx, events.New(events.SubscriptionEndingSoon, &subs[i])); err != nil {
+				return err
+			}
+		}
+		queued = len(subs)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("queue ending soon events: %w", err)
+	}
+	return queued, nil
+}
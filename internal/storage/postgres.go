@@ -2,9 +2,13 @@ package storage
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/tenant"
+	"github.com/seeques/subman/internal/tracing"
 )
 
 type PostgresStorage struct {
@@ -15,17 +19,77 @@ func NewPostgresStorage(pool *pgxpool.Pool) *PostgresStorage {
 	return &PostgresStorage{pool: pool}
 }
 
-func CreatePool() (*pgxpool.Pool, error) {
-	cfg := config.LoadConfig()
-	conn, err := pgxpool.New(context.Background(), cfg.DatabaseURL)
+func CreatePool(ctx context.Context, cfg config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
 	if err != nil {
 		return nil, err
 	}
+	poolConfig.MaxConns = int32(cfg.DBMaxConns)
+	poolConfig.MinConns = int32(cfg.DBMinConns)
+	poolConfig.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolConfig.ConnConfig.ConnectTimeout = cfg.DBConnectTimeout
+	poolConfig.PrepareConn = scopeConnToTenant
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
 
-	err = conn.Ping(context.Background())
+	conn, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	err = conn.Ping(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	return conn, err
 }
+
+// scopeConnToTenant sets the tenant of ctx on a connection before it is
+// handed out. Queries and row-level security policies only see rows of
+// that tenant, and inserted rows default to it. Without a tenant in ctx no
+// tenant data is visible at all.
+func scopeConnToTenant(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	tenantID, allTenants := "", "off"
+	if id, ok := tenant.FromContext(ctx); ok {
+		tenantID = id.String()
+	} else if tenant.IsAllTenants(ctx) {
+		allTenants = "on"
+	}
+
+	query := `SELECT set_config('app.tenant_id', $1, false), set_config('app.all_tenants', $2, false)`
+	if _, err := conn.Exec(ctx, query, tenantID, allTenants); err != nil {
+		// the connection may be left scoped to the previous tenant
+		return false, fmt.Errorf("scope connection to tenant: %w", err)
+	}
+	return true, nil
+}
+
+// BypassesRowSecurity reports whether the database role ignores row-level
+// security, e.g. because it is a superuser. Tenant isolation then relies on
+// the query filters alone.
+func (s *PostgresStorage) BypassesRowSecurity(ctx context.Context) (bool, error) {
+	var bypass bool
+	err := s.pool.QueryRow(ctx, `SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user`).Scan(&bypass)
+	if err != nil {
+		return false, fmt.Errorf("check row security: %w", err)
+	}
+	return bypass, nil
+}
+
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// MigrationVersion returns the schema version recorded by golang-migrate
+// and whether the last migration failed halfway.
+func (s *PostgresStorage) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := s.pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		return 0, false, fmt.Errorf("get migration version: %w", err)
+	}
+	return uint(version), dirty, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seeques/subman/internal/models"
+)
+
+func (s *PostgresStorage) CreatePriceChange(ctx context.Context, change *models.PriceChange) error {
+	query := `INSERT INTO subscription_price_change (subscription_id, price, effective_date, tenant_id)
+	SELECT id, $2, $3, tenant_id FROM subscription WHERE id = $1 AND tenant_visible(tenant_id)
+	ON CONFLICT (subscription_id, effective_date) DO UPDATE SET price = EXCLUDED.price
+	RETURNING id, subscription_id, price, effective_date, created_at`
+
+	err := s.pool.QueryRow(ctx, query, change.SubscriptionID, change.Price, change.EffectiveDate).Scan(
+		&change.ID,
+		&change.SubscriptionID,
+		&change.Price,
+		&change.EffectiveDate,
+		&change.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create price change: %w", err)
+	}
+	return nil
+}
+
+// GetPriceChanges returns the scheduled price changes of the given
+// subscriptions keyed by subscription id, each slice ordered by effective date.
+func (s *PostgresStorage) GetPriceChanges(ctx context.Context, subscriptionIDs []int) (map[int][]models.PriceChange, error) {
+	changes := make(map[int][]models.PriceChange)
+	if len(subscriptionIDs) == 0 {
+		return changes, nil
+	}
+
+	query := `SELECT id, subscription_id, price, effective_date, created_at
+	FROM subscription_price_change
+	WHERE subscription_id = ANY($1) AND tenant_visible(tenant_id)
+	ORDER BY subscription_id, effective_date`
+
+	rows, err := s.pool.Query(ctx, query, subscriptionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("get price changes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change models.PriceChange
+		err := rows.Scan(
+			&change.ID,
+			&change.SubscriptionID,
+			&change.Price,
+			&change.EffectiveDate,
+			&change.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan price change: %w", err)
+		}
+		changes[change.SubscriptionID] = append(changes[change.SubscriptionID], change)
+	}
+	return changes, rows.Err()
+}
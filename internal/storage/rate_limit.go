@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// refilled is the number of tokens in a bucket after refilling it for the
+// time since it was last used, capped at its capacity ($2) and refilled at
+// $3 tokens per second.
+const refilled = `LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3::float8)`
+
+// TakeRateLimitToken refills the bucket stored under key and takes a token
+// from it if one is left. It returns the tokens left afterwards and whether
+// a token was taken. Missing buckets start full.
+func (s *PostgresStorage) TakeRateLimitToken(ctx context.Context, key string, capacity, ratePerSecond float64) (float64, bool, error) {
+	query := `
+		INSERT INTO rate_limit_bucket AS b (key, tokens, allowed)
+		VALUES ($1, $2::float8 - 1, true)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE WHEN ` + refilled + ` >= 1 THEN ` + refilled + ` - 1 ELSE ` + refilled + ` END,
+			allowed = ` + refilled + ` >= 1,
+			updated_at = NOW()
+		RETURNING tokens, allowed`
+
+	var tokens float64
+	var allowed bool
+	if err := s.pool.QueryRow(ctx, query, key, capacity, ratePerSecond).Scan(&tokens, &allowed); err != nil {
+		return 0, false, fmt.Errorf("take rate limit token: %w", err)
+	}
+	return tokens, allowed, nil
+}
+
+// DeleteIdleRateLimitBuckets removes buckets unused for longer than idle.
+func (s *PostgresStorage) DeleteIdleRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error) {
+	result, err := s.pool.Exec(ctx,
+		`DELETE FROM rate_limit_bucket WHERE updated_at < NOW() - make_interval(secs => $1)`,
+		idle.Seconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete idle rate limit buckets: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/models"
+)
+
+const roleAssignmentColumns = `id, tenant_id, principal, role, created_at`
+
+func scanRoleAssignment(row pgx.Row, assignment *models.RoleAssignment) error {
+	return row.Scan(
+		&assignment.ID,
+		&assignment.TenantID,
+		&assignment.Principal,
+		&assignment.Role,
+		&assignment.CreatedAt,
+	)
+}
+
+// CreateRoleAssignment grants a role. Granting a role the principal already
+// has returns the existing assignment.
+func (s *PostgresStorage) CreateRoleAssignment(ctx context.Context, assignment *models.RoleAssignment) error {
+	query := `INSERT INTO role_assignment (principal, role)
+	VALUES ($1, $2)
+	ON CONFLICT (tenant_id, principal, role) DO UPDATE SET role = EXCLUDED.role
+	RETURNING ` + roleAssignmentColumns
+
+	if err := scanRoleAssignment(s.pool.QueryRow(ctx, query, assignment.Principal, assignment.Role), assignment); err != nil {
+		return fmt.Errorf("create role assignment: %w", err)
+	}
+	return nil
+}
+
+// ListRoleAssignments returns all assignments, or only those of a single
+// principal when principal is not empty.
+func (s *PostgresStorage) ListRoleAssignments(ctx context.Context, principal string) ([]models.RoleAssignment, error) {
+	query := `SELECT ` + roleAssignmentColumns + ` FROM role_assignment
+	WHERE tenant_visible(tenant_id) AND ($1 = '' OR principal = $1)
+	ORDER BY principal, role`
+
+	rows, err := s.pool.Query(ctx, query, principal)
+	if err != nil {
+		return nil, fmt.Errorf("list role assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []models.RoleAssignment
+	for rows.Next() {
+		var assignment models.RoleAssignment
+		if err := scanRoleAssignment(rows, &assignment); err != nil {
+			return nil, fmt.Errorf("scan role assignment: %w", err)
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments, rows.Err()
+}
+
+// GetPrincipalRoles returns the roles assigned to principal.
+func (s *PostgresStorage) GetPrincipalRoles(ctx context.Context, principal string) ([]string, error) {
+	query := `SELECT role FROM role_assignment
+	WHERE principal = $1 AND tenant_visible(tenant_id)
+	ORDER BY role`
+
+	rows, err := s.pool.Query(ctx, query, principal)
+	if err != nil {
+		return nil, fmt.Errorf("get principal roles: %w", err)
+	}
+
+	roles, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("get principal roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (s *PostgresStorage) DeleteRoleAssignment(ctx context.Context, id int) error {
+	result, err := s.pool.Exec(ctx, `DELETE FROM role_assignment WHERE id = $1 AND tenant_visible(tenant_id)`, id)
+	if err != nil {
+		return fmt.Errorf("delete role assignment: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
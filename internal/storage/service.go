@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/models"
+)
+
+// ErrServiceNameTaken is returned when the name or an alias of a service
+// is already the name or an alias of another service of the tenant.
+var ErrServiceNameTaken = errors.New("service name or alias already taken")
+
+type ServiceListParams struct {
+	Page     int
+	Limit    int
+	Category string
+}
+
+type ServiceListResult struct {
+	Services []models.Service
+	Total    int
+}
+
+const serviceColumns = `id, tenant_id, name, aliases, category, vendor, website, plans, created_at, updated_at`
+
+func scanService(row pgx.Row, service *models.Service) error {
+	return row.Scan(
+		&service.ID,
+		&service.TenantID,
+		&service.Name,
+		&service.Aliases,
+		&service.Category,
+		&service.Vendor,
+		&service.Website,
+		&service.Plans,
+		&service.CreatedAt,
+		&service.UpdatedAt,
+	)
+}
+
+// resolveService links sub to the catalog entry its service name is the
+// name or an alias of, renaming it to the canonical name. Subscriptions
+// without a category get the category of the service. Subscriptions of
+// services missing from the catalog are left unlinked.
+func resolveService(ctx context.Context, tx pgx.Tx, sub *models.Subscription) error {
+	service, err := lookupService(ctx, tx, sub.ServiceName)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		sub.ServiceID = nil
+		return nil
+	}
+
+	sub.ServiceID = &service.ID
+	sub.ServiceName = service.Name
+	if sub.Category == "" {
+		sub.Category = service.Category
+	}
+	return nil
+}
+
+// lookupService returns the id, canonical name and category of the catalog
+// entry name is the name or an alias of, or nil when the catalog has no
+// such entry.
+func lookupService(ctx context.Context, tx pgx.Tx, name string) (*models.Service, error) {
+	query := `SELECT s.id, s.name, s.category FROM service_lookup l JOIN service s ON s.id = l.service_id
+	WHERE l.name_key = service_key($1) AND tenant_visible(l.tenant_id)`
+
+	var service models.Service
+	err := tx.QueryRow(ctx, query, name).Scan(&service.ID, &service.Name, &service.Category)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve service: %w", err)
+	}
+	return &service, nil
+}
+
+// indexService replaces the lookup keys of a service with those of its
+// name and aliases, then links the subscriptions named after any of them.
+// Linked subscriptions follow renames of their service, and every changed
+// subscription is published like any other update.
+func indexService(ctx context.Context, tx pgx.Tx, service *models.Service) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM service_lookup WHERE service_id = $1`, service.ID); err != nil {
+		return err
+	}
+
+	names := append([]string{service.Name}, service.Aliases...)
+	_, err := tx.Exec(ctx, `INSERT INTO service_lookup (name_key, service_id)
+	SELECT DISTINCT service_key(name), $1 FROM unnest($2::text[]) AS name`, service.ID, names)
+	if isPgError(err, "23505") {
+		return ErrServiceNameTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `UPDATE subscription SET service_id = $1, service_name = $2, updated_at = NOW()
+	WHERE tenant_visible(tenant_id) AND (service_id = $1 OR (service_id IS NULL AND service_key(service_name) IN (
+		SELECT name_key FROM service_lookup WHERE service_id = $1)))
+	AND (service_id IS DISTINCT FROM $1 OR service_name <> $2)
+	RETURNING `+subscriptionColumns, service.ID, service.Name)
+	if err != nil {
+		return err
+	}
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Subscription, error) {
+		var sub models.Subscription
+		err := scanSubscription(row, &sub)
+		return sub, err
+	})
+	if err != nil {
+		return err
+	}
+	for i := range subs {
+		if err := insertOutbox(ctx, tx, events.New(events.SubscriptionUpdated, &subs[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateService adds a service to the catalog and links the existing
+// subscriptions named after it.
+func (s *PostgresStorage) CreateService(ctx context.Context, service *models.Service) error {
+	query := `INSERT INTO service (name, aliases, category, vendor, website, plans)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING ` + serviceColumns
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx, query, service.Name, service.Aliases, service.Category, service.Vendor, service.Website, service.Plans)
+		if err := scanService(row, service); err != nil {
+			return err
+		}
+		return indexService(ctx, tx, service)
+	})
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetService(ctx context.Context, id int) (*models.Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM service WHERE id = $1 AND tenant_visible(tenant_id)`
+
+	var service models.Service
+	if err := scanService(s.pool.QueryRow(ctx, query, id), &service); err != nil {
+		return nil, fmt.Errorf("get service: %w", err)
+	}
+	return &service, nil
+}
+
+// UpdateService replaces a catalog entry. Its subscriptions are renamed
+// along with it, and subscriptions named after new aliases are linked.
+func (s *PostgresStorage) UpdateService(ctx context.Context, service *models.Service) error {
+	query := `UPDATE service SET name = $1, aliases = $2, category = $3, vendor = $4, website = $5, plans = $6, updated_at = NOW()
+	WHERE id = $7 AND tenant_visible(tenant_id)
+	RETURNING ` + serviceColumns
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx, query, service.Name, service.Aliases, service.Category, service.Vendor, service.Website, service.Plans, service.ID)
+		if err := scanService(row, service); err != nil {
+			return err
+		}
+		return indexService(ctx, tx, service)
+	})
+	if err != nil {
+		return fmt.Errorf("update service: %w", err)
+	}
+	return nil
+}
+
+// DeleteService removes a service from the catalog. Its subscriptions keep
+// their service name but are no longer linked, which is published as an
+// update of each.
+func (s *PostgresStorage) DeleteService(ctx context.Context, id int) error {
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// unlinked here rather than by the foreign key to know which changed
+		rows, err := tx.Query(ctx, `UPDATE subscription SET service_id = NULL, updated_at = NOW()
+		WHERE service_id = $1 AND tenant_visible(tenant_id)
+		RETURNING `+subscriptionColumns, id)
+		if err != nil {
+			return err
+		}
+		subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Subscription, error) {
+			var sub models.Subscription
+			err := scanSubscription(row, &sub)
+			return sub, err
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(ctx, `DELETE FROM service WHERE id = $1 AND tenant_visible(tenant_id)`, id)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+
+		for i := range subs {
+			if err := insertOutbox(ctx, tx, events.New(events.SubscriptionUpdated, &subs[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete service: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListServices(ctx context.Context, params ServiceListParams) (*ServiceListResult, error) {
+	offset := (params.Page - 1) * params.Limit
+
+	where := " WHERE tenant_visible(tenant_id)"
+	args := []interface{}{}
+	if params.Category != "" {
+		where += " AND category = $1"
+		args = append(args, params.Category)
+	}
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM service`+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count services: %w", err)
+	}
+
+	pageQuery := `SELECT ` + serviceColumns + ` FROM service` + where +
+		fmt.Sprintf(" ORDER BY name, id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+
+	rows, err := s.pool.Query(ctx, pageQuery, append(args, params.Limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+	defer rows.Close()
+
+	services := []models.Service{}
+	for rows.Next() {
+		var service models.Service
+		if err := scanService(rows, &service); err != nil {
+			return nil, fmt.Errorf("scan service: %w", err)
+		}
+		services = append(services, service)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+
+	return &ServiceListResult{
+		Services: services,
+		Total:    total,
+	}, nil
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/models"
+)
+
+// ErrNothingToMerge is returned when no subscription is named after any of
+// the sources of a merge.
+var ErrNothingToMerge = errors.New("no subscriptions to merge")
+
+// ServiceNameUsage is a distinct service name of the subscriptions of a
+// tenant and how many subscriptions use it.
+type ServiceNameUsage struct {
+	Name string
+	// ServiceID is the catalog entry the name is linked to, if any
+	ServiceID          *int
+	SubscriptionsCount int
+}
+
+type ServiceMergeListParams struct {
+	Page  int
+	Limit int
+}
+
+type ServiceMergeListResult struct {
+	Merges []models.ServiceMerge
+	Total  int
+}
+
+const serviceMergeColumns = `id, tenant_id, target, sources, service_id, subscriptions_count, merged_by, created_at`
+
+func scanServiceMerge(row pgx.Row, merge *models.ServiceMerge) error {
+	return row.Scan(
+		&merge.ID,
+		&merge.TenantID,
+		&merge.Target,
+		&merge.Sources,
+		&merge.ServiceID,
+		&merge.SubscriptionsCount,
+		&merge.MergedBy,
+		&merge.CreatedAt,
+	)
+}
+
+// ListServiceNames returns every distinct service name in use, ordered by
+// name.
+func (s *PostgresStorage) ListServiceNames(ctx context.Context) ([]ServiceNameUsage, error) {
+	query := `SELECT service_name, MAX(service_id), COUNT(*)
+	FROM subscription
+	WHERE tenant_visible(tenant_id)
+	GROUP BY service_name
+	ORDER BY service_name`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list service names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []ServiceNameUsage
+	for rows.Next() {
+		var usage ServiceNameUsage
+		if err := rows.Scan(&usage.Name, &usage.ServiceID, &usage.SubscriptionsCount); err != nil {
+			return nil, fmt.Errorf("scan service name: %w", err)
+		}
+		names = append(names, usage)
+	}
+	return names, rows.Err()
+}
+
+// MergeServiceNames renames the subscriptions named after any of
+// merge.Sources to merge.Target in one transaction, recording the merge
+// and the previous name of every subscription. A target in the catalog is
+// replaced by its canonical name and the subscriptions are linked to it.
+func (s *PostgresStorage) MergeServiceNames(ctx context.Context, merge *models.ServiceMerge) error {
+	// the previous names are selected first, RETURNING only sees the new row
+	update := `WITH previous AS (
+		SELECT id AS previous_id, service_name AS previous_name FROM subscription
+		WHERE tenant_visible(tenant_id) AND service_name = ANY($3)
+		FOR UPDATE
+	)
+	UPDATE subscription SET service_name = $1, service_id = $2, updated_at = NOW()
+	FROM previous
+	WHERE id = previous_id AND (service_name <> $1 OR service_id IS DISTINCT FROM $2)
+	RETURNING id, previous_name`
+
+	insert := `INSERT INTO service_merge (target, sources, service_id, subscriptions_count, merged_by)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + serviceMergeColumns
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		service, err := lookupService(ctx, tx, merge.Target)
+		if err != nil {
+			return err
+		}
+		if service != nil {
+			merge.ServiceID, merge.Target = &service.ID, service.Name
+		}
+
+		rows, err := tx.Query(ctx, update, merge.Target, merge.ServiceID, merge.Sources)
+		if err != nil {
+			return err
+		}
+		var ids []int
+		var previousNames []string
+		for rows.Next() {
+			var id int
+			var previousName string
+			if err := rows.Scan(&id, &previousName); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			previousNames = append(previousNames, previousName)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return ErrNothingToMerge
+		}
+
+		row := tx.QueryRow(ctx, insert, merge.Target, merge.Sources, merge.ServiceID, len(ids), merge.MergedBy)
+		if err := scanServiceMerge(row, merge); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `INSERT INTO service_merge_subscription (merge_id, subscription_id, previous_name)
+		SELECT $1, * FROM unnest($2::integer[], $3::text[])`, merge.ID, ids, previousNames)
+		if err != nil {
+			return err
+		}
+
+		// merged subscriptions are published like any other update
+		rows, err = tx.Query(ctx, `SELECT `+subscriptionColumns+` FROM subscription WHERE id = ANY($1)`, ids)
+		if err != nil {
+			return err
+		}
+		subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Subscription, error) {
+			var sub models.Subscription
+			err := scanSubscription(row, &sub)
+			return sub, err
+		})
+		if err != nil {
+			return err
+		}
+		for i := range subs {
+			if err := insertOutbox(ctx, tx, events.New(events.SubscriptionUpdated, &subs[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("merge service names: %w", err)
+	}
+	return nil
+}
+
+// ListServiceMerges returns the merges of the tenant, most recent first.
+func (s *PostgresStorage) ListServiceMerges(ctx context.Context, params ServiceMergeListParams) (*ServiceMergeListResult, error) {
+	offset := (params.Page - 1) * params.Limit
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM service_merge WHERE tenant_visible(tenant_id)`).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count service merges: %w", err)
+	}
+
+	query := `SELECT ` + serviceMergeColumns + ` FROM service_merge
+	WHERE tenant_visible(tenant_id)
+	ORDER BY id DESC
+	LIMIT $1 OFFSET $2`
+
+	rows, err := s.pool.Query(ctx, query, params.Limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list service merges: %w", err)
+	}
+	defer rows.Close()
+
+	merges := []models.ServiceMerge{}
+	for rows.Next() {
+		var merge models.ServiceMerge
+		if err := scanServiceMerge(rows, &merge); err != nil {
+			return nil, fmt.Errorf("scan service merge: %w", err)
+		}
+		merges = append(merges, merge)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list service merges: %w", err)
+	}
+
+	return &ServiceMergeListResult{
+		Merges: merges,
+		Total:  total,
+	}, nil
+}
@@ -7,12 +7,16 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/events"
 	"github.com/seeques/subman/internal/models"
 )
 
 type ListParams struct {
-	Page  int
-	Limit int
+	Page     int
+	Limit    int
+	UserID   *uuid.UUID
+	Category string
+	Tag      string
 }
 
 type ListResult struct {
@@ -21,27 +25,58 @@ type ListResult struct {
 }
 
 type TotalCostParams struct {
-    StartPeriod time.Time
-    EndPeriod   time.Time
-    UserID      *uuid.UUID
-    ServiceName string
+	StartPeriod time.Time
+	EndPeriod   time.Time
+	UserID      *uuid.UUID
+	ServiceName string
+	Category    string
+	Tag         string
 }
 
-func (s *PostgresStorage) CreateSubscription(ctx context.Context, sub *models.Subscription) error {
-	query := `INSERT INTO subscription (service_name, price, user_id, start_date, end_date)
-	VALUES ($1, $2, $3, $4, $5)
-	RETURNING id, service_name, price, user_id, start_date, end_date, created_at, updated_at`
+// subscriptionColumns selects the tags of a subscription along with its
+// row, so it only works on the subscription table without an alias.
+const subscriptionColumns = `id, tenant_id, service_name, service_id, category, ` + subscriptionTags + `, price, user_id, start_date, end_date, trial_end_date, created_at, updated_at`
 
-	err := s.pool.QueryRow(ctx, query, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate).Scan(
+func scanSubscription(row pgx.Row, sub *models.Subscription) error {
+	return row.Scan(
 		&sub.ID,
+		&sub.TenantID,
 		&sub.ServiceName,
+		&sub.ServiceID,
+		&sub.Category,
+		&sub.Tags,
 		&sub.Price,
 		&sub.UserID,
 		&sub.StartDate,
 		&sub.EndDate,
+		&sub.TrialEndDate,
 		&sub.CreatedAt,
 		&sub.UpdatedAt,
 	)
+}
+
+func (s *PostgresStorage) CreateSubscription(ctx context.Context, sub *models.Subscription) error {
+	query := `INSERT INTO subscription (service_name, service_id, category, price, user_id, start_date, end_date, trial_end_date)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING ` + subscriptionColumns
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := resolveService(ctx, tx, sub); err != nil {
+			return err
+		}
+		tags := sub.Tags
+		row := tx.QueryRow(ctx, query, sub.ServiceName, sub.ServiceID, sub.Category, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.TrialEndDate)
+		if err := scanSubscription(row, sub); err != nil {
+			return err
+		}
+		if err := setSubscriptionTags(ctx, tx, sub, tags); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, events.New(events.SubscriptionCreated, sub))
+	})
+	if violates(err, "subscription_user_fkey") {
+		return ErrUserNotFound
+	}
 	if err != nil {
 		return fmt.Errorf("create subscription: %w", err)
 	}
@@ -49,68 +84,68 @@ func (s *PostgresStorage) CreateSubscription(ctx context.Context, sub *models.Su
 }
 
 func (s *PostgresStorage) GetSubscription(ctx context.Context, id int) (*models.Subscription, error) {
-	query := `SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
+	query := `SELECT ` + subscriptionColumns + `
 	FROM subscription
-	WHERE id = $1`
+	WHERE id = $1 AND tenant_visible(tenant_id)`
 
 	var sub models.Subscription
-	err := s.pool.QueryRow(ctx, query, id).Scan(
-		&sub.ID,
-		&sub.ServiceName,
-		&sub.Price,
-		&sub.UserID,
-		&sub.StartDate,
-		&sub.EndDate,
-		&sub.CreatedAt,
-		&sub.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanSubscription(s.pool.QueryRow(ctx, query, id), &sub); err != nil {
 		return nil, fmt.Errorf("get subscription: %w", err)
 	}
 	return &sub, nil
 }
 
 func (s *PostgresStorage) UpdateSubscription(ctx context.Context, sub *models.Subscription) error {
-	query := `UPDATE subscription SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5, updated_at = NOW()
-	WHERE id = $6
-	RETURNING id, service_name, price, user_id, start_date, end_date, created_at, updated_at`
+	query := `UPDATE subscription SET service_name = $1, service_id = $2, category = $3, price = $4, user_id = $5, start_date = $6, end_date = $7, trial_end_date = $8, updated_at = NOW()
+	WHERE id = $9 AND tenant_visible(tenant_id)
+	RETURNING ` + subscriptionColumns
 
-	err := s.pool.QueryRow(ctx, query, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.ID).Scan(
-		&sub.ID,
-		&sub.ServiceName,
-		&sub.Price,
-		&sub.UserID,
-		&sub.StartDate,
-		&sub.EndDate,
-		&sub.CreatedAt,
-		&sub.UpdatedAt,
-	)
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := resolveService(ctx, tx, sub); err != nil {
+			return err
+		}
+		tags := sub.Tags
+		row := tx.QueryRow(ctx, query, sub.ServiceName, sub.ServiceID, sub.Category, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.TrialEndDate, sub.ID)
+		if err := scanSubscription(row, sub); err != nil {
+			return err
+		}
+		if err := setSubscriptionTags(ctx, tx, sub, tags); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, events.New(events.SubscriptionUpdated, sub))
+	})
+	if violates(err, "subscription_user_fkey") {
+		return ErrUserNotFound
+	}
 	if err != nil {
-		fmt.Errorf("update subscription: %w", err)
+		return fmt.Errorf("update subscription: %w", err)
 	}
 	return nil
 }
 
-func (s *PostgresStorage) DeleteSubscription(ctx context.Context, id int)  error {
-	query := `DELETE FROM subscription WHERE id = $1`
+func (s *PostgresStorage) DeleteSubscription(ctx context.Context, id int) (*models.Subscription, error) {
+	query := `DELETE FROM subscription WHERE id = $1 AND tenant_visible(tenant_id)
+	RETURNING ` + subscriptionColumns
 
-	result, err := s.pool.Exec(ctx, query, id)
+	var sub models.Subscription
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := scanSubscription(tx.QueryRow(ctx, query, id), &sub); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, events.New(events.SubscriptionDeleted, &sub))
+	})
 	if err != nil {
-		return fmt.Errorf("delete subscription", err)
+		return nil, fmt.Errorf("delete subscription: %w", err)
 	}
 
-	if result.RowsAffected() == 0 {
-        return pgx.ErrNoRows
-    }
-
-	return nil
+	return &sub, nil
 }
 
 func (s *PostgresStorage) GetSubscriptionsForPeriod(ctx context.Context, params TotalCostParams) ([]models.Subscription, error) {
 	// start_date <= end_period ($2) and end_date >= start_period ($1)
-	query := `SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
+	query := `SELECT ` + subscriptionColumns + `
 	FROM subscription
-	WHERE start_date <= $2 AND (end_date >= $1 OR end_date IS NULL)`
+	WHERE tenant_visible(tenant_id) AND start_date <= $2 AND (end_date >= $1 OR end_date IS NULL)`
 
 	args := []interface{}{params.StartPeriod, params.EndPeriod}
 	argNum := 3
@@ -121,60 +156,108 @@ func (s *PostgresStorage) GetSubscriptionsForPeriod(ctx context.Context, params
 		argNum++
 	}
 
+	// names of catalog entries also match the subscriptions linked to them
 	if params.ServiceName != "" {
-		query += fmt.Sprintf(" AND service_name = $%d", argNum)
+		query += fmt.Sprintf(` AND (service_name = $%[1]d OR service_id IN (
+			SELECT service_id FROM service_lookup WHERE name_key = service_key($%[1]d) AND tenant_visible(tenant_id)))`, argNum)
 		args = append(args, params.ServiceName)
 		argNum++
 	}
 
+	if params.Category != "" {
+		query += fmt.Sprintf(" AND category = $%d", argNum)
+		args = append(args, params.Category)
+		argNum++
+	}
+
+	if params.Tag != "" {
+		query += fmt.Sprintf(" AND id IN (%s)", taggedWith(argNum))
+		args = append(args, params.Tag)
+		argNum++
+	}
+
 	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
-        return nil, fmt.Errorf("get subscriptions for period: %w", err)
-    }
-    defer rows.Close()
+		return nil, fmt.Errorf("get subscriptions for period: %w", err)
+	}
+	defer rows.Close()
 
 	var subs []models.Subscription
 	for rows.Next() {
 		var sub models.Subscription
-		err := rows.Scan(
-			&sub.ID,
-            &sub.ServiceName,
-            &sub.Price,
-            &sub.UserID,
-            &sub.StartDate,
-            &sub.EndDate,
-            &sub.CreatedAt,
-            &sub.UpdatedAt,
-		)
-		if err != nil {
-            return nil, fmt.Errorf("scan subscription: %w", err)
-        }
+		if err := scanSubscription(rows, &sub); err != nil {
+			return nil, fmt.Errorf("scan subscription: %w", err)
+		}
 		subs = append(subs, sub)
 	}
 	return subs, rows.Err()
 }
 
+// GetSubscriptionsByUsers returns the subscriptions of the given users keyed
+// by user id, each slice ordered by start date.
+func (s *PostgresStorage) GetSubscriptionsByUsers(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID][]models.Subscription, error) {
+	subs := make(map[uuid.UUID][]models.Subscription)
+	if len(userIDs) == 0 {
+		return subs, nil
+	}
+
+	query := `SELECT ` + subscriptionColumns + `
+	FROM subscription
+	WHERE user_id = ANY($1) AND tenant_visible(tenant_id)
+	ORDER BY user_id, start_date, id`
+
+	rows, err := s.pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("get subscriptions by users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sub models.Subscription
+		if err := scanSubscription(rows, &sub); err != nil {
+			return nil, fmt.Errorf("scan subscription: %w", err)
+		}
+		subs[sub.UserID] = append(subs[sub.UserID], sub)
+	}
+	return subs, rows.Err()
+}
+
 func (s *PostgresStorage) ListAllSubscriptions(ctx context.Context, params ListParams) (*ListResult, error) {
 	// limit = 10
 	// 1st page: offset = 0
 	// 2nd page: offset = 10
 	offset := (params.Page - 1) * params.Limit
 
+	where := " WHERE tenant_visible(tenant_id)"
+	args := []interface{}{}
+	if params.UserID != nil {
+		args = append(args, *params.UserID)
+		where += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+	if params.Category != "" {
+		args = append(args, params.Category)
+		where += fmt.Sprintf(" AND category = $%d", len(args))
+	}
+	if params.Tag != "" {
+		args = append(args, params.Tag)
+		where += fmt.Sprintf(" AND id IN (%s)", taggedWith(len(args)))
+	}
+
 	// Get total count
 	var total int
-	countQuery := `SELECT COUNT(*) FROM subscription`
-	err := s.pool.QueryRow(ctx, countQuery).Scan(&total)
+	countQuery := `SELECT COUNT(*) FROM subscription` + where
+	err := s.pool.QueryRow(ctx, countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("count subscriptions: %w", err)
 	}
 
 	// Get page
-	pageQuery := `SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
-	FROM subscription
+	pageQuery := `SELECT ` + subscriptionColumns + `
+	FROM subscription` + where + fmt.Sprintf(`
 	ORDER BY created_at DESC
-	LIMIT $1 OFFSET $2`
+	LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
 
-	rows, err := s.pool.Query(ctx, pageQuery, params.Limit, offset)
+	rows, err := s.pool.Query(ctx, pageQuery, append(args, params.Limit, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("list subscription: %v", err)
 	}
@@ -183,17 +266,7 @@ func (s *PostgresStorage) ListAllSubscriptions(ctx context.Context, params ListP
 	var subscriptions []models.Subscription
 	for rows.Next() {
 		var sub models.Subscription
-		err := rows.Scan(
-			&sub.ID,
-			&sub.ServiceName,
-			&sub.Price,
-			&sub.UserID,
-			&sub.StartDate,
-			&sub.EndDate,
-			&sub.CreatedAt,
-			&sub.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanSubscription(rows, &sub); err != nil {
 			return nil, fmt.Errorf("scan subscription: %v", err)
 		}
 		subscriptions = append(subscriptions, sub)
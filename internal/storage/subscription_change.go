@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/models"
+)
+
+// SubscriptionChangesChannel is the channel the subscription trigger
+// notifies with the id of every recorded change.
+const SubscriptionChangesChannel = "subscription_changes"
+
+// subscriptionRow is the to_jsonb() form of a subscription row as stored by
+// the change trigger. Postgres renders DATE as 2006-01-02 and TIMESTAMP
+// without a time zone, which encoding/json can't parse into time.Time.
+type subscriptionRow struct {
+	ID           int       `json:"id"`
+	TenantID     uuid.UUID `json:"tenant_id"`
+	ServiceName  string    `json:"service_name"`
+	ServiceID    *int      `json:"service_id"`
+	Category     string    `json:"category"`
+	Price        int       `json:"price"`
+	UserID       uuid.UUID `json:"user_id"`
+	StartDate    pgTime    `json:"start_date"`
+	EndDate      *pgTime   `json:"end_date"`
+	TrialEndDate *pgTime   `json:"trial_end_date"`
+	CreatedAt    pgTime    `json:"created_at"`
+	UpdatedAt    pgTime    `json:"updated_at"`
+}
+
+type pgTime struct {
+	time.Time
+}
+
+func (t *pgTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	layout := "2006-01-02T15:04:05.999999"
+	if !strings.Contains(s, "T") {
+		layout = "2006-01-02"
+	}
+
+	parsed, err := time.Parse(layout, s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+func (t *pgTime) ptr() *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
+
+func decodeSubscriptionRow(payload []byte) (models.Subscription, error) {
+	var row subscriptionRow
+	if err := json.Unmarshal(payload, &row); err != nil {
+		return models.Subscription{}, err
+	}
+
+	return models.Subscription{
+		ID:           row.ID,
+		TenantID:     row.TenantID,
+		ServiceName:  row.ServiceName,
+		ServiceID:    row.ServiceID,
+		Category:     row.Category,
+		Price:        row.Price,
+		UserID:       row.UserID,
+		StartDate:    row.StartDate.Time,
+		EndDate:      row.EndDate.ptr(),
+		TrialEndDate: row.TrialEndDate.ptr(),
+		CreatedAt:    row.CreatedAt.Time,
+		UpdatedAt:    row.UpdatedAt.Time,
+	}, nil
+}
+
+// ListSubscriptionChanges returns up to limit changes recorded after afterID
+// in id order, optionally only those of a single user.
+func (s *PostgresStorage) ListSubscriptionChanges(ctx context.Context, afterID int64, userID *uuid.UUID, limit int) ([]models.SubscriptionChange, error) {
+	query := `SELECT id, operation, payload, created_at
+	FROM subscription_change
+	WHERE id > $1 AND tenant_visible(tenant_id)`
+
+	args := []interface{}{afterID}
+	if userID != nil {
+		query += ` AND user_id = $2`
+		args = append(args, *userID)
+	}
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args)+1)
+	args = append(args, limit)
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list subscription changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []models.SubscriptionChange
+	for rows.Next() {
+		var change models.SubscriptionChange
+		var payload []byte
+		if err := rows.Scan(&change.ID, &change.Operation, &payload, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan subscription change: %w", err)
+		}
+
+		change.Subscription, err = decodeSubscriptionRow(payload)
+		if err != nil {
+			return nil, fmt.Errorf("decode subscription change %d: %w", change.ID, err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (s *PostgresStorage) LatestSubscriptionChangeID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := s.pool.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM subscription_change WHERE tenant_visible(tenant_id)`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("latest subscription change id: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteSubscriptionChanges removes changes older than retention. Clients
+// can't resume from an event that was removed.
+func (s *PostgresStorage) DeleteSubscriptionChanges(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM subscription_change WHERE created_at < NOW() - make_interval(secs => $1) AND tenant_visible(tenant_id)`
+
+	result, err := s.pool.Exec(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("delete subscription changes: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// ListenSubscriptionChanges holds a connection listening on
+// SubscriptionChangesChannel and calls onNotify for every notification until
+// ctx is cancelled or the connection fails. onNotify is also called once
+// the listener is set up, so changes made while it was not listening are
+// picked up.
+func (s *PostgresStorage) ListenSubscriptionChanges(ctx context.Context, onNotify func()) error {
+	pooled, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire listen connection: %w", err)
+	}
+	// the connection stays in LISTEN state, so it must not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+SubscriptionChangesChannel); err != nil {
+		return fmt.Errorf("listen subscription changes: %w", err)
+	}
+
+	onNotify()
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return fmt.Errorf("wait for subscription changes: %w", err)
+		}
+		onNotify()
+	}
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/models"
+)
+
+// subscriptionTags selects the tag names of the subscription of the
+// enclosing query in name order.
+const subscriptionTags = `ARRAY(
+		SELECT t.name FROM subscription_tag st JOIN tag t ON t.id = st.tag_id
+		WHERE st.subscription_id = subscription.id
+		ORDER BY t.name COLLATE "C"
+	) AS tags`
+
+// taggedWith selects the ids of the subscriptions tagged with the tag in
+// parameter n.
+func taggedWith(n int) string {
+	return fmt.Sprintf(`SELECT st.subscription_id FROM subscription_tag st JOIN tag t ON t.id = st.tag_id
+		WHERE t.name = $%d AND tenant_visible(st.tenant_id)`, n)
+}
+
+// setSubscriptionTags replaces the tags of sub with tags, creating the tags
+// that don't exist yet.
+func setSubscriptionTags(ctx context.Context, tx pgx.Tx, sub *models.Subscription, tags []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM subscription_tag WHERE subscription_id = $1`, sub.ID); err != nil {
+		return fmt.Errorf("clear subscription tags: %w", err)
+	}
+
+	// the same order subscriptionTags selects them in
+	sub.Tags = slices.Compact(slices.Sorted(slices.Values(tags)))
+	if len(sub.Tags) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `INSERT INTO tag (name) SELECT unnest($1::text[])
+	ON CONFLICT (tenant_id, name) DO NOTHING`, sub.Tags)
+	if err != nil {
+		return fmt.Errorf("create tags: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO subscription_tag (subscription_id, tag_id)
+	SELECT $1, id FROM tag WHERE name = ANY($2) AND tenant_visible(tenant_id)`, sub.ID, sub.Tags)
+	if err != nil {
+		return fmt.Errorf("tag subscription: %w", err)
+	}
+	return nil
+}
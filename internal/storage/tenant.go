@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/seeques/subman/internal/models"
+)
+
+var (
+	// ErrTenantExists is returned when a tenant with the same name exists.
+	ErrTenantExists = errors.New("tenant already exists")
+	// ErrTenantInUse is returned when deleting a tenant that still owns data.
+	ErrTenantInUse = errors.New("tenant still owns data")
+)
+
+const tenantColumns = `id, name, created_at`
+
+func scanTenant(row pgx.Row, t *models.Tenant) error {
+	return row.Scan(
+		&t.ID,
+		&t.Name,
+		&t.CreatedAt,
+	)
+}
+
+func isPgError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// violates reports whether err was caused by the named constraint.
+func violates(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.ConstraintName == constraint
+}
+
+func (s *PostgresStorage) CreateTenant(ctx context.Context, t *models.Tenant) error {
+	query := `INSERT INTO tenant (name) VALUES ($1) RETURNING ` + tenantColumns
+
+	err := scanTenant(s.pool.QueryRow(ctx, query, t.Name), t)
+	if isPgError(err, "23505") {
+		return ErrTenantExists
+	}
+	if err != nil {
+		return fmt.Errorf("create tenant: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetTenant(ctx context.Context, id uuid.UUID) (*models.Tenant, error) {
+	query := `SELECT ` + tenantColumns + ` FROM tenant WHERE id = $1`
+
+	var t models.Tenant
+	if err := scanTenant(s.pool.QueryRow(ctx, query, id), &t); err != nil {
+		return nil, fmt.Errorf("get tenant: %w", err)
+	}
+	return &t, nil
+}
+
+func (s *PostgresStorage) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+tenantColumns+` FROM tenant ORDER BY created_at, name`)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		var t models.Tenant
+		if err := scanTenant(rows, &t); err != nil {
+			return nil, fmt.Errorf("scan tenant: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// DeleteTenant removes a tenant together with its API keys and event
+// history. Tenants that still have subscriptions, budgets or webhooks are
+// kept and ErrTenantInUse is returned.
+func (s *PostgresStorage) DeleteTenant(ctx context.Context, id uuid.UUID) error {
+	result, err := s.pool.Exec(ctx, `DELETE FROM tenant WHERE id = $1`, id)
+	if isPgError(err, "23503") {
+		return ErrTenantInUse
+	}
+	if err != nil {
+		return fmt.Errorf("delete tenant: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/models"
+)
+
+var (
+	// ErrUserExists is returned when creating a user with a taken id.
+	ErrUserExists = errors.New("user already exists")
+	// ErrEmailTaken is returned when another user of the tenant has the email.
+	ErrEmailTaken = errors.New("email already taken")
+	// ErrUserNotFound is returned when a subscription refers to a user that
+	// doesn't exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserInUse is returned when deleting a user that still has subscriptions.
+	ErrUserInUse = errors.New("user still has subscriptions")
+)
+
+type UserListParams struct {
+	Page  int
+	Limit int
+	// ID narrows the list to a single user, for callers restricted to it
+	ID *uuid.UUID
+}
+
+type UserListResult struct {
+	Users []models.User
+	Total int
+}
+
+const userColumns = `id, tenant_id, display_name, email, default_currency, timezone, created_at, updated_at`
+
+func scanUser(row pgx.Row, user *models.User) error {
+	return row.Scan(
+		&user.ID,
+		&user.TenantID,
+		&user.DisplayName,
+		&user.Email,
+		&user.DefaultCurrency,
+		&user.Timezone,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+}
+
+// userError maps violated unique constraints of app_user to the errors above.
+func userError(err error) error {
+	switch {
+	case violates(err, "idx_app_user_email"):
+		return ErrEmailTaken
+	case isPgError(err, "23505"):
+		return ErrUserExists
+	}
+	return err
+}
+
+// CreateUser creates a user, with a new id unless user.ID is set.
+func (s *PostgresStorage) CreateUser(ctx context.Context, user *models.User) error {
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+
+	query := `INSERT INTO app_user (id, display_name, email, default_currency, timezone)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + userColumns
+
+	row := s.pool.QueryRow(ctx, query, user.ID, user.DisplayName, user.Email, user.DefaultCurrency, user.Timezone)
+	if err := scanUser(row, user); err != nil {
+		return fmt.Errorf("create user: %w", userError(err))
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM app_user WHERE id = $1 AND tenant_visible(tenant_id)`
+
+	var user models.User
+	if err := scanUser(s.pool.QueryRow(ctx, query, id), &user); err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	return &user, nil
+}
+
+// GetUsers returns the users of userIDs by id, leaving out unknown ones.
+func (s *PostgresStorage) GetUsers(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*models.User, error) {
+	users := make(map[uuid.UUID]*models.User)
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	query := `SELECT ` + userColumns + ` FROM app_user WHERE id = ANY($1) AND tenant_visible(tenant_id)`
+
+	rows, err := s.pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("get users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users[user.ID] = &user
+	}
+	return users, rows.Err()
+}
+
+func (s *PostgresStorage) UpdateUser(ctx context.Context, user *models.User) error {
+	query := `UPDATE app_user SET display_name = $1, email = $2, default_currency = $3, timezone = $4, updated_at = NOW()
+	WHERE id = $5 AND tenant_visible(tenant_id)
+	RETURNING ` + userColumns
+
+	row := s.pool.QueryRow(ctx, query, user.DisplayName, user.Email, user.DefaultCurrency, user.Timezone, user.ID)
+	if err := scanUser(row, user); err != nil {
+		return fmt.Errorf("update user: %w", userError(err))
+	}
+	return nil
+}
+
+// DeleteUser deletes a user without subscriptions. Users with subscriptions
+// are kept and ErrUserInUse is returned.
+func (s *PostgresStorage) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	result, err := s.pool.Exec(ctx, `DELETE FROM app_user WHERE id = $1 AND tenant_visible(tenant_id)`, id)
+	if isPgError(err, "23503") {
+		return ErrUserInUse
+	}
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListUsers(ctx context.Context, params UserListParams) (*UserListResult, error) {
+	offset := (params.Page - 1) * params.Limit
+
+	where := " WHERE tenant_visible(tenant_id)"
+	args := []interface{}{}
+	if params.ID != nil {
+		where += " AND id = $1"
+		args = append(args, *params.ID)
+	}
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM app_user`+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count users: %w", err)
+	}
+
+	pageQuery := `SELECT ` + userColumns + ` FROM app_user` + where +
+		fmt.Sprintf(" ORDER BY display_name, id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+
+	rows, err := s.pool.Query(ctx, pageQuery, append(args, params.Limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+
+	return &UserListResult{
+		Users: users,
+		Total: total,
+	}, nil
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/seeques/subman/internal/models"
+)
+
+// ErrLeaseExpired is returned when a claimed delivery is marked after its
+// lease ran out, as another worker may have claimed it since.
+var ErrLeaseExpired = errors.New("delivery lease expired")
+
+// ClaimedDelivery is a due delivery together with the endpoint it goes to.
+type ClaimedDelivery struct {
+	models.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+type DeliveryListResult struct {
+	Deliveries []models.WebhookDelivery
+	Total      int
+}
+
+const webhookEndpointColumns = `id, url, secret, event_types, active, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_error, created_at, updated_at`
+
+func scanWebhookEndpoint(row pgx.Row, endpoint *models.WebhookEndpoint) error {
+	return row.Scan(
+		&endpoint.ID,
+		&endpoint.URL,
+		&endpoint.Secret,
+		&endpoint.EventTypes,
+		&endpoint.Active,
+		&endpoint.CreatedAt,
+		&endpoint.UpdatedAt,
+	)
+}
+
+func webhookDeliveryFields(delivery *models.WebhookDelivery) []interface{} {
+	return []interface{}{
+		&delivery.ID,
+		&delivery.EndpointID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	}
+}
+
+func (s *PostgresStorage) CreateWebhookEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	query := `INSERT INTO webhook_endpoint (url, secret, event_types, active)
+	VALUES ($1, $2, $3, $4)
+	RETURNING ` + webhookEndpointColumns
+
+	row := s.pool.QueryRow(ctx, query, endpoint.URL, endpoint.Secret, endpoint.EventTypes, endpoint.Active)
+	if err := scanWebhookEndpoint(row, endpoint); err != nil {
+		return fmt.Errorf("create webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetWebhookEndpoint(ctx context.Context, id int) (*models.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoint WHERE id = $1 AND tenant_visible(tenant_id)`
+
+	var endpoint models.WebhookEndpoint
+	if err := scanWebhookEndpoint(s.pool.QueryRow(ctx, query, id), &endpoint); err != nil {
+		return nil, fmt.Errorf("get webhook endpoint: %w", err)
+	}
+	return &endpoint, nil
+}
+
+func (s *PostgresStorage) ListWebhookEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoint WHERE tenant_visible(tenant_id) ORDER BY id`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []models.WebhookEndpoint
+	for rows.Next() {
+		var endpoint models.WebhookEndpoint
+		if err := scanWebhookEndpoint(rows, &endpoint); err != nil {
+			return nil, fmt.Errorf("scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (s *PostgresStorage) DeleteWebhookEndpoint(ctx context.Context, id int) error {
+	result, err := s.pool.Exec(ctx, `DELETE FROM webhook_endpoint WHERE id = $1 AND tenant_visible(tenant_id)`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook endpoint: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// EnqueueWebhookEvent queues the event for every active endpoint subscribed
+// to its type and returns how many deliveries were queued. Queueing the same
+// event twice is a no-op.
+func (s *PostgresStorage) EnqueueWebhookEvent(ctx context.Context, eventID uuid.UUID, eventType string, payload []byte) (int64, error) {
+	query := `INSERT INTO webhook_delivery (endpoint_id, event_id, event_type, payload, tenant_id)
+	SELECT id, $1, $2, $3, tenant_id
+	FROM webhook_endpoint
+	WHERE active AND $2 = ANY(event_types) AND tenant_visible(tenant_id)
+	ON CONFLICT (endpoint_id, event_id) DO NOTHING`
+
+	result, err := s.pool.Exec(ctx, query, eventID, eventType, payload)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue webhook event: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// ClaimDueWebhookDeliveries leases up to limit pending deliveries whose next
+// attempt is due by pushing their next attempt lease into the future, so
+// other instances skip them while they are being sent. The NextAttemptAt of
+// a claimed delivery holds the end of its lease.
+func (s *PostgresStorage) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error) {
+	query := `WITH due AS (
+		SELECT id FROM webhook_delivery
+		WHERE status = 'pending' AND next_attempt_at <= NOW() AND tenant_visible(tenant_id)
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE webhook_delivery d
+	SET next_attempt_at = NOW() + make_interval(secs => $2), updated_at = NOW()
+	FROM due, webhook_endpoint e
+	WHERE d.id = due.id AND e.id = d.endpoint_id
+	RETURNING d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+		d.next_attempt_at, d.last_error, d.created_at, d.updated_at, e.url, e.secret`
+
+	rows, err := s.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []ClaimedDelivery
+	for rows.Next() {
+		var delivery ClaimedDelivery
+		fields := append(webhookDeliveryFields(&delivery.WebhookDelivery), &delivery.URL, &delivery.Secret)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		claimed = append(claimed, delivery)
+	}
+	return claimed, rows.Err()
+}
+
+// MarkWebhookDelivered records a successful attempt of a delivery claimed
+// until leasedUntil. It returns ErrLeaseExpired when the delivery was claimed
+// again since.
+func (s *PostgresStorage) MarkWebhookDelivered(ctx context.Context, id int64, leasedUntil time.Time) error {
+	query := `UPDATE webhook_delivery
+	SET status = 'delivered', attempts = attempts + 1, last_error = NULL, updated_at = NOW()
+	WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2 AND tenant_visible(tenant_id)`
+
+	result, err := s.pool.Exec(ctx, query, id, leasedUntil)
+	if err != nil {
+		return fmt.Errorf("mark webhook delivered: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// MarkWebhookFailed records a failed attempt of a delivery claimed until
+// leasedUntil. The delivery is retried after retryIn, or moved to the
+// dead-letter list when dead is set. It returns ErrLeaseExpired when the
+// delivery was claimed again since.
+func (s *PostgresStorage) MarkWebhookFailed(ctx context.Context, id int64, leasedUntil time.Time, lastError string, retryIn time.Duration, dead bool) error {
+	status := models.DeliveryStatusPending
+	if dead {
+		status = models.DeliveryStatusDead
+	}
+
+	query := `UPDATE webhook_delivery
+	SET status = $3, attempts = attempts + 1, last_error = $4,
+		next_attempt_at = NOW() + make_interval(secs => $5), updated_at = NOW()
+	WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2 AND tenant_visible(tenant_id)`
+
+	result, err := s.pool.Exec(ctx, query, id, leasedUntil, status, lastError, retryIn.Seconds())
+	if err != nil {
+		return fmt.Errorf("mark webhook failed: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// DeleteDeliveredWebhookDeliveries removes deliveries that succeeded longer
+// than retention ago. Pending and dead deliveries are kept.
+func (s *PostgresStorage) DeleteDeliveredWebhookDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM webhook_delivery
+	WHERE status = 'delivered' AND updated_at < NOW() - make_interval(secs => $1) AND tenant_visible(tenant_id)`
+
+	result, err := s.pool.Exec(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("delete delivered webhook deliveries: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+func (s *PostgresStorage) ListDeadWebhookDeliveries(ctx context.Context, params ListParams) (*DeliveryListResult, error) {
+	offset := (params.Page - 1) * params.Limit
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_delivery WHERE status = 'dead' AND tenant_visible(tenant_id)`).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count dead webhook deliveries: %w", err)
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + `
+	FROM webhook_delivery
+	WHERE status = 'dead' AND tenant_visible(tenant_id)
+	ORDER BY id DESC
+	LIMIT $1 OFFSET $2`
+
+	rows, err := s.pool.Query(ctx, query, params.Limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list dead webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := rows.Scan(webhookDeliveryFields(&delivery)...); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return &DeliveryListResult{
+		Deliveries: deliveries,
+		Total:      total,
+	}, rows.Err()
+}
+
+// RetryWebhookDelivery moves a dead delivery back to the queue with a fresh
+// attempt budget.
+func (s *PostgresStorage) RetryWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	query := `UPDATE webhook_delivery
+	SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+	WHERE id = $1 AND status = 'dead' AND tenant_visible(tenant_id)
+	RETURNING ` + webhookDeliveryColumns
+
+	var delivery models.WebhookDelivery
+	if err := s.pool.QueryRow(ctx, query, id).Scan(webhookDeliveryFields(&delivery)...); err != nil {
+		return nil, fmt.Errorf("retry webhook delivery: %w", err)
+	}
+	return &delivery, nil
+}
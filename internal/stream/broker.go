@@ -0,0 +1,222 @@
+package stream
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/storage"
+)
+
+const (
+	// Subscribers that fall this far behind are disconnected and have to
+	// resume. Only live changes count, as history is replayed before subscribing.
+	subscriberBuffer = 64
+	fetchBatchSize   = 500
+	reconnectDelay   = 5 * time.Second
+	// How long a missing change id is waited for before it is taken to
+	// belong to a rolled back transaction
+	gapTimeout = 30 * time.Second
+	// How long changes are kept for clients resuming with Last-Event-ID
+	retention = 7 * 24 * time.Hour
+)
+
+// Subscriber receives the changes of a single stream client. C is closed
+// when the subscriber is too slow or the broker stops.
+type Subscriber struct {
+	C        <-chan models.SubscriptionChange
+	c        chan models.SubscriptionChange
+	tenantID uuid.UUID
+	userID   *uuid.UUID
+}
+
+// Broker listens for subscription changes on a single database connection
+// and fans them out to every subscriber.
+type Broker struct {
+	storage *storage.PostgresStorage
+
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+	cursor      *cursor
+	stopped     bool
+
+	wake chan struct{}
+}
+
+func NewBroker(storage *storage.PostgresStorage) *Broker {
+	return &Broker{
+		storage:     storage,
+		subscribers: make(map[*Subscriber]struct{}),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Subscribe registers a subscriber for the changes of a tenant, optionally
+// only those of a single user. Changes that happened before the call are not
+// delivered and have to be read from storage.
+func (b *Broker) Subscribe(tenantID uuid.UUID, userID *uuid.UUID) *Subscriber {
+	c := make(chan models.SubscriptionChange, subscriberBuffer)
+	sub := &Subscriber{C: c, c: c, tenantID: tenantID, userID: userID}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		close(c)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+// Run listens for changes until ctx is cancelled, reconnecting when the
+// connection is lost. All subscribers are closed when it returns.
+func (b *Broker) Run(ctx context.Context) {
+	defer b.stop()
+
+	lastID, err := b.storage.LatestSubscriptionChangeID(ctx)
+	if err != nil && ctx.Err() == nil {
+		slog.Error("failed to get latest subscription change", "error", err)
+	}
+	b.cursor = newCursor(lastID)
+
+	go b.fetchLoop(ctx)
+	go b.pruneLoop(ctx)
+
+	for {
+		err := b.storage.ListenSubscriptionChanges(ctx, b.notify)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("subscription change listener failed, reconnecting", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (b *Broker) notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// fetchLoop reads new changes from storage whenever a notification arrives.
+// Notifications only carry the change id, so bursts collapse into one read.
+// Ids are taken before commit, so a change can commit after one with a
+// higher id was already streamed. Reads therefore start after the last id
+// below which no change is missing, and skip changes already broadcast; a
+// missing id is given up on after gapTimeout.
+func (b *Broker) fetchLoop(ctx context.Context) {
+	ticker := time.NewTicker(gapTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.wake:
+		case <-ticker.C:
+			// gaps that were never filled still have to expire
+			if !b.cursor.hasGap() {
+				continue
+			}
+		}
+
+		after := b.cursor.after()
+		for {
+			changes, err := b.storage.ListSubscriptionChanges(ctx, after, nil, fetchBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("failed to fetch subscription changes", "error", err)
+				}
+				break
+			}
+
+			for _, change := range changes {
+				if b.cursor.mark(change.ID) {
+					b.broadcast(change)
+				}
+				after = change.ID
+			}
+
+			if len(changes) < fetchBatchSize {
+				break
+			}
+		}
+
+		if skipped := b.cursor.advance(time.Now()); skipped > 0 {
+			slog.Debug("gave up waiting for subscription change ids", "count", skipped)
+		}
+	}
+}
+
+func (b *Broker) broadcast(change models.SubscriptionChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if sub.tenantID != change.Subscription.TenantID {
+			continue
+		}
+		if sub.userID != nil && *sub.userID != change.Subscription.UserID {
+			continue
+		}
+
+		select {
+		case sub.c <- change:
+		default:
+			slog.Warn("disconnecting slow subscription stream client")
+			delete(b.subscribers, sub)
+			close(sub.c)
+		}
+	}
+}
+
+func (b *Broker) pruneLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := b.storage.DeleteSubscriptionChanges(ctx, retention)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("failed to prune subscription changes", "error", err)
+		} else if deleted > 0 {
+			slog.Info("pruned subscription changes", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Broker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,77 @@
+package stream
+
+import "time"
+
+// cursor tracks which change ids were broadcast. Ids up to last are all
+// done; above it, ids broadcast out of order are remembered until the
+// missing ones before them show up or are given up on.
+type cursor struct {
+	last int64
+	sent map[int64]struct{}
+	// since when the id after last has been missing
+	gapSince time.Time
+	gapAt    int64
+}
+
+func newCursor(last int64) *cursor {
+	return &cursor{last: last, sent: make(map[int64]struct{})}
+}
+
+// after is the id changes have to be read after.
+func (c *cursor) after() int64 {
+	return c.last
+}
+
+// mark records id as broadcast, reporting false when it already was.
+func (c *cursor) mark(id int64) bool {
+	if id <= c.last {
+		return false
+	}
+	if _, ok := c.sent[id]; ok {
+		return false
+	}
+	c.sent[id] = struct{}{}
+	return true
+}
+
+func (c *cursor) hasGap() bool {
+	return len(c.sent) > 0
+}
+
+// advance moves last over every id broadcast without a gap before it. An id
+// missing for longer than gapTimeout, counted from now, is skipped, as its
+// transaction most likely rolled back. It returns how many ids were skipped.
+func (c *cursor) advance(now time.Time) int64 {
+	var skipped int64
+	for {
+		for {
+			if _, ok := c.sent[c.last+1]; !ok {
+				break
+			}
+			delete(c.sent, c.last+1)
+			c.last++
+		}
+
+		if len(c.sent) == 0 {
+			c.gapSince = time.Time{}
+			return skipped
+		}
+		if c.gapSince.IsZero() || c.gapAt != c.last+1 {
+			c.gapSince = now
+			c.gapAt = c.last + 1
+		}
+		if now.Sub(c.gapSince) < gapTimeout {
+			return skipped
+		}
+
+		// skip to the lowest id broadcast after the gap
+		next := c.last
+		for id := range c.sent {
+			if next == c.last || id < next {
+				next = id
+			}
+		}
+		skipped += next - 1 - c.last
+		c.last = next - 1
+	}
+}
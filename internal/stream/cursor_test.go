@@ -0,0 +1,87 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorLateCommit(t *testing.T) {
+	now := time.Now()
+	c := newCursor(10)
+
+	// 12 commits before 11
+	if !c.mark(12) {
+		t.Fatal("mark(12) = false, want true")
+	}
+	c.advance(now)
+	if c.after() != 10 {
+		t.Fatalf("after() = %d, want 10 while 11 is missing", c.after())
+	}
+
+	// the next read starts after 10 again, so 12 comes back and is skipped
+	if !c.mark(11) {
+		t.Error("late change 11 was not broadcast")
+	}
+	if c.mark(12) {
+		t.Error("change 12 was broadcast twice")
+	}
+	c.advance(now.Add(time.Second))
+	if c.after() != 12 {
+		t.Errorf("after() = %d, want 12", c.after())
+	}
+	if c.hasGap() {
+		t.Error("hasGap() = true with nothing missing")
+	}
+	if c.mark(11) || c.mark(12) {
+		t.Error("changes up to after() were broadcast again")
+	}
+}
+
+func TestCursorGivesUpOnGaps(t *testing.T) {
+	now := time.Now()
+	c := newCursor(10)
+
+	// 11 rolled back, 14 is still in flight
+	c.mark(12)
+	c.mark(13)
+	c.mark(15)
+	if skipped := c.advance(now); skipped != 0 || c.after() != 10 {
+		t.Fatalf("advance = %d, after() = %d, want 0 and 10 before the timeout", skipped, c.after())
+	}
+	if skipped := c.advance(now.Add(gapTimeout - time.Second)); skipped != 0 || c.after() != 10 {
+		t.Fatalf("advance = %d, after() = %d, want 0 and 10 before the timeout", skipped, c.after())
+	}
+
+	// 11 is given up on; the gap at 14 has only just been found
+	if skipped := c.advance(now.Add(gapTimeout)); skipped != 1 || c.after() != 13 {
+		t.Fatalf("advance = %d, after() = %d, want 1 and 13", skipped, c.after())
+	}
+	if !c.hasGap() {
+		t.Fatal("hasGap() = false with 14 missing")
+	}
+
+	// 14 gets a full timeout of its own
+	if skipped := c.advance(now.Add(gapTimeout + time.Second)); skipped != 0 || c.after() != 13 {
+		t.Fatalf("advance = %d, after() = %d, want 0 and 13", skipped, c.after())
+	}
+	if !c.mark(14) {
+		t.Error("late change 14 was not broadcast")
+	}
+	c.advance(now.Add(gapTimeout + 2*time.Second))
+	if c.after() != 15 || c.hasGap() {
+		t.Errorf("after() = %d, hasGap() = %v, want 15 and no gap", c.after(), c.hasGap())
+	}
+}
+
+func TestCursorInOrder(t *testing.T) {
+	c := newCursor(0)
+	for id := int64(1); id <= 3; id++ {
+		if !c.mark(id) {
+			t.Fatalf("mark(%d) = false", id)
+		}
+	}
+	c.advance(time.Now())
+	if c.after() != 3 || c.hasGap() {
+		t.Errorf("after() = %d, hasGap() = %v, want 3 and no gap", c.after(), c.hasGap())
+	}
+}
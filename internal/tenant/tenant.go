@@ -0,0 +1,44 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// DefaultID is the tenant that owns all data created before tenants
+// existed. Its admins operate the whole installation.
+var DefaultID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// Header lets operators act on behalf of another tenant.
+const Header = "X-Tenant-ID"
+
+type scope struct {
+	id  uuid.UUID
+	all bool
+}
+
+type scopeKey struct{}
+
+// WithTenant scopes every storage call made with ctx to a single tenant.
+func WithTenant(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{id: id})
+}
+
+// WithAllTenants lets storage calls made with ctx see the rows of every
+// tenant. It is meant for background jobs, never for request handling.
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{all: true})
+}
+
+// FromContext returns the tenant ctx is scoped to, if any.
+func FromContext(ctx context.Context) (uuid.UUID, bool) {
+	s, ok := ctx.Value(scopeKey{}).(scope)
+	return s.id, ok && !s.all
+}
+
+// IsAllTenants reports whether ctx was created by WithAllTenants.
+func IsAllTenants(ctx context.Context) bool {
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s.all
+}
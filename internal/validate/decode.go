@@ -0,0 +1,78 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/seeques/subman/internal/response"
+)
+
+// Decode reads a JSON object from body into v, a pointer to a DTO, and
+// checks it with Struct. Besides the rules, it reports fields of the body
+// that v doesn't have, so misspelled fields aren't silently dropped, and
+// values of the wrong type. The error is only set when body isn't a JSON
+// object at all.
+func Decode(body io.Reader, v any) (response.FieldErrors, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, errors.New("body is not a JSON object")
+	}
+
+	var errs response.FieldErrors
+	known := make(map[string]bool)
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		known[jsonName(t.Field(i))] = true
+	}
+	var unknown []string
+	for key := range raw {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs.Add(key, response.CodeUnknownField, "unknown field "+key)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+		errs.Add(typeErr.Field, response.CodeInvalid, typeErr.Field+" must be "+jsonType(typeErr.Type))
+	}
+
+	for _, fe := range Struct(v) {
+		if !errs.Has(fe.Field) {
+			errs = append(errs, fe)
+		}
+	}
+	return errs, nil
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
@@ -0,0 +1,103 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seeques/subman/internal/response"
+)
+
+type decodeDTO struct {
+	ServiceName string   `json:"service_name" validate:"required,max=10"`
+	Price       int      `json:"price" validate:"required,min=1"`
+	Tags        []string `json:"tags,omitempty" validate:"max=2"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want map[string]response.Code
+	}{
+		{
+			name: "valid",
+			body: `{"service_name": "Netflix", "price": 400, "tags": ["family"]}`,
+			want: map[string]response.Code{},
+		},
+		{
+			name: "unknown fields",
+			body: `{"service_name": "Netflix", "price": 400, "prise": 500, "Tags": ["family"]}`,
+			want: map[string]response.Code{
+				"prise": response.CodeUnknownField,
+				// field names are matched exactly
+				"Tags": response.CodeUnknownField,
+			},
+		},
+		{
+			name: "missing required fields",
+			body: `{}`,
+			want: map[string]response.Code{
+				"service_name": response.CodeRequired,
+				"price":        response.CodeRequired,
+			},
+		},
+		{
+			name: "wrong type",
+			body: `{"service_name": "Netflix", "price": "400"}`,
+			want: map[string]response.Code{
+				"price": response.CodeInvalid,
+			},
+		},
+		{
+			name: "rules and unknown fields together",
+			body: `{"service_name": "A very long service name", "price": 0, "tags": ["a", "b", "c"], "extra": true}`,
+			want: map[string]response.Code{
+				"service_name": response.CodeTooLong,
+				"price":        response.CodeRequired,
+				"tags":         response.CodeTooLong,
+				"extra":        response.CodeUnknownField,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dto decodeDTO
+			errs, err := Decode(strings.NewReader(tt.body), &dto)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+
+			if len(errs) != len(tt.want) {
+				t.Errorf("errors = %v, want %v", errs, tt.want)
+			}
+			for field, code := range tt.want {
+				if got := codeOf(errs, field); got != code {
+					t.Errorf("code of %s = %q, want %q", field, got, code)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeReportsTypeErrorOnce(t *testing.T) {
+	var dto decodeDTO
+	errs, err := Decode(strings.NewReader(`{"service_name": 5, "price": 400}`), &dto)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	// the field is also empty, but the type error explains why
+	if len(errs) != 1 || errs[0].Field != "service_name" || errs[0].Message != "service_name must be a string" {
+		t.Errorf("errors = %v, want only the type of service_name", errs)
+	}
+}
+
+func TestDecodeRejectsNonObjects(t *testing.T) {
+	for _, body := range []string{``, `null`, `[]`, `"text"`, `{"price": 400`} {
+		var dto decodeDTO
+		if _, err := Decode(strings.NewReader(body), &dto); err == nil {
+			t.Errorf("Decode(%q) succeeded, want an error", body)
+		}
+	}
+}
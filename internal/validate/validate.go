@@ -0,0 +1,239 @@
+// Package validate checks request DTOs against rules declared in validate
+// struct tags, reporting every invalid field under its JSON name:
+//
+//	ServiceName string `json:"service_name" validate:"required,max=255"`
+//
+// The rules of a field are checked in order up to the first failing one:
+//
+//	required     the field is not the zero value
+//	min=N max=N  bounds of numbers, or of the length of strings and lists
+//	uuid         a UUID
+//	month        a month formatted as MM-YYYY
+//	notbefore=F  a month not before the month in field F
+//	oneof=A B    one of the listed values
+//	email        an email address
+//	timezone     an IANA time zone name such as Europe/Moscow
+//	currency     an ISO 4217 currency code such as RUB
+//
+// Rules other than required are skipped for zero values, so optional fields
+// are only checked when they are given.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // time zones are checked without relying on the host's database
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/response"
+)
+
+// MonthLayout is the format of months in requests.
+const MonthLayout = "01-2006"
+
+type rule struct {
+	name string
+	arg  string
+}
+
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+// fields caches the parsed tags of each DTO type
+var fields sync.Map
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fields.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+
+		f := field{index: i, name: jsonName(sf)}
+		for _, r := range strings.Split(tag, ",") {
+			name, arg, _ := strings.Cut(r, "=")
+			f.rules = append(f.rules, rule{name: name, arg: arg})
+		}
+		result = append(result, f)
+	}
+
+	fields.Store(t, result)
+	return result
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+// Struct checks v, a struct or a pointer to one, returning nil when every
+// field is valid.
+func Struct(v any) response.FieldErrors {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	fs := fieldsOf(rv.Type())
+
+	var errs response.FieldErrors
+	for _, f := range fs {
+		value := rv.Field(f.index)
+		for _, r := range f.rules {
+			if r.name != "required" && value.IsZero() {
+				break
+			}
+			if code, message := check(rv, fs, &errs, f.name, value, r); code != "" {
+				errs.Add(f.name, code, message)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// check applies r to the field name, returning the code and message of the
+// error when it fails. Earlier errors tell whether fields that r refers to
+// are valid.
+func check(rv reflect.Value, fs []field, errs *response.FieldErrors, name string, value reflect.Value, r rule) (response.Code, string) {
+	switch r.name {
+	case "required":
+		if value.IsZero() {
+			return response.CodeRequired, name + " is required"
+		}
+
+	case "min", "max":
+		limit, err := strconv.Atoi(r.arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: invalid %s rule of %s", r.name, name))
+		}
+		return checkBound(name, value, r.name == "min", limit)
+
+	case "uuid":
+		if _, err := uuid.Parse(value.String()); err != nil {
+			return response.CodeInvalid, "invalid " + name + ", must be UUID"
+		}
+
+	case "month":
+		if _, err := time.Parse(MonthLayout, value.String()); err != nil {
+			return response.CodeInvalid, "invalid " + name + ", expected MM-YYYY"
+		}
+
+	case "notbefore":
+		other := fieldByName(rv, fs, r.arg)
+		// the order only matters once both months are valid
+		if other.IsZero() || errs.Has(r.arg) {
+			return "", ""
+		}
+		month, err := time.Parse(MonthLayout, value.String())
+		if err != nil {
+			return response.CodeInvalid, "invalid " + name + ", expected MM-YYYY"
+		}
+		otherMonth, err := time.Parse(MonthLayout, other.String())
+		if err == nil && month.Before(otherMonth) {
+			return response.CodeOutOfRange, name + " must not be before " + r.arg
+		}
+
+	case "oneof":
+		allowed := strings.Fields(r.arg)
+		for _, a := range allowed {
+			if value.String() == a {
+				return "", ""
+			}
+		}
+		return response.CodeInvalid, name + " must be one of " + strings.Join(allowed, ", ")
+
+	case "email":
+		addr, err := mail.ParseAddress(value.String())
+		if err != nil || addr.Address != value.String() {
+			return response.CodeInvalid, "invalid " + name + ", must be an email address"
+		}
+
+	case "timezone":
+		if _, err := time.LoadLocation(value.String()); err != nil || value.String() == "Local" {
+			return response.CodeInvalid, "invalid " + name + ", must be an IANA time zone such as Europe/Moscow"
+		}
+
+	case "currency":
+		if !isCurrency(value.String()) {
+			return response.CodeInvalid, "invalid " + name + ", must be a three letter ISO 4217 code such as RUB"
+		}
+
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q of %s", r.name, name))
+	}
+	return "", ""
+}
+
+func checkBound(name string, value reflect.Value, isMin bool, limit int) (response.Code, string) {
+	var n int64
+	var code response.Code
+	var message string
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = value.Int()
+		code = response.CodeOutOfRange
+		message = fmt.Sprintf("%s must be at most %d", name, limit)
+		if isMin {
+			message = fmt.Sprintf("%s must be at least %d", name, limit)
+		}
+	case reflect.String:
+		n = int64(utf8.RuneCountInString(value.String()))
+		code = response.CodeTooLong
+		message = fmt.Sprintf("%s must be at most %d characters", name, limit)
+		if isMin {
+			code = response.CodeTooShort
+			message = fmt.Sprintf("%s must be at least %d characters", name, limit)
+		}
+	case reflect.Slice:
+		n = int64(value.Len())
+		code = response.CodeTooLong
+		message = fmt.Sprintf("%s must have at most %d items", name, limit)
+		if isMin {
+			code = response.CodeTooShort
+			message = fmt.Sprintf("%s must have at least %d items", name, limit)
+		}
+	default:
+		panic(fmt.Sprintf("validate: min and max don't apply to %s", name))
+	}
+
+	if (isMin && n < int64(limit)) || (!isMin && n > int64(limit)) {
+		return code, message
+	}
+	return "", ""
+}
+
+func isCurrency(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldByName(rv reflect.Value, fs []field, name string) reflect.Value {
+	for _, f := range fs {
+		if f.name == name {
+			return rv.Field(f.index)
+		}
+	}
+	panic("validate: unknown field " + name)
+}
@@ -0,0 +1,287 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seeques/subman/internal/response"
+)
+
+// codeOf returns the code of the error reported for field, or "" when the
+// field is valid.
+func codeOf(errs response.FieldErrors, field string) response.Code {
+	for _, fe := range errs {
+		if fe.Field == field {
+			return fe.Code
+		}
+	}
+	return ""
+}
+
+type ruleCase struct {
+	value string
+	want  response.Code
+}
+
+// testStringRule checks value against a field tagged "required,<rule>" and
+// an optional field tagged with just the rule, which must accept "".
+func testStringRule[T any](t *testing.T, build func(required, optional string) T, tests []ruleCase) {
+	t.Helper()
+
+	tests = append(tests, ruleCase{value: "", want: response.CodeRequired})
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			dto := build(tt.value, "")
+			errs := Struct(&dto)
+			if got := codeOf(errs, "required"); got != tt.want {
+				t.Errorf("code of %q = %q, want %q (errors: %v)", tt.value, got, tt.want, errs)
+			}
+			if got := codeOf(errs, "optional"); got != "" {
+				t.Errorf("empty optional field got code %q", got)
+			}
+
+			if tt.value == "" {
+				return
+			}
+			// the rule applies the same to an optional field once it is given
+			dto = build("x", tt.value)
+			if got := codeOf(Struct(&dto), "optional"); got != tt.want {
+				t.Errorf("code of optional %q = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequired(t *testing.T) {
+	type dto struct {
+		Name    string   `json:"name" validate:"required"`
+		Count   int      `json:"count" validate:"required"`
+		Tags    []string `json:"tags" validate:"required"`
+		Comment string   `json:"comment"`
+	}
+
+	errs := Struct(&dto{})
+	for _, field := range []string{"name", "count", "tags"} {
+		if got := codeOf(errs, field); got != response.CodeRequired {
+			t.Errorf("code of empty %s = %q, want %q", field, got, response.CodeRequired)
+		}
+	}
+	if got := codeOf(errs, "comment"); got != "" {
+		t.Errorf("field without rules got code %q", got)
+	}
+	if len(errs) != 3 {
+		t.Errorf("got %d errors, want 3: %v", len(errs), errs)
+	}
+
+	if errs := Struct(&dto{Name: "a", Count: 1, Tags: []string{"b"}}); errs != nil {
+		t.Errorf("valid dto got errors: %v", errs)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	type dto struct {
+		Price int      `json:"price" validate:"required,min=1,max=100"`
+		Name  string   `json:"name" validate:"min=2,max=4"`
+		Tags  []string `json:"tags" validate:"max=2"`
+	}
+
+	tests := []struct {
+		name  string
+		dto   dto
+		field string
+		want  response.Code
+	}{
+		{"price missing", dto{}, "price", response.CodeRequired},
+		{"price below min", dto{Price: -1}, "price", response.CodeOutOfRange},
+		{"price at min", dto{Price: 1}, "price", ""},
+		{"price at max", dto{Price: 100}, "price", ""},
+		{"price above max", dto{Price: 101}, "price", response.CodeOutOfRange},
+		{"name empty is optional", dto{Price: 1}, "name", ""},
+		{"name too short", dto{Price: 1, Name: "a"}, "name", response.CodeTooShort},
+		{"name at max", dto{Price: 1, Name: "abcd"}, "name", ""},
+		{"name too long", dto{Price: 1, Name: "abcde"}, "name", response.CodeTooLong},
+		// lengths count characters, not bytes
+		{"cyrillic name", dto{Price: 1, Name: "Плюс"}, "name", ""},
+		{"tags at max", dto{Price: 1, Tags: []string{"a", "b"}}, "tags", ""},
+		{"too many tags", dto{Price: 1, Tags: []string{"a", "b", "c"}}, "tags", response.CodeTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := codeOf(Struct(&tt.dto), tt.field); got != tt.want {
+				t.Errorf("code of %s = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUUID(t *testing.T) {
+	type dto struct {
+		Required string `json:"required" validate:"required,uuid"`
+		Optional string `json:"optional" validate:"uuid"`
+	}
+
+	testStringRule(t, func(required, optional string) dto { return dto{required, optional} }, []ruleCase{
+		{"60601fee-2bf1-4721-ae6f-7636e79a0cba", ""},
+		{"60601fee2bf14721ae6f7636e79a0cba", ""},
+		{"60601fee-2bf1-4721-ae6f", response.CodeInvalid},
+		{"not-a-uuid", response.CodeInvalid},
+	})
+}
+
+func TestMonth(t *testing.T) {
+	type dto struct {
+		Required string `json:"required" validate:"required,month"`
+		Optional string `json:"optional" validate:"month"`
+	}
+
+	testStringRule(t, func(required, optional string) dto { return dto{required, optional} }, []ruleCase{
+		{"01-2025", ""},
+		{"12-2025", ""},
+		{"13-2025", response.CodeInvalid},
+		{"1-2025", response.CodeInvalid},
+		{"2025-01", response.CodeInvalid},
+		{"01/2025", response.CodeInvalid},
+	})
+}
+
+func TestNotBefore(t *testing.T) {
+	type dto struct {
+		Start string `json:"start" validate:"required,month"`
+		End   string `json:"end" validate:"month,notbefore=start"`
+	}
+
+	tests := []struct {
+		name      string
+		dto       dto
+		wantStart response.Code
+		wantEnd   response.Code
+	}{
+		{"after", dto{"01-2025", "06-2025"}, "", ""},
+		{"same month", dto{"01-2025", "01-2025"}, "", ""},
+		{"before", dto{"06-2025", "01-2025"}, "", response.CodeOutOfRange},
+		{"before in an earlier year", dto{"01-2025", "12-2024"}, "", response.CodeOutOfRange},
+		{"end is optional", dto{"06-2025", ""}, "", ""},
+		{"invalid end", dto{"06-2025", "2025"}, "", response.CodeInvalid},
+		// the order is only checked once start is valid
+		{"start missing", dto{"", "01-2025"}, response.CodeRequired, ""},
+		{"start invalid", dto{"june", "01-2025"}, response.CodeInvalid, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Struct(&tt.dto)
+			if got := codeOf(errs, "start"); got != tt.wantStart {
+				t.Errorf("code of start = %q, want %q", got, tt.wantStart)
+			}
+			if got := codeOf(errs, "end"); got != tt.wantEnd {
+				t.Errorf("code of end = %q, want %q", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	type dto struct {
+		Required string `json:"required" validate:"required,oneof=monthly yearly"`
+		Optional string `json:"optional" validate:"oneof=monthly yearly"`
+	}
+
+	testStringRule(t, func(required, optional string) dto { return dto{required, optional} }, []ruleCase{
+		{"monthly", ""},
+		{"yearly", ""},
+		{"weekly", response.CodeInvalid},
+		{"Monthly", response.CodeInvalid},
+		{"monthly yearly", response.CodeInvalid},
+	})
+}
+
+func TestEmail(t *testing.T) {
+	type dto struct {
+		Required string `json:"required" validate:"required,email"`
+		Optional string `json:"optional" validate:"email"`
+	}
+
+	testStringRule(t, func(required, optional string) dto { return dto{required, optional} }, []ruleCase{
+		{"anna@example.com", ""},
+		{"anna.petrova+subs@mail.example.ru", ""},
+		{"anna", response.CodeInvalid},
+		{"anna@", response.CodeInvalid},
+		// display names are not part of an address
+		{"Anna <anna@example.com>", response.CodeInvalid},
+		{" anna@example.com", response.CodeInvalid},
+	})
+}
+
+func TestTimezone(t *testing.T) {
+	type dto struct {
+		Required string `json:"required" validate:"required,timezone"`
+		Optional string `json:"optional" validate:"timezone"`
+	}
+
+	testStringRule(t, func(required, optional string) dto { return dto{required, optional} }, []ruleCase{
+		{"Europe/Moscow", ""},
+		{"America/New_York", ""},
+		{"UTC", ""},
+		{"Local", response.CodeInvalid},
+		{"Europe/Atlantis", response.CodeInvalid},
+		{"+03:00", response.CodeInvalid},
+	})
+}
+
+func TestCurrency(t *testing.T) {
+	type dto struct {
+		Required string `json:"required" validate:"required,currency"`
+		Optional string `json:"optional" validate:"currency"`
+	}
+
+	testStringRule(t, func(required, optional string) dto { return dto{required, optional} }, []ruleCase{
+		{"RUB", ""},
+		{"USD", ""},
+		{"rub", response.CodeInvalid},
+		{"RU", response.CodeInvalid},
+		{"RUBL", response.CodeInvalid},
+		{"R1B", response.CodeInvalid},
+	})
+}
+
+func TestRulesStopAtFirstFailure(t *testing.T) {
+	type dto struct {
+		ID string `json:"id" validate:"required,max=5,uuid"`
+	}
+
+	errs := Struct(&dto{ID: "60601fee-2bf1-4721-ae6f-7636e79a0cba"})
+	if len(errs) != 1 || errs[0].Code != response.CodeTooLong {
+		t.Errorf("errors = %v, want only %q", errs, response.CodeTooLong)
+	}
+}
+
+func TestUnknownRulePanics(t *testing.T) {
+	type dto struct {
+		Name string `json:"name" validate:"required,nonsense"`
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("Struct didn't panic on an unknown rule")
+		}
+		if msg, _ := r.(string); !strings.Contains(msg, `unknown rule "nonsense"`) {
+			t.Errorf("panic = %v, want it to name the rule", r)
+		}
+	}()
+	Struct(&dto{Name: "a"})
+}
+
+func TestInvalidBoundPanics(t *testing.T) {
+	type dto struct {
+		Name string `json:"name" validate:"max=many"`
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Struct didn't panic on a max rule without a number")
+		}
+	}()
+	Struct(&dto{Name: "a"})
+}
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/events"
+	"github.com/seeques/subman/internal/storage"
+)
+
+const (
+	// Deliveries are moved to the dead-letter list after this many failures
+	maxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+
+	batchSize   = 20
+	sendTimeout = 10 * time.Second
+	// How long claimed deliveries are hidden from other workers. A batch is
+	// sent one delivery after another, so the lease has to outlast every
+	// send of the batch timing out.
+	leaseDuration = batchSize*sendTimeout + time.Minute
+)
+
+// deliveryStore is the part of the storage the dispatcher works with.
+type deliveryStore interface {
+	EnqueueWebhookEvent(ctx context.Context, eventID uuid.UUID, eventType string, payload []byte) (int64, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]storage.ClaimedDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id int64, leasedUntil time.Time) error
+	MarkWebhookFailed(ctx context.Context, id int64, leasedUntil time.Time, lastError string, retryIn time.Duration, dead bool) error
+}
+
+// Dispatcher queues events for registered endpoints and delivers them in
+// the background, retrying failures with exponential backoff.
+type Dispatcher struct {
+	storage deliveryStore
+	client  *http.Client
+}
+
+func NewDispatcher(storage *storage.PostgresStorage) *Dispatcher {
+	return &Dispatcher{
+		storage: storage,
+		client:  &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Emit queues an event for every endpoint subscribed to its type. Delivery
+// happens asynchronously in Run.
+func (d *Dispatcher) Emit(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal webhook event: %w", err)
+	}
+
+	queued, err := d.storage.EnqueueWebhookEvent(ctx, event.ID, event.Type, payload)
+	if err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		slog.Debug("webhook event queued", "event_id", event.ID, "event_type", event.Type, "deliveries", queued)
+	}
+	return nil
+}
+
+// Run delivers due events every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to deliver webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends all deliveries whose next attempt is due.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	for {
+		claimed, err := d.storage.ClaimDueWebhookDeliveries(ctx, batchSize, leaseDuration)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range claimed {
+			d.attempt(ctx, delivery)
+		}
+
+		if len(claimed) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery storage.ClaimedDelivery) {
+	err := d.send(ctx, delivery)
+	if err == nil {
+		if err := d.storage.MarkWebhookDelivered(ctx, delivery.ID, delivery.NextAttemptAt); err != nil {
+			d.logMarkError("failed to mark webhook delivered", err, delivery)
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	dead := attempts >= maxAttempts
+	retryIn := backoff(attempts)
+
+	if dead {
+		slog.Warn("webhook delivery moved to dead letters",
+			"error", err,
+			"delivery_id", delivery.ID,
+			"endpoint_id", delivery.EndpointID,
+			"attempts", attempts,
+		)
+	} else {
+		slog.Info("webhook delivery failed, will retry",
+			"error", err,
+			"delivery_id", delivery.ID,
+			"endpoint_id", delivery.EndpointID,
+			"attempts", attempts,
+			"retry_in", retryIn,
+		)
+	}
+
+	if err := d.storage.MarkWebhookFailed(ctx, delivery.ID, delivery.NextAttemptAt, err.Error(), retryIn, dead); err != nil {
+		d.logMarkError("failed to mark webhook failed", err, delivery)
+	}
+}
+
+// logMarkError logs an attempt that couldn't be recorded. An expired lease
+// means another worker claimed the delivery and records its own attempt.
+func (d *Dispatcher) logMarkError(msg string, err error, delivery storage.ClaimedDelivery) {
+	if errors.Is(err, storage.ErrLeaseExpired) {
+		slog.Warn("webhook delivery lease expired before the attempt was recorded", "delivery_id", delivery.ID)
+		return
+	}
+	slog.Error(msg, "error", err, "delivery_id", delivery.ID)
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery storage.ClaimedDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff doubles the delay after every failed attempt, with up to 20%
+// jitter so retries of many deliveries don't line up.
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff << (attempts - 1)
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/storage"
+)
+
+// memoryDeliveries keeps deliveries in memory and treats every pending one
+// as due, so each DeliverDue call makes one attempt.
+type memoryDeliveries struct {
+	deliveries []*storage.ClaimedDelivery
+}
+
+func (m *memoryDeliveries) EnqueueWebhookEvent(ctx context.Context, eventID uuid.UUID, eventType string, payload []byte) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryDeliveries) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]storage.ClaimedDelivery, error) {
+	var claimed []storage.ClaimedDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status != models.DeliveryStatusPending || len(claimed) == limit {
+			continue
+		}
+		delivery.NextAttemptAt = time.Now().Add(lease)
+		claimed = append(claimed, *delivery)
+	}
+	return claimed, nil
+}
+
+func (m *memoryDeliveries) claimed(id int64, leasedUntil time.Time) (*storage.ClaimedDelivery, error) {
+	for _, delivery := range m.deliveries {
+		if delivery.ID == id && delivery.Status == models.DeliveryStatusPending && delivery.NextAttemptAt.Equal(leasedUntil) {
+			return delivery, nil
+		}
+	}
+	return nil, storage.ErrLeaseExpired
+}
+
+func (m *memoryDeliveries) MarkWebhookDelivered(ctx context.Context, id int64, leasedUntil time.Time) error {
+	delivery, err := m.claimed(id, leasedUntil)
+	if err != nil {
+		return err
+	}
+	delivery.Status = models.DeliveryStatusDelivered
+	delivery.Attempts++
+	return nil
+}
+
+func (m *memoryDeliveries) MarkWebhookFailed(ctx context.Context, id int64, leasedUntil time.Time, lastError string, retryIn time.Duration, dead bool) error {
+	delivery, err := m.claimed(id, leasedUntil)
+	if err != nil {
+		return err
+	}
+	if dead {
+		delivery.Status = models.DeliveryStatusDead
+	}
+	delivery.Attempts++
+	delivery.LastError = &lastError
+	delivery.NextAttemptAt = time.Now().Add(retryIn)
+	return nil
+}
+
+func newTestDelivery(url string) *storage.ClaimedDelivery {
+	return &storage.ClaimedDelivery{
+		WebhookDelivery: models.WebhookDelivery{
+			ID:        1,
+			EventID:   uuid.New(),
+			EventType: "subscription.created",
+			Payload:   []byte(`{}`),
+			Status:    models.DeliveryStatusPending,
+		},
+		URL:    url,
+		Secret: "secret",
+	}
+}
+
+func TestDeliverDueMovesToDeadLetters(t *testing.T) {
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	delivery := newTestDelivery(receiver.URL)
+	store := &memoryDeliveries{deliveries: []*storage.ClaimedDelivery{delivery}}
+	d := &Dispatcher{storage: store, client: receiver.Client()}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := d.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+
+		want := models.DeliveryStatusPending
+		if attempt == maxAttempts {
+			want = models.DeliveryStatusDead
+		}
+		if delivery.Status != want {
+			t.Fatalf("status after %d attempts = %s, want %s", attempt, delivery.Status, want)
+		}
+	}
+
+	if delivery.Attempts != maxAttempts {
+		t.Errorf("attempts = %d, want %d", delivery.Attempts, maxAttempts)
+	}
+	if delivery.LastError == nil || *delivery.LastError != "endpoint responded with status 500" {
+		t.Errorf("last error = %v, want the response status", delivery.LastError)
+	}
+
+	// dead letters are not sent again
+	if err := d.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if got := received.Load(); got != maxAttempts {
+		t.Errorf("receiver got %d requests, want %d", got, maxAttempts)
+	}
+}
+
+func TestDeliverDueMarksDelivered(t *testing.T) {
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt fails, the retry succeeds
+		if received.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	delivery := newTestDelivery(receiver.URL)
+	store := &memoryDeliveries{deliveries: []*storage.ClaimedDelivery{delivery}}
+	d := &Dispatcher{storage: store, client: receiver.Client()}
+
+	for range 3 {
+		if err := d.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+	}
+
+	if delivery.Status != models.DeliveryStatusDelivered {
+		t.Errorf("status = %s, want %s", delivery.Status, models.DeliveryStatusDelivered)
+	}
+	if delivery.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", delivery.Attempts)
+	}
+	if got := received.Load(); got != 2 {
+		t.Errorf("receiver got %d requests, want 2", got)
+	}
+}
+
+func TestDeliverDueKeepsAttemptOfNewerLease(t *testing.T) {
+	var store *memoryDeliveries
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// another instance claims the delivery while this attempt is in flight
+		time.Sleep(time.Millisecond)
+		store.ClaimDueWebhookDeliveries(r.Context(), batchSize, leaseDuration)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	delivery := newTestDelivery(receiver.URL)
+	store = &memoryDeliveries{deliveries: []*storage.ClaimedDelivery{delivery}}
+	d := &Dispatcher{storage: store, client: receiver.Client()}
+
+	if err := d.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+
+	if delivery.Attempts != 0 || delivery.LastError != nil {
+		t.Errorf("attempt was recorded over the newer lease: attempts = %d, last error = %v", delivery.Attempts, delivery.LastError)
+	}
+}
+
+func TestLeaseOutlastsBatch(t *testing.T) {
+	if leaseDuration < batchSize*sendTimeout {
+		t.Errorf("lease of %s is shorter than a batch of timed out sends, %s", leaseDuration, batchSize*sendTimeout)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{10, 256 * time.Minute},
+		// capped from here on, also where the shift overflows
+		{11, maxBackoff},
+		{20, maxBackoff},
+		{64, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		// up to 20% jitter on top of the delay
+		for range 100 {
+			got := backoff(tt.attempts)
+			if got < tt.want || got > tt.want+tt.want/5 {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempts, got, tt.want, tt.want+tt.want/5)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Subman-Event"
+	HeaderDelivery  = "X-Subman-Delivery"
+	HeaderTimestamp = "X-Subman-Timestamp"
+	HeaderSignature = "X-Subman-Signature"
+)
+
+// Sign returns the value of the signature header for a payload sent at
+// timestamp: "sha256=" followed by the hex HMAC-SHA256 of
+// "<unix timestamp>.<payload>" keyed with the endpoint secret.
+// Receivers should recompute it and reject stale timestamps.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is valid for the payload and timestamp.
+func Verify(secret string, timestamp time.Time, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature))
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/seeques/subman/internal/models"
+	"github.com/seeques/subman/internal/storage"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	payload := []byte(`{"type":"subscription.created"}`)
+	signature := Sign("secret", now, payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		payload   []byte
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", now, payload, signature, true},
+		{"wrong secret", "other", now, payload, signature, false},
+		{"other timestamp", "secret", now.Add(time.Second), payload, signature, false},
+		{"tampered payload", "secret", now, []byte(`{"type":"subscription.deleted"}`), signature, false},
+		{"missing prefix", "secret", now, payload, signature[len("sha256="):], false},
+		{"empty signature", "secret", now, payload, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.payload, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSignatureHeader checks that a receiver can verify a delivery from the
+// headers it is sent with.
+func TestSignatureHeader(t *testing.T) {
+	const secret = "endpoint-secret"
+	payload := []byte(`{"id":"c7a1","type":"subscription.updated"}`)
+
+	verified := make(chan bool, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+
+		unix, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("parse %s: %v", HeaderTimestamp, err)
+		}
+
+		if r.Header.Get(HeaderEvent) != "subscription.updated" {
+			t.Errorf("%s = %q, want subscription.updated", HeaderEvent, r.Header.Get(HeaderEvent))
+		}
+		if r.Header.Get(HeaderDelivery) != "7" {
+			t.Errorf("%s = %q, want 7", HeaderDelivery, r.Header.Get(HeaderDelivery))
+		}
+
+		ok := Verify(secret, time.Unix(unix, 0), body, r.Header.Get(HeaderSignature))
+		verified <- ok
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d := &Dispatcher{client: receiver.Client()}
+	delivery := storage.ClaimedDelivery{
+		WebhookDelivery: models.WebhookDelivery{ID: 7, EventType: "subscription.updated", Payload: payload},
+		URL:             receiver.URL,
+		Secret:          secret,
+	}
+
+	if err := d.send(context.Background(), delivery); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if !<-verified {
+		t.Error("receiver could not verify the signature")
+	}
+}
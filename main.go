@@ -1,17 +1,37 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"strings"
 	"log"
 	"log/slog"
 	"syscall"
 	"context"
-	"time"
 	"os/signal"
 	"net/http"
+	"slices"
 	"github.com/seeques/subman/internal/api"
+	"github.com/seeques/subman/internal/auth"
+	"github.com/seeques/subman/internal/budget"
+	"github.com/seeques/subman/internal/cli"
 	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/graphqlapi"
+	"github.com/seeques/subman/internal/grpcapi"
+	"github.com/seeques/subman/internal/health"
+	"github.com/seeques/subman/internal/logging"
+	"github.com/seeques/subman/internal/metrics"
+	"github.com/seeques/subman/internal/migrate"
+	"github.com/seeques/subman/internal/outbox"
+	"github.com/seeques/subman/internal/ratelimit"
 	"github.com/seeques/subman/internal/storage"
+	"github.com/seeques/subman/internal/stream"
+	"github.com/seeques/subman/internal/tenant"
+	"github.com/seeques/subman/internal/tracing"
+	"github.com/seeques/subman/internal/webhook"
+	"github.com/seeques/subman/migrations"
 )
 
 // @title Subscription Service API
@@ -21,12 +41,99 @@ import (
 // @host localhost:8080
 // @BasePath /api/v1
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API key sent as "Bearer <key>"
+
+const usage = `usage: subman [command] [flags]
+
+commands:
+  serve     run the API server, the default
+  migrate   apply or revert database migrations, see subman migrate -h
+
+Run subman <command> -h to list the flags.
+
+`
+
 func main() {
-	cfg := config.LoadConfig()
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "migrate":
+		runMigrate(args)
+	case "help":
+		fmt.Print(usage + cli.Usage)
+	default:
+		if slices.Contains(cli.Commands, command) {
+			runClient(command, args)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s%s", command, usage, cli.Usage)
+		os.Exit(2)
+	}
+}
+
+// runClient runs a client command against the API, stopping on interrupt.
+func runClient(command string, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cli.Run(ctx, command, args, os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the config of a command and sets up logging, exiting on
+// invalid settings.
+func loadConfig(name string, args []string) config.Config {
+	cfg, err := config.Load(name, args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatalf("logging setup failed: %v", err)
+	}
+	return cfg
+}
+
+func serve(args []string) {
+	cfg := loadConfig("subman serve", args)
 
 	slog.Info("starting server", "port", cfg.Port)
 
-	pool, err := storage.CreatePool()
+	schema, err := migrate.Load(migrations.FS)
+	if err != nil {
+		log.Fatalf("reading embedded migrations failed: %v", err)
+	}
+
+	if cfg.MigrateOnStart {
+		if err := migrateUp(context.Background(), cfg, schema); err != nil {
+			log.Fatalf("migrating on start failed: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter)
+	if err != nil {
+		log.Fatalf("tracing setup failed: %v", err)
+	}
+
+	pool, err := storage.CreatePool(context.Background(), cfg)
 	if err != nil {
 		log.Fatalf("pgxpool creation failed: %v", err)
 	}
@@ -34,7 +141,65 @@ func main() {
 
 	storage := storage.NewPostgresStorage(pool)
 
-	s := api.NewServer(storage, cfg)
+	if bypass, err := storage.BypassesRowSecurity(context.Background()); err != nil {
+		slog.Warn("failed to check row-level security", "error", err)
+	} else if bypass {
+		slog.Warn("database role bypasses row-level security, tenant isolation relies on query filters only")
+	}
+
+	// background jobs stop once shutdown begins and work across all tenants
+	jobsCtx, stopJobs := context.WithCancel(tenant.WithAllTenants(context.Background()))
+	defer stopJobs()
+
+	broker := stream.NewBroker(storage)
+	go broker.Run(jobsCtx)
+
+	var verifier *auth.JWTVerifier
+	if cfg.JWKS != "" {
+		keys, err := auth.NewKeySet(context.Background(), cfg.JWKS, cfg.JWKSRefreshInterval)
+		if err != nil {
+			log.Fatalf("jwks loading failed: %v", err)
+		}
+		verifier = auth.NewJWTVerifier(keys, cfg.JWTIssuer, cfg.JWTAudience)
+	}
+
+	authenticator := auth.NewAuthenticator(storage, cfg.BootstrapAPIKey, verifier)
+
+	limits, err := ratelimit.ParseLimits(cfg.RateLimits)
+	if err != nil {
+		log.Fatalf("invalid rate limits: %v", err)
+	}
+	limitStore, err := ratelimit.NewStore(cfg.RateLimitStore, storage)
+	if err != nil {
+		log.Fatalf("rate limiter setup failed: %v", err)
+	}
+	limiter := ratelimit.New(limitStore, limits)
+	go limiter.Run(jobsCtx)
+
+	m := metrics.New(pool, storage)
+	go m.Run(jobsCtx, cfg.MetricsRefreshInterval)
+
+	checker := health.NewChecker(storage, schema[len(schema)-1].Version)
+
+	graphqlHandler, err := graphqlapi.NewHandler(storage, cfg)
+	if err != nil {
+		log.Fatalf("graphql schema setup failed: %v", err)
+	}
+
+	s := api.NewServer(storage, broker, authenticator, limiter, m, checker, graphqlHandler, cfg)
+
+	go budget.NewEvaluator(storage).Run(jobsCtx, cfg.BudgetEvaluationInterval)
+
+	dispatcher := webhook.NewDispatcher(storage)
+	go dispatcher.Run(jobsCtx, cfg.WebhookPollInterval)
+
+	sinks, closeSinks, err := outbox.NewSinks(cfg.OutboxSinks, cfg.OutboxFile, dispatcher)
+	if err != nil {
+		log.Fatalf("outbox sinks setup failed: %v", err)
+	}
+	defer closeSinks()
+
+	go outbox.NewRelay(storage, sinks...).Run(jobsCtx, cfg.OutboxPollInterval)
 
 	go func() {
 		if err := s.Run(); err != nil && err != http.ErrServerClosed {
@@ -42,18 +207,47 @@ func main() {
 		}
 	}()
 
+	var grpcServer *grpcapi.Server
+	if cfg.GRPCPort != 0 {
+		grpcServer = grpcapi.NewServer(storage, authenticator, limiter, cfg)
+		go func() {
+			if err := grpcServer.Run(); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	slog.Info("received shutdown signal")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	// also closes open event streams, which would otherwise hold up the shutdown
+	stopJobs()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	// gRPC clients see the drain through health checks during the HTTP drain delay
+	if grpcServer != nil {
+		grpcServer.Drain()
+	}
+
 	if err := s.Shutdown(ctx); err != nil {
 		slog.Error("server forced to shutdown", "error", err)
 	}
 
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			slog.Error("gRPC server forced to shutdown", "error", err)
+		}
+	}
+
+	// flushes the spans of the last requests
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to shut down tracing", "error", err)
+	}
+
 	slog.Info("server stopped")
 }
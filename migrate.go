@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/seeques/subman/internal/config"
+	"github.com/seeques/subman/internal/migrate"
+	"github.com/seeques/subman/migrations"
+)
+
+const migrateUsage = `usage: subman migrate <command> [flags]
+
+commands:
+  up              apply all pending migrations
+  down [N]        revert the last N migrations, 1 by default
+  status          show the schema version and which migrations are applied
+  goto VERSION    migrate up or down to VERSION, 0 reverts everything
+  force VERSION   record VERSION as applied without running anything, to
+                  recover from a migration that failed halfway
+
+Run subman migrate <command> -h to list the flags.
+`
+
+func runMigrate(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	command, args := args[0], args[1:]
+
+	// positional arguments come before the flags
+	var arg string
+	switch command {
+	case "goto", "force":
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			log.Fatalf("migrate %s needs a version", command)
+		}
+		arg, args = args[0], args[1:]
+	case "down":
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			arg, args = args[0], args[1:]
+		}
+	case "up", "status":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", command, migrateUsage)
+		os.Exit(2)
+	}
+
+	cfg := loadConfig("subman migrate "+command, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	schema, err := migrate.Load(migrations.FS)
+	if err != nil {
+		log.Fatalf("reading embedded migrations failed: %v", err)
+	}
+
+	m, err := migrate.New(ctx, cfg.DatabaseURL, schema)
+	if err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	defer m.Close(context.Background())
+
+	switch command {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		steps := 1
+		if arg != "" {
+			steps, err = strconv.Atoi(arg)
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of migrations %q", arg)
+			}
+		}
+		err = m.Down(ctx, steps)
+	case "goto":
+		err = m.Goto(ctx, parseVersion(arg))
+	case "force":
+		err = m.Force(ctx, parseVersion(arg))
+	case "status":
+		err = printStatus(ctx, m)
+	}
+	if err != nil {
+		log.Fatalf("migrate %s: %v", command, err)
+	}
+}
+
+// migrateUp applies pending migrations before the server starts. Instances
+// starting together wait for each other on the migration lock.
+func migrateUp(ctx context.Context, cfg config.Config, schema []migrate.Migration) error {
+	m, err := migrate.New(ctx, cfg.DatabaseURL, schema)
+	if err != nil {
+		return err
+	}
+	defer m.Close(ctx)
+
+	return m.Up(ctx)
+}
+
+func parseVersion(s string) uint {
+	version, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		log.Fatalf("invalid version %q", s)
+	}
+	return uint(version)
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	current, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d (latest %d)\n", current, m.Latest())
+	if dirty {
+		fmt.Println("dirty: the last migration failed halfway")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, migration := range m.Migrations() {
+		status := "pending"
+		if migration.Version <= current {
+			status = "applied"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, status)
+	}
+	return w.Flush()
+}
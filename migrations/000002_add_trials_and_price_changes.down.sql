@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS subscription_price_change;
+
+ALTER TABLE subscription DROP COLUMN IF EXISTS trial_end_date;
@@ -0,0 +1,10 @@
+ALTER TABLE subscription ADD COLUMN trial_end_date DATE;
+
+CREATE TABLE subscription_price_change (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscription(id) ON DELETE CASCADE,
+    price INTEGER NOT NULL CHECK (price > 0),
+    effective_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (subscription_id, effective_date)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS budget_breach;
+DROP TABLE IF EXISTS budget;
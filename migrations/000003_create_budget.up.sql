@@ -0,0 +1,23 @@
+CREATE TABLE budget (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    period VARCHAR(16) NOT NULL CHECK (period IN ('monthly', 'yearly')),
+    limit_amount INTEGER NOT NULL CHECK (limit_amount > 0),
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    thresholds INTEGER[] NOT NULL DEFAULT '{80,100}',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_budget_user_id ON budget(user_id);
+
+CREATE TABLE budget_breach (
+    id SERIAL PRIMARY KEY,
+    budget_id INTEGER NOT NULL REFERENCES budget(id) ON DELETE CASCADE,
+    threshold INTEGER NOT NULL,
+    period_start DATE NOT NULL,
+    spent INTEGER NOT NULL,
+    limit_amount INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (budget_id, threshold, period_start)
+);
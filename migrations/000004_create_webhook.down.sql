@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS subscription_ending_notice;
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook_endpoint;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_webhook_delivery_event;
+DROP TABLE IF EXISTS outbox;
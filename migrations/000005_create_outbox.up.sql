@@ -0,0 +1,17 @@
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    processed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_unprocessed ON outbox(id) WHERE processed_at IS NULL;
+
+-- the relay delivers at least once, so the same event may reach the webhook sink twice
+CREATE UNIQUE INDEX idx_webhook_delivery_event ON webhook_delivery(endpoint_id, event_id);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS subscription_change_notify ON subscription;
+DROP FUNCTION IF EXISTS record_subscription_change();
+DROP TABLE IF EXISTS subscription_change;
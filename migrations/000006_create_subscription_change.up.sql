@@ -0,0 +1,35 @@
+CREATE TABLE subscription_change (
+    id BIGSERIAL PRIMARY KEY,
+    operation VARCHAR(16) NOT NULL,
+    subscription_id INTEGER NOT NULL,
+    user_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_subscription_change_user_id ON subscription_change(user_id, id);
+
+-- records every change to a subscription row and notifies listeners with the change id
+CREATE FUNCTION record_subscription_change() RETURNS trigger AS $$
+DECLARE
+    change_id BIGINT;
+    changed subscription;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+
+    INSERT INTO subscription_change (operation, subscription_id, user_id, payload)
+    VALUES (TG_OP, changed.id, changed.user_id, to_jsonb(changed))
+    RETURNING id INTO change_id;
+
+    PERFORM pg_notify('subscription_changes', change_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscription_change_notify
+AFTER INSERT OR UPDATE OR DELETE ON subscription
+FOR EACH ROW EXECUTE FUNCTION record_subscription_change();
@@ -0,0 +1,39 @@
+CREATE OR REPLACE FUNCTION record_subscription_change() RETURNS trigger AS $$
+DECLARE
+    change_id BIGINT;
+    changed subscription;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+
+    INSERT INTO subscription_change (operation, subscription_id, user_id, payload)
+    VALUES (TG_OP, changed.id, changed.user_id, to_jsonb(changed))
+    RETURNING id INTO change_id;
+
+    PERFORM pg_notify('subscription_changes', change_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'subscription', 'subscription_price_change', 'budget', 'budget_breach', 'webhook_endpoint',
+        'subscription_change', 'subscription_ending_notice', 'outbox', 'webhook_delivery', 'api_key'
+    ] LOOP
+        EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %I', t);
+        EXECUTE format('ALTER TABLE %I NO FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I DISABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I DROP COLUMN IF EXISTS tenant_id', t);
+    END LOOP;
+END
+$$;
+
+DROP FUNCTION IF EXISTS tenant_visible(UUID);
+DROP FUNCTION IF EXISTS current_tenant_id();
+DROP TABLE IF EXISTS tenant;
@@ -0,0 +1,69 @@
+CREATE TABLE tenant (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- owns all data created before tenants existed
+INSERT INTO tenant (id, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default');
+
+-- the tenant a connection is scoped to, set by the application on every checkout
+CREATE FUNCTION current_tenant_id() RETURNS UUID AS $$
+    SELECT NULLIF(current_setting('app.tenant_id', true), '')::UUID
+$$ LANGUAGE sql STABLE;
+
+-- background jobs work across tenants by setting app.all_tenants instead
+CREATE FUNCTION tenant_visible(row_tenant_id UUID) RETURNS BOOLEAN AS $$
+    SELECT row_tenant_id = current_tenant_id() OR current_setting('app.all_tenants', true) = 'on'
+$$ LANGUAGE sql STABLE;
+
+-- Tenant owned tables. Records derived from other data are removed with
+-- their tenant, everything else has to be deleted before the tenant is.
+DO $$
+DECLARE
+    t TEXT;
+    on_delete TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'subscription', 'subscription_price_change', 'budget', 'budget_breach', 'webhook_endpoint',
+        'subscription_change', 'subscription_ending_notice', 'outbox', 'webhook_delivery', 'api_key'
+    ] LOOP
+        on_delete := 'NO ACTION';
+        IF t IN ('subscription_change', 'subscription_ending_notice', 'outbox', 'webhook_delivery', 'api_key') THEN
+            on_delete := 'CASCADE';
+        END IF;
+
+        EXECUTE format('ALTER TABLE %I ADD COLUMN tenant_id UUID NOT NULL DEFAULT %L REFERENCES tenant(id) ON DELETE %s',
+            t, '00000000-0000-0000-0000-000000000001', on_delete);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id SET DEFAULT current_tenant_id()', t);
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_visible(tenant_id))', t);
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        -- the application usually connects as the table owner, which policies skip otherwise
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+    END LOOP;
+END
+$$;
+
+CREATE INDEX idx_subscription_tenant_id ON subscription(tenant_id, user_id);
+CREATE INDEX idx_budget_tenant_id ON budget(tenant_id, user_id);
+
+-- changes keep the tenant of the subscription they belong to
+CREATE OR REPLACE FUNCTION record_subscription_change() RETURNS trigger AS $$
+DECLARE
+    change_id BIGINT;
+    changed subscription;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+
+    INSERT INTO subscription_change (operation, subscription_id, user_id, payload, tenant_id)
+    VALUES (TG_OP, changed.id, changed.user_id, to_jsonb(changed), changed.tenant_id)
+    RETURNING id INTO change_id;
+
+    PERFORM pg_notify('subscription_changes', change_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
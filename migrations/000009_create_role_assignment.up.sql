@@ -0,0 +1,13 @@
+CREATE TABLE role_assignment (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id) ON DELETE CASCADE,
+    -- principal id as authenticated, e.g. apikey:12 or jwt:<sub>
+    principal VARCHAR(255) NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (tenant_id, principal, role)
+);
+
+CREATE POLICY tenant_isolation ON role_assignment USING (tenant_visible(tenant_id));
+ALTER TABLE role_assignment ENABLE ROW LEVEL SECURITY;
+ALTER TABLE role_assignment FORCE ROW LEVEL SECURITY;
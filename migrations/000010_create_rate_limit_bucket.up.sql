@@ -0,0 +1,11 @@
+-- token buckets shared by all instances when RATE_LIMIT_STORE=postgres;
+-- losing them on a crash only resets the limits, so skip the WAL
+CREATE UNLOGGED TABLE rate_limit_bucket (
+    key VARCHAR(512) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    -- whether the last request taking a token from the bucket got one
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_rate_limit_bucket_updated_at ON rate_limit_bucket(updated_at);
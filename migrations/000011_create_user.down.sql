@@ -0,0 +1,2 @@
+ALTER TABLE subscription DROP CONSTRAINT IF EXISTS subscription_user_fkey;
+DROP TABLE IF EXISTS app_user;
@@ -0,0 +1,30 @@
+-- "user" is a reserved word
+CREATE TABLE app_user (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id),
+    display_name VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    default_currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    -- IANA time zone, e.g. Europe/Moscow
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    -- user ids were chosen by clients before, so the same id may exist in several tenants
+    PRIMARY KEY (tenant_id, id)
+);
+
+CREATE INDEX idx_app_user_id ON app_user(id);
+CREATE UNIQUE INDEX idx_app_user_email ON app_user(tenant_id, lower(email));
+
+CREATE POLICY tenant_isolation ON app_user USING (tenant_visible(tenant_id));
+ALTER TABLE app_user ENABLE ROW LEVEL SECURITY;
+ALTER TABLE app_user FORCE ROW LEVEL SECURITY;
+
+-- every user id of a subscription gets a user, named after its id
+SELECT set_config('app.all_tenants', 'on', true);
+
+INSERT INTO app_user (tenant_id, id, display_name)
+SELECT DISTINCT tenant_id, user_id, user_id::text FROM subscription;
+
+ALTER TABLE subscription ADD CONSTRAINT subscription_user_fkey
+    FOREIGN KEY (tenant_id, user_id) REFERENCES app_user(tenant_id, id);
@@ -0,0 +1,49 @@
+-- the form service names are looked up by, so "Yandex  Plus" and "yandex plus" match
+CREATE FUNCTION service_key(name TEXT) RETURNS TEXT AS $$
+    SELECT lower(regexp_replace(btrim(name), '\s+', ' ', 'g'))
+$$ LANGUAGE sql IMMUTABLE;
+
+-- the catalog is reference data, so it is removed with its tenant
+CREATE TABLE service (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    category VARCHAR(64) NOT NULL DEFAULT '',
+    vendor VARCHAR(255) NOT NULL DEFAULT '',
+    website VARCHAR(255) NOT NULL DEFAULT '',
+    -- [{"name": "Family", "price": 699}], prices are monthly like subscription prices
+    plans JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_service_tenant_id ON service(tenant_id, category);
+
+-- the keys of the name and aliases of every service, unique within a tenant
+-- so a name never resolves to two services
+CREATE TABLE service_lookup (
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id) ON DELETE CASCADE,
+    name_key TEXT NOT NULL,
+    service_id INTEGER NOT NULL REFERENCES service(id) ON DELETE CASCADE,
+    PRIMARY KEY (tenant_id, name_key)
+);
+
+CREATE INDEX idx_service_lookup_service_id ON service_lookup(service_id);
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['service', 'service_lookup'] LOOP
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_visible(tenant_id))', t);
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+    END LOOP;
+END
+$$;
+
+-- subscriptions keep their service name when the catalog entry is deleted
+ALTER TABLE subscription ADD COLUMN service_id INTEGER REFERENCES service(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_subscription_service_id ON subscription(service_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS service_merge_subscription;
+DROP TABLE IF EXISTS service_merge;
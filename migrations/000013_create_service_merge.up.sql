@@ -0,0 +1,35 @@
+-- merges of service name spellings, kept so merged names can be traced back
+CREATE TABLE service_merge (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id) ON DELETE CASCADE,
+    target VARCHAR(255) NOT NULL,
+    sources TEXT[] NOT NULL,
+    service_id INTEGER REFERENCES service(id) ON DELETE SET NULL,
+    subscriptions_count INTEGER NOT NULL,
+    -- principal that applied the merge, e.g. apikey:12
+    merged_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_service_merge_tenant_id ON service_merge(tenant_id, id);
+
+-- the name every merged subscription had before
+CREATE TABLE service_merge_subscription (
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id) ON DELETE CASCADE,
+    merge_id INTEGER NOT NULL REFERENCES service_merge(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL,
+    previous_name VARCHAR(255) NOT NULL,
+    PRIMARY KEY (merge_id, subscription_id)
+);
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['service_merge', 'service_merge_subscription'] LOOP
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_visible(tenant_id))', t);
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+    END LOOP;
+END
+$$;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS subscription_tag;
+DROP TABLE IF EXISTS tag;
+ALTER TABLE subscription DROP COLUMN IF EXISTS category;
@@ -0,0 +1,40 @@
+ALTER TABLE subscription ADD COLUMN category VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_subscription_category ON subscription(tenant_id, category);
+
+-- tags are free-form labels, created on first use
+CREATE TABLE tag (
+    id SERIAL PRIMARY KEY,
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (tenant_id, name)
+);
+
+CREATE TABLE subscription_tag (
+    tenant_id UUID NOT NULL DEFAULT current_tenant_id() REFERENCES tenant(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL REFERENCES subscription(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tag(id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX idx_subscription_tag_tag_id ON subscription_tag(tag_id);
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['tag', 'subscription_tag'] LOOP
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_visible(tenant_id))', t);
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+    END LOOP;
+END
+$$;
+
+-- subscriptions of catalog services start out in the category of their service
+SELECT set_config('app.all_tenants', 'on', true);
+
+UPDATE subscription SET category = service.category
+FROM service
+WHERE service.id = subscription.service_id;
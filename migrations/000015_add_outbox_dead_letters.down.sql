@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_webhook_delivery_delivered;
+DROP INDEX IF EXISTS idx_outbox_processed_at;
+
+DROP INDEX IF EXISTS idx_outbox_unprocessed;
+CREATE INDEX idx_outbox_unprocessed ON outbox(id) WHERE processed_at IS NULL;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS dead_at;
@@ -0,0 +1,9 @@
+-- entries that keep failing are parked instead of being retried forever
+ALTER TABLE outbox ADD COLUMN dead_at TIMESTAMP;
+
+DROP INDEX IF EXISTS idx_outbox_unprocessed;
+CREATE INDEX idx_outbox_unprocessed ON outbox(id) WHERE processed_at IS NULL AND dead_at IS NULL;
+
+-- processed entries and delivered webhooks are pruned after a retention period
+CREATE INDEX idx_outbox_processed_at ON outbox(processed_at) WHERE processed_at IS NOT NULL;
+CREATE INDEX idx_webhook_delivery_delivered ON webhook_delivery(updated_at) WHERE status = 'delivered';